@@ -1,3 +1,8 @@
-module github.com/ikamii/go-todo-cli
+module github.com/ikamii/go-todo-cli/v1
 
 go 1.24.0
+
+require github.com/ikamii/go-todo-cli v0.0.0
+
+// The engine is shared with the v2 CLI in this repository
+replace github.com/ikamii/go-todo-cli => ../v2
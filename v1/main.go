@@ -2,36 +2,22 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
-)
 
-// Respresents a todo item
-type Task struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
-}
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
 
-// Manages a list of tasks
+// Manages a list of tasks, printing what each change did
 type TodoList struct {
-	Tasks  []Task `json:"tasks"`
-	nextID int
+	todo.List
 }
 
 // Adds a task to the list
 func (tl *TodoList) AddTask(title string) {
-	task := Task{
-		ID:        tl.nextID,
-		Title:     title,
-		Completed: false,
-	}
-	tl.Tasks = append(tl.Tasks, task)
-	tl.nextID++
+	task := tl.List.AddTask(title)
 	fmt.Printf("Added task: %s (ID: %d)\n", title, task.ID)
 }
 
@@ -55,64 +41,36 @@ func (tl *TodoList) ListTasks() {
 
 // Marks a task as completed
 func (tl *TodoList) CompleteTask(id int) error {
-	for i, task := range tl.Tasks {
-		if task.ID == id {
-			tl.Tasks[i].Completed = true
-			fmt.Printf("Marked task %d as completed: %s\n", id, task.Title)
-			return nil
-		}
+	task, err := tl.List.CompleteTask(id)
+	if err != nil {
+		return fmt.Errorf("Task with ID %d not found", id)
 	}
-	return fmt.Errorf("Task with ID %d not found", id)
+	fmt.Printf("Marked task %d as completed: %s\n", id, task.Title)
+	return nil
 }
 
 // Removes a task from the list
 func (tl *TodoList) DeleteTask(id int) error {
-	for i, task := range tl.Tasks {
-		if task.ID == id {
-			tl.Tasks = append(tl.Tasks[:i], tl.Tasks[i+1:]...)
-			fmt.Printf("Deleted task %d: %s\n", id, task.Title)
-			return nil
-		}
+	task, err := tl.List.DeleteTask(id)
+	if err != nil {
+		return fmt.Errorf("Task with ID %d not found", id)
 	}
-	return fmt.Errorf("Task with ID %d not found", id)
+	fmt.Printf("Deleted task %d: %s\n", id, task.Title)
+	return nil
 }
 
 // Saves the todo list to a JSON file
 func (tl *TodoList) SaveToFile(filename string) error {
-	data, err := json.MarshalIndent(tl, "", "  ")
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(filename, data, 0644)
+	return todo.FileStore{Path: filename}.Save(&tl.List)
 }
 
-// Loads the todo list from a JSON file
+// Loads the todo list from a JSON file; a missing file is an empty list
 func (tl *TodoList) LoadFromFile(filename string) error {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		// If the file doesn't exist, start with an empty list
-		if os.IsNotExist(err) {
-			tl.Tasks = []Task{}
-			tl.nextID = 1
-			return nil
-		}
-		return err
-	}
-
-	err = json.Unmarshal(data, tl)
+	list, err := todo.FileStore{Path: filename}.Load()
 	if err != nil {
 		return err
 	}
-
-	// Find the highest ID to set nextID correctly
-	maxID := 0
-	for _, task := range tl.Tasks {
-		if task.ID > maxID {
-			maxID = task.ID
-		}
-	}
-	tl.nextID = maxID + 1
-
+	tl.List = *list
 	return nil
 }
 
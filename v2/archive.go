@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// Completed tasks moved out of the store are kept in todo.archive.json.
+// It is always a JSON file, whatever the backend: it is only read when
+// browsing or restoring
+func archivePath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".archive.json"
+}
+
+// The archive is encrypted when the store is
+func archiveStore(filename string) todo.FileStore {
+	return todo.FileStore{Path: archivePath(filename), Passphrase: storePassphrase(filename)}
+}
+
+func loadArchive(filename string) (*TodoList, error) {
+	list, err := archiveStore(filename).Load()
+	if err != nil {
+		return nil, err
+	}
+	return &TodoList{List: *list}, nil
+}
+
+// Moves completed tasks into the archive. A task with open subtasks stays
+// until they are done, so trees are archived whole. Archived tasks get new
+// IDs in the archive; the store's IDs stay short
+func (tl *TodoList) Archive(filename string) (int, error) {
+	var moved []Task
+	for _, task := range tl.Tasks {
+		if !task.Completed || slices.ContainsFunc(tl.descendants(task), func(t Task) bool { return !t.Completed }) {
+			continue
+		}
+		moved = append(moved, task)
+	}
+	if len(moved) == 0 {
+		return 0, nil
+	}
+	archive, err := loadArchive(filename)
+	if err != nil {
+		return 0, err
+	}
+	for _, task := range moved {
+		archive.List.Add(task)
+		if _, err := tl.List.DeleteTask(task.ID); err != nil {
+			return 0, err
+		}
+	}
+	// The archive is written first: if saving the store fails afterwards
+	// the tasks are in both places rather than lost
+	if err := archiveStore(filename).Save(&archive.List); err != nil {
+		return 0, err
+	}
+	return len(moved), nil
+}
+
+// Moves an archived task, and its archived subtasks, back into the store
+// under new IDs
+func (tl *TodoList) Restore(filename, ref string) error {
+	archive, err := loadArchive(filename)
+	if err != nil {
+		return err
+	}
+	id, err := archive.Resolve(ref)
+	if err != nil {
+		return err
+	}
+	task, _ := archive.Find(id)
+	for _, t := range append([]Task{task}, archive.descendants(task)...) {
+		if _, err := archive.List.DeleteTask(t.ID); err != nil {
+			return err
+		}
+		// Already back, e.g. after undoing the archive
+		if slices.ContainsFunc(tl.Tasks, func(live Task) bool { return live.UUID == t.UUID }) {
+			continue
+		}
+		restored := tl.List.Add(t)
+		fmt.Printf(T("Restored task %d: %s\n"), restored.ID, restored.Title)
+	}
+	return archiveStore(filename).Save(&archive.List)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Attachments copied into the blob store are referenced as
+// blob:<sha256>/<file name>, so the same content is stored once and a
+// reference resolves on any machine the store directory is synced to
+var blobRefRe = regexp.MustCompile(`^blob:([0-9a-f]{64})/(.+)$`)
+
+// Names of files in the blob store
+var blobNameRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Blob store directory: $TODO_ATTACHMENTS_DIR, else todo.attachments next
+// to the store, which syncs along with it on a shared drive
+func blobDir(filename string) string {
+	if dir := cmp.Or(os.Getenv("TODO_ATTACHMENTS_DIR"), cfg.AttachmentsDir); dir != "" {
+		return dir
+	}
+	return strings.TrimSuffix(filename, ".json") + ".attachments"
+}
+
+// Where a blob lives; the first byte fans out the directory
+func blobPath(dir, sum string) string {
+	return filepath.Join(dir, sum[:2], sum)
+}
+
+// Splits a blob reference into its hash and file name
+func parseBlobRef(ref string) (sum, name string, ok bool) {
+	m := blobRefRe.FindStringSubmatch(ref)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// Copies a file into the blob store unless the same content is there
+// already, and returns its reference
+func storeBlob(dir, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	// Hash while copying to a temp file, then move it into place by hash
+	tmp, err := os.CreateTemp(dir, ".incoming-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), f); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	ref := "blob:" + sum + "/" + filepath.Base(path)
+
+	dest := blobPath(dir, sum)
+	if _, err := os.Stat(dest); err == nil {
+		return ref, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	return ref, os.Rename(tmp.Name(), dest)
+}
+
+// Copies the blobs the tasks refer to from one store directory to
+// another where they're missing, returning how many were copied
+func copyMissingBlobs(from, to string, tasks []Task) (int, error) {
+	copied := 0
+	if filepath.Clean(from) == filepath.Clean(to) {
+		return 0, nil
+	}
+	for _, task := range tasks {
+		for _, link := range task.Attachments {
+			sum, _, ok := parseBlobRef(link)
+			if !ok {
+				continue
+			}
+			src, dest := blobPath(from, sum), blobPath(to, sum)
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+			if _, err := os.Stat(src); err != nil {
+				// Not here either; it may come with a later sync
+				continue
+			}
+			if _, err := storeBlob(to, src); err != nil {
+				return copied, err
+			}
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// Copies a file into the blob store and attaches it to a task
+func (tl *TodoList) AttachFile(id int, dir, path string) error {
+	task, ok := tl.Find(id)
+	if !ok {
+		return fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	ref, err := storeBlob(dir, path)
+	if err != nil {
+		return err
+	}
+	task.Attachments = union(task.Attachments, []string{ref})
+	if err := tl.Update(task); err != nil {
+		return err
+	}
+	fmt.Printf(T("Attached %s to task %d\n"), filepath.Base(path), id)
+	return nil
+}
+
+// Describes an attachment for show: links as they are, blobs by name with
+// their local path, or a note when the blob hasn't arrived on this machine
+func describeAttachment(dir, link string) string {
+	sum, name, ok := parseBlobRef(link)
+	if !ok {
+		return link
+	}
+	path := blobPath(dir, sum)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Sprintf(T("%s (not on this machine yet)"), name)
+	}
+	return fmt.Sprintf("%s (%s)", name, path)
+}
+
+// Deletes blobs no task refers to, completed tasks included, and returns
+// how many were removed and the bytes freed. dryRun only counts them
+func collectBlobs(tl *TodoList, dir string, dryRun bool) (removed int, freed int64, err error) {
+	used := map[string]bool{}
+	for _, task := range tl.Tasks {
+		for _, link := range task.Attachments {
+			if sum, _, ok := parseBlobRef(link); ok {
+				used[sum] = true
+			}
+		}
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || used[d.Name()] || !blobNameRe.MatchString(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
+
+// Handles `todo attach <task> <file>...`
+func attachCommand(filename string, args []string) error {
+	if len(args) < 2 {
+		return errors.New(T("usage: todo attach <task> <file>..."))
+	}
+	tl := loadTodoList(filename)
+	id, err := tl.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+	return tl.Transaction(filename, func() error {
+		for _, path := range args[1:] {
+			if err := tl.AttachFile(id, blobDir(filename), path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Handles `todo attachments gc [--dry-run]`
+func attachmentsCommand(filename string, args []string) error {
+	if len(args) == 0 || args[0] != "gc" {
+		return errors.New(T("usage: todo attachments gc [--dry-run]"))
+	}
+	attachmentsCmd := flag.NewFlagSet("attachments gc", flag.ExitOnError)
+	dryRun := attachmentsCmd.Bool("dry-run", false, "only report what would be removed")
+	attachmentsCmd.Parse(args[1:])
+
+	removed, freed, err := collectBlobs(loadTodoList(filename), blobDir(filename), *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf(T("Would remove %d unused attachments (%d bytes)\n"), removed, freed)
+	} else {
+		fmt.Printf(T("Removed %d unused attachments (%d bytes)\n"), removed, freed)
+	}
+	return nil
+}
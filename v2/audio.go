@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Client for a speech-to-text endpoint taking a multipart "file" upload and
+// answering {"text": ...}: the OpenAI transcriptions API and whisper.cpp's
+// server (/inference) both do. Configured with TODO_TRANSCRIBE_URL (default
+// the OpenAI API), TODO_TRANSCRIBE_MODEL and the key from
+// TODO_TRANSCRIBE_API_KEY or the keychain entry service "todo-cli",
+// account "transcribe"
+type transcriber struct {
+	url    string
+	model  string
+	apiKey string
+	http   *http.Client
+}
+
+func newTranscriber() *transcriber {
+	t := &transcriber{
+		url:    os.Getenv("TODO_TRANSCRIBE_URL"),
+		model:  os.Getenv("TODO_TRANSCRIBE_MODEL"),
+		apiKey: os.Getenv("TODO_TRANSCRIBE_API_KEY"),
+		// Long memos take a while on a local CPU
+		http: &http.Client{Timeout: 5 * time.Minute},
+	}
+	if t.url == "" {
+		t.url = "https://api.openai.com/v1/audio/transcriptions"
+	}
+	if t.model == "" {
+		t.model = "whisper-1"
+	}
+	if t.apiKey == "" {
+		key, err := keychainSecret("todo-cli", "transcribe")
+		if err != nil {
+			// A local whisper.cpp server needs no key
+			slog.Info("no transcription API key", "err", err)
+		}
+		t.apiKey = key
+	}
+	return t
+}
+
+// Uploads the audio file and returns its transcript
+func (t *transcriber) transcribe(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	mw.WriteField("model", t.model)
+	mw.WriteField("response_format", "json")
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	slog.Debug("transcription request", "url", t.url, "model", t.model, "status", resp.Status, "took", time.Since(start))
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(T("transcription endpoint returned %s: %s"), resp.Status, strings.TrimSpace(string(data)))
+	}
+	var reply struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return "", err
+	}
+	// Whisper pads segments with spaces and newlines
+	text := strings.Join(strings.Fields(reply.Text), " ")
+	if text == "" {
+		return "", fmt.Errorf(T("no speech found in %s"), path)
+	}
+	return text, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A chat network the bot talks through; runBot only depends on this, so
+// other networks (Matrix) can be added next to Telegram
+type botTransport interface {
+	// Waits for new messages, returning none after a quiet spell
+	receive() ([]botMessage, error)
+	send(chat, text string) error
+	name() string
+}
+
+type botMessage struct {
+	chat string
+	text string
+}
+
+// How often reminders are checked while waiting for messages
+const botPollTimeout = 30 * time.Second
+
+// Serves commands from the allowed chats and sends them reminders for
+// tasks that come due and follow-ups that have arrived. The store is
+// reloaded for every command, so the CLI can be used at the same time
+func runBot(filename string, transport botTransport, allowed map[string]bool) error {
+	fmt.Printf(T("Bot running on %s for %d chats; Ctrl-C to stop\n"), transport.name(), len(allowed))
+	reminded := map[string]string{}
+	failures := 0
+	for {
+		messages, err := transport.receive()
+		if err != nil {
+			// Ride out network blips, backing off up to a minute
+			failures++
+			slog.Warn("bot receive failed", "transport", transport.name(), "err", err)
+			time.Sleep(min(time.Duration(failures)*5*time.Second, time.Minute))
+			continue
+		}
+		failures = 0
+		for _, msg := range messages {
+			reply := fmt.Sprintf(T("Not authorized. Add chat %s to TODO_TELEGRAM_CHATS to use this bot."), msg.chat)
+			if allowed[msg.chat] {
+				reply = botReply(filename, msg.text)
+			}
+			if err := transport.send(msg.chat, reply); err != nil {
+				slog.Warn("bot send failed", "chat", msg.chat, "err", err)
+			}
+		}
+		for _, text := range botReminders(loadTodoList(filename), time.Now(), reminded) {
+			for chat := range allowed {
+				if err := transport.send(chat, text); err != nil {
+					slog.Warn("bot send failed", "chat", chat, "err", err)
+				}
+			}
+		}
+	}
+}
+
+// Runs one chat command against the store and returns the answer
+func botReply(filename, text string) string {
+	command, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	command = strings.ToLower(strings.TrimPrefix(command, "/"))
+	arg = strings.TrimSpace(arg)
+	tl := loadTodoList(filename)
+
+	switch command {
+	case "add":
+		if arg == "" {
+			return T("Usage: add <task description>")
+		}
+		var added Task
+		err := tl.Transaction(filename, func() error {
+			var err error
+			added, err = tl.add(withRules(Task{Title: normalizeTitle(arg)}))
+			return err
+		})
+		if err != nil {
+			return fmt.Sprintf(T("Error: %v"), err)
+		}
+		return fmt.Sprintf(T("Added task %d: %s"), added.ID, added.Title)
+
+	case "list":
+		now := time.Now()
+		tasks := tl.Filter(func(task Task) bool {
+			if task.Completed || task.Someday {
+				return false
+			}
+			// "list today": what is due today or already late
+			return arg != "today" || (task.Due != "" && dateReached(task.Due, now))
+		})
+		if len(tasks) == 0 {
+			return T("No tasks found.")
+		}
+		var b strings.Builder
+		for _, task := range tasks {
+			fmt.Fprintf(&b, "%d %s", task.ID, task.Title)
+			if _, labels := taskDetails(task, now); len(labels) > 0 {
+				b.WriteString(" (" + strings.Join(labels, ", ") + ")")
+			}
+			b.WriteString("\n")
+		}
+		return strings.TrimSuffix(b.String(), "\n")
+
+	case "done", "complete":
+		id, err := tl.Resolve(arg)
+		if err != nil {
+			return fmt.Sprintf(T("Error: %v"), err)
+		}
+		var completed, next Task
+		err = tl.Transaction(filename, func() error {
+			completed, next, err = tl.complete(id)
+			return err
+		})
+		if err != nil {
+			return fmt.Sprintf(T("Error: %v"), err)
+		}
+		reply := fmt.Sprintf(T("Marked task %d as completed: %s"), id, completed.Title)
+		if next.ID != 0 {
+			reply += "\n" + fmt.Sprintf(T("Next occurrence: task %d, due %s"), next.ID, next.Due)
+		}
+		return reply
+	}
+	return T("Commands: add <task>, list, list today, done <task-id|ref>")
+}
+
+// Reminders that haven't been sent today: open tasks due today or late,
+// and waiting tasks whose follow-up date has arrived. reminded maps task
+// UUIDs to the day they were last reminded about
+func botReminders(tl *TodoList, now time.Time, reminded map[string]string) []string {
+	today := now.Format(dateLayout)
+	var texts []string
+	for _, task := range tl.Tasks {
+		if task.Completed || reminded[task.UUID] == today {
+			continue
+		}
+		var text string
+		switch {
+		case overdue(task, now):
+			text = fmt.Sprintf(T("Overdue since %s: %s"), task.Due, task.Title)
+		case task.Due == today:
+			text = fmt.Sprintf(T("Due today: %s"), task.Title)
+		case task.Waiting && dateReached(task.FollowUp, now):
+			text = fmt.Sprintf(T("Follow up: %s (%s)"), task.Title, waitingNote(task))
+		default:
+			continue
+		}
+		reminded[task.UUID] = today
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// Telegram Bot API client using long polling, so the bot needs no public
+// address. Configured with TODO_TELEGRAM_TOKEN (or the keychain entry
+// service "todo-cli", account "telegram") and TODO_TELEGRAM_URL for a
+// self-hosted Bot API server
+type telegramBot struct {
+	baseURL string
+	offset  int64
+	http    *http.Client
+}
+
+func newTelegramBot() (*telegramBot, error) {
+	token := os.Getenv("TODO_TELEGRAM_TOKEN")
+	if token == "" {
+		var err error
+		if token, err = keychainSecret("todo-cli", "telegram"); err != nil || token == "" {
+			return nil, errors.New(T("no Telegram bot token: set TODO_TELEGRAM_TOKEN or store it in the keychain (service todo-cli, account telegram)"))
+		}
+	}
+	base := strings.TrimSuffix(os.Getenv("TODO_TELEGRAM_URL"), "/")
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	return &telegramBot{
+		baseURL: base + "/bot" + token,
+		http:    &http.Client{Timeout: botPollTimeout + 10*time.Second},
+	}, nil
+}
+
+func (b *telegramBot) name() string {
+	return "Telegram"
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+func (b *telegramBot) receive() ([]botMessage, error) {
+	query := url.Values{
+		"offset":  {strconv.FormatInt(b.offset, 10)},
+		"timeout": {strconv.Itoa(int(botPollTimeout.Seconds()))},
+	}
+	var updates []telegramUpdate
+	if err := b.call(http.MethodGet, "getUpdates?"+query.Encode(), nil, &updates); err != nil {
+		return nil, err
+	}
+	var messages []botMessage
+	for _, update := range updates {
+		b.offset = max(b.offset, update.UpdateID+1)
+		if update.Message != nil && update.Message.Text != "" {
+			messages = append(messages, botMessage{
+				chat: strconv.FormatInt(update.Message.Chat.ID, 10),
+				text: update.Message.Text,
+			})
+		}
+	}
+	return messages, nil
+}
+
+func (b *telegramBot) send(chat, text string) error {
+	body, err := json.Marshal(map[string]string{"chat_id": chat, "text": text})
+	if err != nil {
+		return err
+	}
+	return b.call(http.MethodPost, "sendMessage", body, nil)
+}
+
+// Calls a Bot API method and decodes its result
+func (b *telegramBot) call(method, path string, body []byte, result any) error {
+	req, err := http.NewRequest(method, b.baseURL+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.http.Do(req)
+	if err != nil {
+		// The URL holds the token; keep it out of logs
+		return fmt.Errorf("telegram %s: %w", strings.Split(path, "?")[0], errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("telegram: %s", resp.Status)
+	}
+	if !reply.OK {
+		return fmt.Errorf("telegram: %s", reply.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
+
+// Chat IDs allowed to use the bot, from TODO_TELEGRAM_CHATS
+func botAllowedChats() map[string]bool {
+	allowed := map[string]bool{}
+	for _, chat := range strings.Split(os.Getenv("TODO_TELEGRAM_CHATS"), ",") {
+		if chat = strings.TrimSpace(chat); chat != "" {
+			allowed[chat] = true
+		}
+	}
+	return allowed
+}
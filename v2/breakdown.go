@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const breakdownPrompt = `You help break large tasks into concrete, actionable subtasks.
+Reply with JSON only, in the form:
+{"subtasks": [{"title": "short imperative title", "estimate": "30m"}]}
+Use 3 to 8 subtasks. Estimates are durations like 15m, 2h or 1d.`
+
+// A subtask proposed by the model
+type proposedSubtask struct {
+	Title    string `json:"title"`
+	Estimate string `json:"estimate"`
+}
+
+// Asks the LLM to split a task into subtasks and adds the ones the user
+// confirms
+func breakdownTask(tl *TodoList, filename string, id int) error {
+	var task Task
+	for _, t := range tl.Tasks {
+		if t.ID == id {
+			task = t
+		}
+	}
+
+	var llm llmBackend = newLLMClient()
+	fmt.Printf(T("Asking %s to break down: %s\n"), llm.name(), task.Title)
+	reply, err := llm.chat(breakdownPrompt, task.Title)
+	if err != nil {
+		return err
+	}
+	var proposal struct {
+		Subtasks []proposedSubtask `json:"subtasks"`
+	}
+	if err := decodeLLMJSON(reply, &proposal); err != nil {
+		return err
+	}
+
+	var subtasks []proposedSubtask
+	for _, sub := range proposal.Subtasks {
+		if strings.TrimSpace(sub.Title) != "" {
+			subtasks = append(subtasks, sub)
+		}
+	}
+	if len(subtasks) == 0 {
+		fmt.Println(T("No subtasks proposed."))
+		return nil
+	}
+
+	fmt.Println(T("Proposed subtasks:"))
+	for i, sub := range subtasks {
+		if sub.Estimate != "" {
+			fmt.Printf("  %d. %s (~%s)\n", i+1, sub.Title, sub.Estimate)
+		} else {
+			fmt.Printf("  %d. %s\n", i+1, sub.Title)
+		}
+	}
+	if !confirm(fmt.Sprintf(T("Add these %d tasks?"), len(subtasks))) {
+		fmt.Println(T("Nothing added."))
+		return nil
+	}
+
+	return tl.Transaction(filename, func() error {
+		for _, sub := range subtasks {
+			if err := tl.AddTask(sub.Title); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Handles `todo complete <task>... [--cascade]` and `todo complete --all
+// [filter flags]`
+func completeCommand(filename string, args []string) error {
+	completeCmd := flag.NewFlagSet("complete", flag.ExitOnError)
+	cascade := completeCmd.Bool("cascade", false, "complete open subtasks too")
+	targets := registerTargets(completeCmd)
+	rest := parseInterspersed(completeCmd, args)
+	tl := loadTodoList(filename)
+	// Open tasks only, unless asked for by ID
+	targets.filter.status = cmp.Or(targets.filter.status, "open")
+	tasks, err := targets.resolve(tl, rest, T("Completing %d tasks:\n"))
+	if err != nil || tasks == nil {
+		return err
+	}
+	// Subtasks before their parents, so a parent picked along with its
+	// subtasks isn't refused for having them open
+	slices.SortStableFunc(tasks, func(a, b Task) int { return cmp.Compare(tl.depth(b), tl.depth(a)) })
+	done := 0
+	err = tl.Transaction(filename, func() error {
+		for _, task := range tasks {
+			if current, ok := tl.Find(task.ID); !ok || current.Completed {
+				fmt.Printf(T("Task %d is already completed\n"), task.ID)
+				continue
+			}
+			var err error
+			if *cascade {
+				err = tl.CompleteTree(task.ID)
+			} else {
+				err = tl.CompleteTask(task.ID)
+			}
+			if err != nil {
+				return err
+			}
+			done++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 1 {
+		fmt.Printf(T("Completed %d of %d tasks\n"), done, len(tasks))
+	}
+	return nil
+}
+
+// Handles `todo delete <task>... [--cascade]` and `todo delete --all
+// [filter flags]`
+func deleteCommand(filename string, args []string) error {
+	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
+	cascade := deleteCmd.Bool("cascade", false, "delete subtasks too instead of moving them up a level")
+	targets := registerTargets(deleteCmd)
+	rest := parseInterspersed(deleteCmd, args)
+	tl := loadTodoList(filename)
+	tasks, err := targets.resolve(tl, rest, T("Deleting %d tasks:\n"))
+	if err != nil || tasks == nil {
+		return err
+	}
+	deleted := 0
+	err = tl.Transaction(filename, func() error {
+		for _, task := range tasks {
+			// Gone already as a subtask of an earlier one, with --cascade
+			if _, ok := tl.Find(task.ID); !ok {
+				continue
+			}
+			var err error
+			if *cascade {
+				err = tl.DeleteTree(task.ID)
+			} else {
+				err = tl.DeleteTask(task.ID)
+			}
+			if err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 1 {
+		fmt.Printf(T("Deleted %d of %d tasks\n"), deleted, len(tasks))
+	}
+	return nil
+}
+
+// Which tasks a bulk command acts on: IDs, refs and ranges like 2-7, or
+// --all with the usual filter flags
+type bulkTargets struct {
+	all    *bool
+	yes    *bool
+	dryRun *bool
+	filter taskFilter
+}
+
+func registerTargets(fs *flag.FlagSet) *bulkTargets {
+	t := &bulkTargets{}
+	t.all = fs.Bool("all", false, "act on every task matching the filter flags")
+	t.yes = fs.Bool("yes", false, "with --all, go ahead without asking")
+	t.dryRun = fs.Bool("dry-run", false, "with --all, only show the tasks that would change")
+	t.filter.register(fs)
+	return t
+}
+
+// Returns the tasks to act on. With --all the matches are shown and
+// confirmed first; nil without an error means there is nothing to do
+func (t *bulkTargets) resolve(tl *TodoList, args []string, header string) ([]Task, error) {
+	if !*t.all {
+		if len(args) == 0 {
+			return nil, errors.New(T("Task ID required"))
+		}
+		return tl.resolveMany(args)
+	}
+	if len(args) > 0 {
+		return nil, errors.New(T("give task IDs or --all, not both"))
+	}
+	tasks := tl.Filter(t.filter.keep)
+	if len(tasks) == 0 {
+		fmt.Println(T("No tasks match the filter"))
+		return nil, nil
+	}
+	fmt.Printf(header, len(tasks))
+	tl.PrintTasks(tasks)
+	if *t.dryRun {
+		return nil, nil
+	}
+	if !*t.yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New(T("not changing tasks without confirmation; pass --yes"))
+		}
+		if !confirm(T("Apply?")) {
+			return nil, nil
+		}
+	}
+	return tasks, nil
+}
+
+// Resolves IDs, refs and ID ranges like 2-7, in the order given and
+// without repeats. Gaps in a range are skipped, but a range with no tasks
+// at all is an error
+func (tl *TodoList) resolveMany(args []string) ([]Task, error) {
+	var tasks []Task
+	seen := map[int]bool{}
+	add := func(task Task) {
+		if !seen[task.ID] {
+			seen[task.ID] = true
+			tasks = append(tasks, task)
+		}
+	}
+	for _, arg := range args {
+		from, to, ok, err := parseIDRange(arg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			inRange := tl.Filter(func(task Task) bool { return task.ID >= from && task.ID <= to })
+			if len(inRange) == 0 {
+				return nil, fmt.Errorf(T("no tasks with IDs %d to %d"), from, to)
+			}
+			slices.SortFunc(inRange, func(a, b Task) int { return a.ID - b.ID })
+			for _, task := range inRange {
+				add(task)
+			}
+			continue
+		}
+		id, err := tl.Resolve(arg)
+		if err != nil {
+			return nil, err
+		}
+		task, _ := tl.Find(id)
+		add(task)
+	}
+	return tasks, nil
+}
+
+// The most IDs one range may span
+const maxIDRange = 10000
+
+// Parses "2-7"; refs and UUIDs never read as ranges, as both sides must be
+// numbers. Backward and overly long ranges are errors
+func parseIDRange(arg string) (from, to int, ok bool, err error) {
+	a, b, found := strings.Cut(arg, "-")
+	if !found || !allDigits(a) || !allDigits(b) {
+		return 0, 0, false, nil
+	}
+	from, err1 := strconv.Atoi(a)
+	to, err2 := strconv.Atoi(b)
+	switch {
+	case err1 != nil || err2 != nil || from < 1:
+		return 0, 0, false, fmt.Errorf(T("invalid ID range %q"), arg)
+	case to < from:
+		return 0, 0, false, fmt.Errorf(T("ID range %q runs backwards; use %d-%d"), arg, to, from)
+	case to-from >= maxIDRange:
+		return 0, 0, false, fmt.Errorf(T("ID range %q spans more than %d IDs"), arg, maxIDRange)
+	}
+	return from, to, true, nil
+}
+
+// How many parents a task has above it
+func (tl *TodoList) depth(task Task) int {
+	depth := 0
+	for parent, ok := tl.parentOf(task); ok && depth <= len(tl.Tasks); parent, ok = tl.parentOf(parent) {
+		depth++
+	}
+	return depth
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+func TestParseIDRange(t *testing.T) {
+	tests := []struct {
+		arg      string
+		from, to int
+		ok       bool
+		wantErr  bool
+	}{
+		{arg: "2-7", from: 2, to: 7, ok: true},
+		{arg: "3-3", from: 3, to: 3, ok: true},
+		{arg: "1-10000", from: 1, to: 10000, ok: true},
+		{arg: "7"},
+		{arg: "a1b2"},
+		{arg: "-3"},
+		{arg: "3-"},
+		{arg: "1-+5"},
+		{arg: "0b0e9a2c-7f5d-4e21-9d43-5a1b2c3d4e5f"},
+		{arg: "12345678-1234-4e21-9d43-5a1b2c3d4e5f"},
+		{arg: "7-2", wantErr: true},
+		{arg: "0-5", wantErr: true},
+		{arg: "1-10001", wantErr: true},
+		{arg: "1-999999999", wantErr: true},
+		{arg: "1-99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			from, to, ok, err := parseIDRange(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIDRange(%q) error = %v, want error %v", tt.arg, err, tt.wantErr)
+			}
+			if from != tt.from || to != tt.to || ok != tt.ok {
+				t.Errorf("parseIDRange(%q) = %d, %d, %v, want %d, %d, %v", tt.arg, from, to, ok, tt.from, tt.to, tt.ok)
+			}
+		})
+	}
+}
+
+func TestResolveMany(t *testing.T) {
+	tl := &TodoList{List: todo.List{Tasks: []Task{
+		{ID: 9, Hash: "c3d4e5f6a7"}, {ID: 2, Hash: "a1b2c3d4e5"}, {ID: 5, Hash: "b2c3d4e5f6"}, {ID: 7, Hash: "d4e5f6a7b8"},
+	}}}
+	tests := []struct {
+		args    []string
+		want    []int
+		wantErr bool
+	}{
+		{args: []string{"2-7"}, want: []int{2, 5, 7}},
+		{args: []string{"9", "1-5", "5"}, want: []int{9, 2, 5}},
+		{args: []string{"b2c3", "3-9"}, want: []int{5, 7, 9}},
+		{args: []string{"3-4"}, wantErr: true},
+		{args: []string{"9-2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			tasks, err := tl.resolveMany(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveMany(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+			var got []int
+			for _, task := range tasks {
+				got = append(got, task.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("resolveMany(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Busy time from an external calendar, imported from an .ics URL with
+// `todo calendar import` and kept in todo.busy.json. Only the times are
+// kept, not what the meetings are about
+type busyTimes struct {
+	Source  string      `json:"source"`
+	Fetched time.Time   `json:"fetched"`
+	Blocks  []busyBlock `json:"blocks"`
+}
+
+type busyBlock struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// How far ahead recurring meetings are expanded
+const busyHorizon = 60 * 24 * time.Hour
+
+// Hours a task of each effort is counted as when planning a day; tasks
+// without an effort count as an hour
+var effortHours = map[string]float64{"quick": 0.5, "medium": 2, "deep": 4}
+
+func busyPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".busy.json"
+}
+
+// Reads the imported busy times; none have been imported is not an error
+func loadBusy(filename string) (busyTimes, error) {
+	var busy busyTimes
+	data, err := os.ReadFile(busyPath(filename))
+	if os.IsNotExist(err) {
+		return busy, nil
+	}
+	if err != nil {
+		return busy, err
+	}
+	return busy, json.Unmarshal(data, &busy)
+}
+
+// The calendar to import: the argument, $TODO_CALENDAR_URL or
+// calendar_url in the config file
+func calendarSource(arg string) (string, error) {
+	source := cmp.Or(arg, os.Getenv("TODO_CALENDAR_URL"), cfg.CalendarURL)
+	if source == "" {
+		return "", errors.New(T("no calendar to import: give an .ics URL or set TODO_CALENDAR_URL"))
+	}
+	return source, nil
+}
+
+// Opens an .ics source: http(s) and webcal URLs are fetched, anything
+// else is read as a file
+func openCalendar(source string) (io.ReadCloser, error) {
+	url := source
+	if rest, ok := strings.CutPrefix(url, "webcal://"); ok {
+		url = "https://" + rest
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return os.Open(strings.TrimPrefix(source, "file://"))
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf(T("fetching %s: %s"), source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Fetches the calendar and replaces the stored busy times with its
+// meetings from today up to busyHorizon
+func importCalendar(filename, source string, now time.Time) (busyTimes, error) {
+	r, err := openCalendar(source)
+	if err != nil {
+		return busyTimes{}, err
+	}
+	defer r.Close()
+	from := startOfDay(now)
+	blocks, err := parseICS(r, from, from.Add(busyHorizon))
+	if err != nil {
+		return busyTimes{}, err
+	}
+	busy := busyTimes{Source: source, Fetched: now, Blocks: blocks}
+	data, err := json.MarshalIndent(busy, "", "  ")
+	if err != nil {
+		return busyTimes{}, err
+	}
+	return busy, os.WriteFile(busyPath(filename), data, 0600)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// One content line of an .ics file: NAME;PARAM=x:VALUE
+type icsLine struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Reads the busy blocks between from and to out of an iCalendar stream.
+// Cancelled and transparent (free) events are skipped. Recurring events
+// are expanded for FREQ=DAILY and WEEKLY (with INTERVAL, COUNT, UNTIL and
+// BYDAY) minus their EXDATEs; other rules only count their first time
+func parseICS(r io.Reader, from, to time.Time) ([]busyBlock, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Long lines are folded onto continuation lines starting with a space
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var blocks []busyBlock
+	var event []icsLine
+	inEvent, sawCalendar := false, false
+	for _, raw := range lines {
+		line := parseICSLine(raw)
+		switch {
+		case line.name == "BEGIN" && line.value == "VCALENDAR":
+			sawCalendar = true
+		case line.name == "BEGIN" && line.value == "VEVENT":
+			inEvent, event = true, nil
+		case line.name == "END" && line.value == "VEVENT":
+			inEvent = false
+			expanded, err := eventBlocks(event, from, to)
+			if err != nil {
+				slog.Warn("skipping calendar event", "err", err)
+				continue
+			}
+			blocks = append(blocks, expanded...)
+		case inEvent:
+			event = append(event, line)
+		}
+	}
+	if !sawCalendar {
+		return nil, errors.New(T("not an iCalendar (.ics) file"))
+	}
+	slices.SortFunc(blocks, func(a, b busyBlock) int { return a.Start.Compare(b.Start) })
+	return blocks, nil
+}
+
+func parseICSLine(raw string) icsLine {
+	head, value, _ := strings.Cut(raw, ":")
+	parts := strings.Split(head, ";")
+	line := icsLine{name: strings.ToUpper(parts[0]), value: value, params: map[string]string{}}
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		line.params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return line
+}
+
+// Reads a DATE or DATE-TIME value: UTC (…Z), with a TZID, or floating
+// local time. allDay is true for dates
+func parseICSTime(line icsLine) (t time.Time, allDay bool, err error) {
+	loc := time.Local
+	if tzid := line.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	value := line.value
+	switch {
+	case line.params["VALUE"] == "DATE" || len(value) == 8:
+		t, err = time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	return t, false, err
+}
+
+func eventBlocks(event []icsLine, from, to time.Time) ([]busyBlock, error) {
+	var start, end time.Time
+	var allDay bool
+	var duration time.Duration
+	var rrule string
+	var exdates []time.Time
+	for _, line := range event {
+		var err error
+		switch line.name {
+		case "STATUS":
+			if strings.EqualFold(line.value, "CANCELLED") {
+				return nil, nil
+			}
+		case "TRANSP":
+			if strings.EqualFold(line.value, "TRANSPARENT") {
+				return nil, nil
+			}
+		case "DTSTART":
+			start, allDay, err = parseICSTime(line)
+		case "DTEND":
+			end, _, err = parseICSTime(line)
+		case "DURATION":
+			duration, err = parseICSDuration(line.value)
+		case "RRULE":
+			rrule = line.value
+		case "EXDATE":
+			for _, value := range strings.Split(line.value, ",") {
+				line.value = value
+				t, _, err := parseICSTime(line)
+				if err == nil {
+					exdates = append(exdates, t)
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %w", line.name, line.value, err)
+		}
+	}
+	if start.IsZero() {
+		return nil, errors.New("event without DTSTART")
+	}
+	switch {
+	case !end.IsZero():
+		duration = end.Sub(start)
+	case duration == 0 && allDay:
+		duration = 24 * time.Hour
+	}
+	if duration <= 0 {
+		return nil, nil
+	}
+
+	var blocks []busyBlock
+	for _, t := range occurrences(start, rrule, to) {
+		if slices.ContainsFunc(exdates, t.Equal) {
+			continue
+		}
+		if t.Add(duration).After(from) && t.Before(to) {
+			blocks = append(blocks, busyBlock{Start: t, End: t.Add(duration)})
+		}
+	}
+	return blocks, nil
+}
+
+// Reads durations like PT1H30M or P1D
+func parseICSDuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	var d time.Duration
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	n := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+		case c >= '0' && c <= '9':
+			n += string(c)
+		default:
+			v, err := strconv.Atoi(n)
+			if err != nil || units[c] == 0 {
+				return 0, fmt.Errorf("invalid duration")
+			}
+			d += time.Duration(v) * units[c]
+			n = ""
+		}
+	}
+	return d, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Start times of an event up to the horizon
+func occurrences(start time.Time, rrule string, to time.Time) []time.Time {
+	if rrule == "" {
+		return []time.Time{start}
+	}
+	rule := map[string]string{}
+	for _, part := range strings.Split(rrule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		rule[strings.ToUpper(key)] = value
+	}
+	freq := rule["FREQ"]
+	if freq != "DAILY" && freq != "WEEKLY" {
+		slog.Debug("calendar rule not expanded", "rrule", rrule)
+		return []time.Time{start}
+	}
+	interval, _ := strconv.Atoi(rule["INTERVAL"])
+	interval = max(interval, 1)
+	count, _ := strconv.Atoi(rule["COUNT"])
+	until := to
+	if rule["UNTIL"] != "" {
+		t, _, err := parseICSTime(icsLine{value: rule["UNTIL"], params: map[string]string{}})
+		if err == nil && t.Before(until) {
+			until = t
+			if len(rule["UNTIL"]) == 8 {
+				until = until.Add(24*time.Hour - time.Second)
+			}
+		}
+	}
+	days := map[time.Weekday]bool{}
+	for _, day := range strings.Split(rule["BYDAY"], ",") {
+		if wd, ok := icsWeekdays[strings.ToUpper(day)]; ok {
+			days[wd] = true
+		}
+	}
+	if freq == "WEEKLY" && len(days) == 0 {
+		days[start.Weekday()] = true
+	}
+
+	var times []time.Time
+	// Walks day by day, so the time of day stays put across DST changes
+	weekStart := start.AddDate(0, 0, -int(start.Weekday()))
+	for day := 0; ; day++ {
+		t := start.AddDate(0, 0, day)
+		if t.After(until) || (count > 0 && len(times) >= count) {
+			break
+		}
+		switch freq {
+		case "DAILY":
+			if day%interval != 0 {
+				continue
+			}
+		case "WEEKLY":
+			week := int(t.Sub(weekStart).Hours()/24) / 7
+			if week%interval != 0 || !days[t.Weekday()] {
+				continue
+			}
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// Hours of meetings on the day; overlapping meetings count once. Blocks
+// are sorted by start
+func (b busyTimes) hoursOn(day time.Time) float64 {
+	from := startOfDay(day)
+	to := from.AddDate(0, 0, 1)
+	var hours float64
+	covered := from
+	for _, block := range b.Blocks {
+		start, end := maxTime(block.Start, covered), minTime(block.End, to)
+		if end.After(start) {
+			hours += end.Sub(start).Hours()
+			covered = end
+		}
+	}
+	return hours
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// Hours of open tasks due on the day (YYYY-MM-DD), by their effort
+func (tl *TodoList) taskHoursOn(day string) float64 {
+	var hours float64
+	for _, task := range tl.Tasks {
+		if !task.Completed && task.Due == day {
+			hours += cmp.Or(effortHours[task.Effort], 1)
+		}
+	}
+	return hours
+}
+
+// Hours in a working day, from workday_hours in the config file
+func workdayHours() float64 {
+	return cmp.Or(cfg.WorkdayHours, 8)
+}
+
+// Hours to work on a day: none on a holiday
+func workdayHoursOn(date time.Time) float64 {
+	if _, holiday := holidayOn(date); holiday {
+		return 0
+	}
+	return workdayHours()
+}
+
+// A warning when meetings and the other tasks due leave no room for the
+// task on its due day; "" when there is room or no calendar was imported
+func (tl *TodoList) dayFullWarning(filename string, task Task) string {
+	busy, err := loadBusy(filename)
+	if err != nil || len(busy.Blocks) == 0 {
+		return ""
+	}
+	day := task.Due
+	date, err := time.ParseInLocation(dateLayout, day, time.Local)
+	if err != nil {
+		return ""
+	}
+	meetings, tasks := busy.hoursOn(date), tl.taskHoursOn(day)
+	if current, ok := tl.Find(task.ID); ok && !current.Completed && current.Due == day {
+		tasks -= cmp.Or(effortHours[current.Effort], 1)
+	}
+	if name, holiday := holidayOn(date); holiday {
+		return fmt.Sprintf(T("Note: %s is a holiday (%s)"), day, name)
+	}
+	if meetings+tasks+cmp.Or(effortHours[task.Effort], 1) <= workdayHours() {
+		return ""
+	}
+	return fmt.Sprintf(T("Note: %s is already full (%.1fh of meetings, %.1fh of tasks due, %.1fh workday)"), day, meetings, tasks, workdayHours())
+}
+
+// Built-in context provider for `next`: on a day mostly spent in
+// meetings, quick tasks that fit between them come first
+func calendarSuggestion(filename string, now time.Time) (suggestion, bool) {
+	busy, err := loadBusy(filename)
+	if err != nil || len(busy.Blocks) == 0 {
+		return suggestion{}, false
+	}
+	meetings := busy.hoursOn(now)
+	if meetings < workdayHours()/2 {
+		return suggestion{}, false
+	}
+	return suggestion{
+		provider: "calendar",
+		Note:     fmt.Sprintf(T("%.1fh of meetings today"), meetings),
+		effort:   "quick",
+	}, true
+}
+
+// Handles `todo calendar [import [<url>]] [--days 7]`: imports busy time,
+// or shows how full the coming days are
+func calendarCommand(filename string, args []string) error {
+	now := time.Now()
+	if len(args) > 0 && args[0] == "import" {
+		if len(args) > 2 {
+			return errors.New(T("usage: todo calendar import [<ics-url>]"))
+		}
+		source, err := calendarSource(strings.Join(args[1:], ""))
+		if err != nil {
+			return err
+		}
+		busy, err := importCalendar(filename, source, now)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(T("Imported %d busy blocks from %s\n"), len(busy.Blocks), source)
+		return nil
+	}
+
+	calCmd := flag.NewFlagSet("calendar", flag.ExitOnError)
+	days := calCmd.Int("days", 7, "how many days to show")
+	calCmd.Parse(args)
+	busy, err := loadBusy(filename)
+	if err != nil {
+		return err
+	}
+	if busy.Source == "" {
+		return errors.New(T("no calendar imported yet; run todo calendar import <ics-url>"))
+	}
+	fmt.Printf(T("Busy time from %s, fetched %s\n"), busy.Source, busy.Fetched.Local().Format("2006-01-02 15:04"))
+	tl := loadTodoList(filename)
+	for i := range max(*days, 1) {
+		date := startOfDay(now).AddDate(0, 0, i)
+		day := date.Format(dateLayout)
+		meetings, tasks := busy.hoursOn(date), tl.taskHoursOn(day)
+		free := workdayHoursOn(date) - meetings - tasks
+		line := fmt.Sprintf(T("%s  %4.1fh meetings  %4.1fh tasks due  %4.1fh free"), date.Format("Mon "+dateLayout), meetings, tasks, max(free, 0))
+		if name, holiday := holidayOn(date); holiday {
+			line += "  " + name
+		} else if free < 0 {
+			line += "  " + T("full")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings from the config file. Each one is a default: the matching
+// environment variable and flag still win
+type config struct {
+	File           string `yaml:"file"`
+	Backend        string `yaml:"backend"`
+	Format         string `yaml:"format"`
+	Accessible     bool   `yaml:"accessible"`
+	HooksDir       string `yaml:"hooks_dir"`
+	TemplatesDir   string `yaml:"templates_dir"`
+	RulesFile      string `yaml:"rules_file"`
+	AttachmentsDir string `yaml:"attachments_dir"`
+	// ID strategy for stores that haven't recorded one yet
+	IDStrategy string `yaml:"id_strategy"`
+	// .ics URL with the busy time to plan around, and the hours in a
+	// working day
+	CalendarURL  string  `yaml:"calendar_url"`
+	WorkdayHours float64 `yaml:"workday_hours"`
+	// Languages to read dates in besides English, e.g. [de, es]; the
+	// locale's language by default
+	DateLanguages []string `yaml:"date_languages"`
+	// Country code of the public holidays business-day dates, recurrences
+	// and capacity planning skip, and extra days off: "MM-DD name" every
+	// year or "YYYY-MM-DD name" once
+	HolidayCalendar string   `yaml:"holiday_calendar"`
+	Holidays        []string `yaml:"holidays"`
+	// Encrypt stores on their next save; see todo encrypt
+	Encrypt bool `yaml:"encrypt"`
+	// Order list sorts by without --sort, and whether it's reversed
+	ListSort    string `yaml:"list_sort"`
+	ListReverse bool   `yaml:"list_reverse"`
+	// Most tasks that can be pinned at once, 5 by default
+	MaxPins int `yaml:"max_pins"`
+	// API token for todo sync todoist; TODO_TODOIST_TOKEN wins
+	TodoistToken string `yaml:"todoist_token"`
+	// WebDAV login for todo sync dav://; TODO_SYNC_USER and
+	// TODO_SYNC_PASSWORD win
+	SyncUser     string `yaml:"sync_user"`
+	SyncPassword string `yaml:"sync_password"`
+	// Keep a read-only copy of the store in the cache directory, for
+	// reading when the store can't be reached
+	OfflineCache bool `yaml:"offline_cache"`
+}
+
+var cfg config
+
+// Where the config file is read from: $TODO_CONFIG, or config.yaml in the
+// user's config directory ($XDG_CONFIG_HOME/todo on Linux)
+func configPath() string {
+	if path := os.Getenv("TODO_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "config.yaml")
+}
+
+// Reads the config file; a missing file is an empty config. Unknown keys
+// are errors, so a typo doesn't silently do nothing
+func loadConfig(path string) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return c, fmt.Errorf(T("config file %s: %v"), path, err)
+	}
+	if c.IDStrategy != "" && !todo.ValidIDStrategy(c.IDStrategy) {
+		return c, fmt.Errorf(T("config file %s: unknown id_strategy %q (use increase, reuse or sequence)"), path, c.IDStrategy)
+	}
+	for _, lang := range c.DateLanguages {
+		if _, ok := dateWords[strings.ToLower(lang)]; !ok {
+			return c, fmt.Errorf(T("config file %s: unknown date language %q (available: %s)"), path, lang, formatNames(dateWords))
+		}
+	}
+	if c.ListSort != "" && !slices.Contains(sortOrders, c.ListSort) {
+		return c, fmt.Errorf(T("config file %s: unknown list_sort %q (use %s)"), path, c.ListSort, strings.Join(sortOrders, ", "))
+	}
+	if err := checkHolidays(c); err != nil {
+		return c, fmt.Errorf(T("config file %s: %v"), path, err)
+	}
+	if c.MaxPins < 0 {
+		return c, fmt.Errorf(T("config file %s: max_pins can't be negative"), path)
+	}
+	if c.WorkdayHours < 0 || c.WorkdayHours > 24 {
+		return c, fmt.Errorf(T("config file %s: workday_hours must be between 0 and 24"), path)
+	}
+	for _, p := range []*string{&c.File, &c.HooksDir, &c.TemplatesDir, &c.RulesFile, &c.AttachmentsDir} {
+		*p = expandHome(*p)
+	}
+	return c, nil
+}
+
+// Expands a leading ~/ to the home directory
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// Where tasks are kept when nothing says otherwise: $XDG_DATA_HOME/todo,
+// ~/.local/share/todo without it, or %LocalAppData%\todo on Windows
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "todo")
+	}
+	if dir := os.Getenv("LocalAppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "todo")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "todo")
+}
+
+// Picks the task store: --file, then $TODO_FILE, then the config file.
+// A todo.json (or todo.db) in the working directory comes next, so lists
+// kept next to a project keep working; otherwise the data directory
+func storeFile(flagValue string) (string, error) {
+	if path := cmp.Or(flagValue, os.Getenv("TODO_FILE"), cfg.File); path != "" {
+		return path, nil
+	}
+	for _, local := range []string{"todo.json", sqlitePath("todo.json")} {
+		if _, err := os.Stat(local); err == nil {
+			return "todo.json", nil
+		}
+	}
+	dir := dataDir()
+	if dir == "" {
+		return "todo.json", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo.json"), nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// State of the conflict screen `todo sync --resolve` opens: one task at a
+// time, its conflicting fields side by side, each settled as the local
+// value, the remote one or a typed merge
+type conflictState struct {
+	conflicts []syncConflict
+	results   []Task // what each task becomes, starting from the merge
+	current   int    // index into conflicts
+	cursor    int    // index into the current task's fields
+	editing   bool
+	input     []rune
+	message   string
+}
+
+// Lets the user settle each conflict on a full screen. The tasks come
+// back by UUID for the sync to write to both sides; quitting cancels the
+// sync
+func resolveConflicts(conflicts []syncConflict) (map[string]Task, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New(T("--resolve needs an interactive terminal"))
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state)
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+	return runConflictScreen(conflicts)
+}
+
+// The conflict screen itself, on a terminal already in raw mode; the tui
+// opens it from its own screen
+func runConflictScreen(conflicts []syncConflict) (map[string]Task, error) {
+	s := &conflictState{conflicts: conflicts}
+	for _, c := range conflicts {
+		s.results = append(s.results, c.Resolved)
+	}
+	buf := make([]byte, 64)
+	for {
+		s.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			return nil, errors.New(T("sync cancelled; nothing was changed"))
+		}
+		done, cancel := s.handle(string(buf[:n]))
+		if cancel {
+			return nil, errors.New(T("sync cancelled; nothing was changed"))
+		}
+		if done {
+			chosen := make(map[string]Task, len(conflicts))
+			for i, c := range conflicts {
+				chosen[c.Local.UUID] = s.results[i]
+			}
+			return chosen, nil
+		}
+	}
+}
+
+// Applies one chunk of input. Returns done once the last task is settled,
+// or cancel to abandon the sync
+func (s *conflictState) handle(in string) (done, cancel bool) {
+	if s.editing {
+		s.handleInput(in)
+		return false, false
+	}
+	c := s.conflicts[s.current]
+	field := c.Fields[s.cursor]
+	result := &s.results[s.current]
+	s.message = ""
+	switch in {
+	case "q", "\x03", "\x1b":
+		return false, true
+	case "j", "\x1b[B", "\x1bOB":
+		s.cursor = min(s.cursor+1, len(c.Fields)-1)
+	case "k", "\x1b[A", "\x1bOA":
+		s.cursor = max(s.cursor-1, 0)
+	case "l", "\x1b[D", "\x1bOD":
+		*result = takeFields(*result, c.Local, []string{field})
+	case "r", "\x1b[C", "\x1bOC":
+		*result = takeFields(*result, c.Remote, []string{field})
+	case "L":
+		*result = takeFields(*result, c.Local, c.Fields)
+	case "R":
+		*result = takeFields(*result, c.Remote, c.Fields)
+	case "m":
+		*result = takeFields(*result, c.Resolved, []string{field})
+	case "e":
+		s.editing, s.input = true, []rune(fieldValue(*result, field))
+	case "p":
+		s.current, s.cursor = max(s.current-1, 0), 0
+	case "\r", "\n", "n":
+		if s.current == len(s.conflicts)-1 {
+			return true, false
+		}
+		s.current, s.cursor = s.current+1, 0
+	}
+	return false, false
+}
+
+// Edits the merged value; Enter checks and keeps it, Esc cancels
+func (s *conflictState) handleInput(in string) {
+	switch in {
+	case "\x1b", "\x03":
+		s.editing, s.input = false, nil
+	case "\r", "\n":
+		c := s.conflicts[s.current]
+		if err := setFieldValue(&s.results[s.current], c.Fields[s.cursor], strings.TrimSpace(string(s.input)), time.Now()); err != nil {
+			s.message = err.Error()
+		}
+		s.editing, s.input = false, nil
+	default:
+		if strings.HasPrefix(in, "\x1b") || !utf8.ValidString(in) {
+			return
+		}
+		for _, r := range in {
+			switch {
+			case r == '\x7f' || r == '\b':
+				if len(s.input) > 0 {
+					s.input = s.input[:len(s.input)-1]
+				}
+			case r >= ' ':
+				s.input = append(s.input, r)
+			}
+		}
+	}
+}
+
+// Sets a field from typed text, checked like the matching add flag. Only
+// text fields can be typed; the others take one side's value
+func setFieldValue(task *Task, name, value string, now time.Time) error {
+	switch name {
+	case "title":
+		if value == "" {
+			return errors.New(T("title is required"))
+		}
+		task.Title = normalizeTitle(value)
+		return nil
+	case "tags":
+		tags, err := parseTags(splitFields(value))
+		if err == nil {
+			task.Tags = tags
+		}
+		return err
+	case "notes":
+		task.Notes = value
+		return nil
+	case "waiting_on":
+		task.WaitingOn = value
+		return nil
+	case "follow_up":
+		if value == "" {
+			task.FollowUp = ""
+			return nil
+		}
+		day, err := parseDate(value, now)
+		if err == nil {
+			task.FollowUp = day
+		}
+		return err
+	case "priority", "due", "context", "effort", "repeat":
+	default:
+		if _, builtin := taskFieldIndex(name); builtin {
+			return fmt.Errorf(T("%s can't be typed; take the local or remote value"), name)
+		}
+	}
+	mutations, err := parseMutations([]string{name + "=" + value}, now)
+	if err != nil {
+		return err
+	}
+	mutations[0].apply(task)
+	return nil
+}
+
+// Redraws the screen: the task, then a row per conflicting field with the
+// local, remote and resulting values
+func (s *conflictState) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	c := s.conflicts[s.current]
+	result := s.results[s.current]
+	nameWidth := displayWidth(T("Field"))
+	for _, name := range c.Fields {
+		nameWidth = max(nameWidth, displayWidth(name))
+	}
+	column := max((width-nameWidth-6)/3, 8)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	header := fmt.Sprintf(T("Conflict %d of %d: %s"), s.current+1, len(s.conflicts), c.Local.Title)
+	fmt.Fprintf(&b, "%s\r\n\r\n", truncateWidth(header, width-1))
+	row := func(name, local, remote, merged string) string {
+		cell := func(v string, w int) string {
+			v = truncateWidth(v, w)
+			return v + strings.Repeat(" ", max(w-displayWidth(v), 0))
+		}
+		return cell(name, nameWidth) + "  " + cell(local, column) + " " + cell(remote, column) + " " + truncateWidth(merged, column)
+	}
+	fmt.Fprintf(&b, "  %s\r\n", row(T("Field"), T("Local"), T("Remote"), T("Result")))
+	for i, name := range c.Fields {
+		value := fieldValue(result, name)
+		if s.editing && i == s.cursor {
+			value = string(s.input) + "_"
+		}
+		line := row(name, fieldValue(c.Local, name), fieldValue(c.Remote, name), value)
+		if i == s.cursor {
+			fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&b, "  %s\r\n", line)
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H", height-2)
+	b.WriteString(s.message)
+	fmt.Fprintf(&b, "\x1b[%d;1H", height)
+	help := T("[l]ocal  [r]emote  [m]erge  [e]dit  [L]/[R] all fields  [enter] next  [p]rev  [q] cancel")
+	if s.editing {
+		help = T("[enter] keep  [esc] cancel")
+	}
+	b.WriteString(truncateWidth(help, width-1))
+	fmt.Print(b.String())
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// State that lasts across invocations but isn't part of the task data,
+// such as the active GTD context. Kept next to the store
+type sessionState struct {
+	Context string `json:"context,omitempty"`
+	// Date the someday/maybe backlog was last reviewed
+	SomedayReviewed string `json:"someday_reviewed,omitempty"`
+	// How each sync target is synced, by target
+	Sync map[string]syncBinding `json:"sync,omitempty"`
+}
+
+func statePath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".state.json"
+}
+
+func loadState(filename string) (sessionState, error) {
+	var state sessionState
+	data, err := os.ReadFile(statePath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, err
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+func saveState(filename string, state sessionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath(filename), data, 0644)
+}
+
+// Contexts are written GTD-style with a leading @; "home" becomes "@home"
+func normalizeContext(context string) string {
+	context = strings.TrimSpace(context)
+	if context == "" || strings.HasPrefix(context, "@") {
+		return context
+	}
+	return "@" + context
+}
+
+// Reports whether a task belongs in the given context. Tasks without a
+// context can be done anywhere, so they show up in every context
+func inContext(task Task, context string) bool {
+	return context == "" || task.Context == "" || strings.EqualFold(task.Context, context)
+}
+
+// Sets or clears (with "") the context of a task
+func (tl *TodoList) SetContext(id int, context string) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Context = normalizeContext(context)
+			if context == "" {
+				fmt.Printf(T("Cleared context of task %d\n"), id)
+			} else {
+				fmt.Printf(T("Task %d is now in %s\n"), id, tl.Tasks[i].Context)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Handles `todo context [show|set|clear|list|assign]`
+func contextCommand(filename string, args []string) error {
+	state, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+	sub := "show"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "show":
+		if state.Context == "" {
+			fmt.Println(T("No active context; list shows all tasks."))
+		} else {
+			fmt.Printf(T("Active context: %s\n"), state.Context)
+		}
+		return nil
+
+	case "set":
+		if len(args) != 2 || normalizeContext(args[1]) == "" {
+			return errors.New(T("usage: todo context set @name"))
+		}
+		state.Context = normalizeContext(args[1])
+		if err := saveState(filename, state); err != nil {
+			return err
+		}
+		fmt.Printf(T("Active context: %s\n"), state.Context)
+		return nil
+
+	case "clear":
+		state.Context = ""
+		if err := saveState(filename, state); err != nil {
+			return err
+		}
+		fmt.Println(T("Context cleared; list shows all tasks."))
+		return nil
+
+	case "list":
+		counts := map[string]int{}
+		for _, task := range loadTodoList(filename).Tasks {
+			if task.Context != "" && !task.Completed && !task.Someday {
+				counts[task.Context]++
+			}
+		}
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			marker := " "
+			if strings.EqualFold(name, state.Context) {
+				marker = "*"
+			}
+			fmt.Printf(T("%s %s (%d open)\n"), marker, name, counts[name])
+		}
+		return nil
+
+	case "assign":
+		if len(args) != 3 {
+			return errors.New(T("usage: todo context assign <task-id|ref> @name (or \"\" to clear)"))
+		}
+		tl := loadTodoList(filename)
+		id, err := tl.Resolve(args[1])
+		if err != nil {
+			return err
+		}
+		return tl.Transaction(filename, func() error {
+			return tl.SetContext(id, args[2])
+		})
+	}
+	return fmt.Errorf(T("unknown context command %q"), sub)
+}
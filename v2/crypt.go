@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+	"golang.org/x/term"
+)
+
+// The passphrase once asked for, so a command reading several encrypted
+// files asks only once
+var passphrase string
+
+// Whether the store is kept encrypted: because it already is, or because
+// the config file asks for new stores to be
+func storeEncrypted(filename string) bool {
+	return cfg.Encrypt || fileEncrypted(filename)
+}
+
+func fileEncrypted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, 64)
+	n, _ := io.ReadFull(f, header)
+	return todo.IsEncrypted(header[:n])
+}
+
+// The passphrase function for the store's files, nil if it isn't encrypted
+func storePassphrase(filename string) func() (string, error) {
+	if !storeEncrypted(filename) {
+		return nil
+	}
+	return func() (string, error) { return readPassphrase(false) }
+}
+
+// The store's passphrase: TODO_PASSPHRASE, the keychain entry service
+// "todo-cli", account "store", or else asked for on the terminal. A new
+// passphrase is asked for twice, so a typo doesn't lock the store
+func readPassphrase(confirmNew bool) (string, error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if env := os.Getenv("TODO_PASSPHRASE"); env != "" {
+		passphrase = env
+		return passphrase, nil
+	}
+	if secret, err := keychainSecret("todo-cli", "store"); err == nil && secret != "" {
+		passphrase = secret
+		return passphrase, nil
+	} else if err != nil {
+		slog.Debug("no store passphrase in the keychain", "err", err)
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New(T("the store is encrypted: set TODO_PASSPHRASE or store the passphrase in the keychain (service todo-cli, account store)"))
+	}
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		answer, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(answer), err
+	}
+	answer, err := ask(T("Passphrase: "))
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return "", errors.New(T("the passphrase can't be empty"))
+	}
+	if confirmNew {
+		again, err := ask(T("Passphrase again: "))
+		if err != nil {
+			return "", err
+		}
+		if again != answer {
+			return "", errors.New(T("the passphrases don't match"))
+		}
+	}
+	passphrase = answer
+	return passphrase, nil
+}
+
+// Handles `todo encrypt` and `todo decrypt`: rewrites the store, its
+// backup, archive, undo journal and inbox encrypted with a passphrase, or
+// back in plain JSON. Attachments are left as they are
+func cryptCommand(filename string, encrypt bool) error {
+	if backend != "json" {
+		return fmt.Errorf(T("encryption needs the json backend, not %s"), backend)
+	}
+	if encrypt == fileEncrypted(filename) {
+		if encrypt {
+			return errors.New(T("the store is already encrypted"))
+		}
+		return errors.New(T("the store is not encrypted"))
+	}
+	if !encrypt && cfg.Encrypt {
+		return errors.New(T("the config file sets encrypt: true; remove it first, or the store is encrypted again on the next save"))
+	}
+	if _, err := readPassphrase(encrypt); err != nil {
+		return err
+	}
+	key := func() (string, error) { return passphrase, nil }
+	from, to := todo.FileStore{Path: filename}, todo.FileStore{Path: filename}
+	if encrypt {
+		to.Passphrase = key
+	} else {
+		from.Passphrase = key
+	}
+	files := []string{filename, archivePath(filename)}
+	for _, path := range files {
+		if _, err := os.Stat(path); os.IsNotExist(err) && path != filename {
+			continue
+		}
+		from.Path, to.Path = path, path
+		list, err := from.Load()
+		if err != nil {
+			return err
+		}
+		// Saved twice, so the backup of the old version goes too
+		for range 2 {
+			if err := to.Save(list); err != nil {
+				return err
+			}
+		}
+		os.Remove(path + ".corrupt")
+	}
+	if _, err := os.Stat(journalPath(filename)); err == nil {
+		j, err := loadJournal(filename)
+		if err != nil {
+			return err
+		}
+		if err := writeJournal(filename, j, encrypt); err != nil {
+			return err
+		}
+	}
+	for _, path := range []string{inboxPath(filename), inboxPath(filename) + ".merging"} {
+		if err := rewriteInbox(path, encrypt); err != nil {
+			return err
+		}
+	}
+	if encrypt {
+		fmt.Printf(T("Encrypted %s; keep the passphrase safe, the tasks can't be read without it\n"), filename)
+	} else {
+		fmt.Printf(T("Decrypted %s\n"), filename)
+	}
+	return nil
+}
+
+// Reads a sidecar file, opening it if it was encrypted
+func readSidecar(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !todo.IsEncrypted(data) {
+		return data, err
+	}
+	key, err := readPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	data, err = todo.Decrypt(data, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Seals a sidecar file's contents if the store is encrypted
+func sealSidecar(data []byte, encrypt bool) ([]byte, error) {
+	if !encrypt {
+		return data, nil
+	}
+	key, err := readPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	return todo.Encrypt(data, key)
+}
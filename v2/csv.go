@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Comma-separated values with a header row, one task per row. Without
+// --fields every built-in field and every custom field in use is a column
+func exportCSV(w io.Writer, tasks []Task, fields []string) error {
+	if fields == nil {
+		fields = defaultColumns(tasks)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		columns := taskColumns(task)
+		record := make([]string, len(fields))
+		for i, name := range fields {
+			record[i] = csvValue(columns[name])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		// Attachments; URLs and paths don't contain spaces often, and
+		// spreadsheets handle one cell better than several
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
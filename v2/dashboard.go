@@ -0,0 +1,226 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// A focus session in progress, kept in todo.timers.json so the dashboard
+// can show what is being worked on from another terminal
+type runningTimer struct {
+	UUID    string    `json:"uuid"`
+	Started time.Time `json:"started"`
+	PID     int       `json:"pid"`
+}
+
+func timersPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".timers.json"
+}
+
+func loadTimers(filename string) ([]runningTimer, error) {
+	var timers []runningTimer
+	data, err := os.ReadFile(timersPath(filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return timers, json.Unmarshal(data, &timers)
+}
+
+// Records that this process is timing the task with uuid, or, with "",
+// that it stopped. Failures are only logged; the timer is a courtesy
+func trackTimer(filename, uuid string) {
+	unlock, err := lockStore(filename)
+	if err != nil {
+		slog.Warn("could not record timer", "err", err)
+		return
+	}
+	defer unlock()
+	timers, err := loadTimers(filename)
+	if err != nil {
+		slog.Warn("could not read timers", "err", err)
+	}
+	timers = slices.DeleteFunc(timers, func(t runningTimer) bool { return t.PID == os.Getpid() })
+	if uuid != "" {
+		timers = append(timers, runningTimer{UUID: uuid, Started: time.Now(), PID: os.Getpid()})
+	}
+	if len(timers) == 0 {
+		err = os.Remove(timersPath(filename))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		var data []byte
+		if data, err = json.MarshalIndent(timers, "", "  "); err == nil {
+			err = os.WriteFile(timersPath(filename), data, 0644)
+		}
+	}
+	if err != nil {
+		slog.Warn("could not write timers", "err", err)
+	}
+}
+
+// Handles `todo dashboard [--refresh 30s]`: a read-only full-screen view
+// for a spare monitor, redrawn every second and reloaded from the store as
+// it changes. Without a terminal it prints the view once
+func dashboardCommand(filename string, args []string) error {
+	dashCmd := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	refresh := dashCmd.Duration("refresh", 30*time.Second, "how often to reread the store")
+	recent := dashCmd.Int("recent", 5, "number of recent completions to show")
+	dashCmd.Parse(args)
+	if *refresh <= 0 {
+		return fmt.Errorf(T("invalid refresh interval %s"), *refresh)
+	}
+
+	// Reads without merging the inbox or taking the lock: the dashboard
+	// never writes, and a stale view is fixed by the next reload
+	tl := &TodoList{}
+	if err := tl.LoadFromFile(filename); err != nil {
+		return err
+	}
+	timers, err := loadTimers(filename)
+	if err != nil {
+		slog.Warn("could not read timers", "err", err)
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Print(strings.ReplaceAll(tl.dashboard(filename, timers, *recent, time.Now(), 80, 0), "\r\n", "\n"))
+		return nil
+	}
+
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	loaded := time.Now()
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		fmt.Print("\x1b[H\x1b[2J" + tl.dashboard(filename, timers, *recent, time.Now(), width, height))
+		select {
+		case <-stop:
+			return nil
+		case now := <-tick.C:
+			if now.Sub(loaded) < *refresh {
+				continue
+			}
+			loaded = now
+			fresh := &TodoList{}
+			if err := fresh.LoadFromFile(filename); err != nil {
+				// Keep showing the last good view; the store may be mid-save
+				slog.Warn("could not reload store", "err", err)
+				continue
+			}
+			tl = fresh
+			if timers, err = loadTimers(filename); err != nil {
+				slog.Warn("could not read timers", "err", err)
+			}
+		}
+	}
+}
+
+// Renders the dashboard: counts, what is due today, running timers and
+// recent completions, cut to width and, when height isn't 0, to height
+func (tl *TodoList) dashboard(filename string, timers []runningTimer, recent int, now time.Time, width, height int) string {
+	var lines []string
+	add := func(format string, a ...any) {
+		lines = append(lines, truncateWidth(fmt.Sprintf(format, a...), width-1))
+	}
+	today := now.Format(dateLayout)
+
+	var open, overdueCount, dueToday, doneToday, waiting int
+	for _, task := range tl.Tasks {
+		switch {
+		case task.Completed:
+			if strings.HasPrefix(task.CompletedAt, today) {
+				doneToday++
+			}
+		case task.Waiting:
+			open++
+			waiting++
+		case overdue(task, now):
+			open++
+			overdueCount++
+		case task.Due == today:
+			open++
+			dueToday++
+		default:
+			open++
+		}
+	}
+	add("%s  %s", listName(filename), now.Format("Mon 2006-01-02 15:04"))
+	add("")
+	add(T("%d open   %d overdue   %d due today   %d waiting   %d done today"), open, overdueCount, dueToday, waiting, doneToday)
+
+	add("")
+	add("%s", T("Due today"))
+	due := tl.Filter(func(task Task) bool {
+		return !task.Completed && !task.Someday && (dateReached(task.Due, now) || task.Pinned)
+	})
+	sortTasks(due, "priority", false)
+	if len(due) == 0 {
+		add("  %s", T("Nothing scheduled"))
+	}
+	for _, task := range due {
+		marks, _ := taskDetails(task, now)
+		add("  %2d  %s%s  %s", task.ID, pinPrefix(task), task.Title, strings.Join(marks, "  "))
+	}
+
+	add("")
+	add("%s", T("In progress"))
+	shown := 0
+	for _, timer := range timers {
+		id, err := tl.Resolve(timer.UUID)
+		if err != nil {
+			continue
+		}
+		task, _ := tl.Find(id)
+		if task.Completed {
+			continue
+		}
+		add("  %2d  %s  %s", task.ID, task.Title, formatElapsed(now.Sub(timer.Started)))
+		shown++
+	}
+	if shown == 0 {
+		add("  %s", T("No timers running"))
+	}
+
+	add("")
+	add("%s", T("Recently completed"))
+	done := tl.Filter(func(task Task) bool { return task.Completed && task.CompletedAt != "" })
+	slices.SortStableFunc(done, func(a, b Task) int { return cmp.Compare(b.CompletedAt, a.CompletedAt) })
+	if len(done) == 0 {
+		add("  %s", T("Nothing completed"))
+	}
+	for _, task := range done[:max(min(recent, len(done)), 0)] {
+		at, err := time.Parse(time.RFC3339, task.CompletedAt)
+		when := task.CompletedAt
+		if err == nil {
+			when = at.Local().Format("01-02 15:04")
+		}
+		add("  %s  %s", when, task.Title)
+	}
+
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
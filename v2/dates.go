@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dates on tasks are plain calendar days in local time
+const dateLayout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+	"sun":      time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parses a date given as 2006-01-02 or as a phrase relative to today:
+// tomorrow, next friday, in 3 days, 2w, 3bd (working days) and the like
+func parseDate(s string, now time.Time) (string, error) {
+	s = strings.TrimSpace(s)
+	if _, err := time.ParseInLocation(dateLayout, s, time.Local); err == nil {
+		return s, nil
+	}
+	if date, ok := parseRelativeDate(strings.ToLower(s), now); ok {
+		return date.Format(dateLayout), nil
+	}
+	for _, lang := range dateLanguages() {
+		if date, ok := parseRelativeDate(translateDateWords(strings.ToLower(s), lang), now); ok {
+			return date.Format(dateLayout), nil
+		}
+	}
+	return "", fmt.Errorf(T("invalid date %q (use YYYY-MM-DD, tomorrow, next friday, in 3 days, 3d or 2w)"), s)
+}
+
+func parseRelativeDate(s string, now time.Time) (time.Time, bool) {
+	switch s {
+	case "today":
+		return now, true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), true
+	case "yesterday":
+		return now.AddDate(0, 0, -1), true
+	case "next week":
+		return now.AddDate(0, 0, 7), true
+	case "next month":
+		return now.AddDate(0, 1, 0), true
+	case "next business day", "next workday":
+		return addBusinessDays(now, 1), true
+	}
+
+	if day, ok := weekdays[strings.TrimPrefix(s, "next ")]; ok {
+		// Always ahead, so "friday" on a Friday means a week from now
+		days := (int(day)-int(now.Weekday())+6)%7 + 1
+		return now.AddDate(0, 0, days), true
+	}
+
+	// in 3 days, in 2 weeks, in 1 month
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		count, unit, ok := strings.Cut(rest, " ")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n < 0 {
+			return time.Time{}, false
+		}
+		switch strings.TrimSuffix(unit, "s") {
+		case "day":
+			return now.AddDate(0, 0, n), true
+		case "week":
+			return now.AddDate(0, 0, 7*n), true
+		case "month":
+			return now.AddDate(0, n, 0), true
+		case "business day", "workday":
+			return addBusinessDays(now, n), true
+		}
+		return time.Time{}, false
+	}
+
+	// 3bd, 3d, 2w
+	if count, ok := strings.CutSuffix(s, "bd"); ok {
+		if n, err := strconv.Atoi(count); err == nil && n >= 0 {
+			return addBusinessDays(now, n), true
+		}
+	}
+	if len(s) > 1 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil && n >= 0 {
+			switch s[len(s)-1] {
+			case 'd':
+				return now.AddDate(0, 0, n), true
+			case 'w':
+				return now.AddDate(0, 0, 7*n), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Reports whether a date has arrived; dates are compared as strings since
+// the layout sorts chronologically
+func dateReached(date string, now time.Time) bool {
+	return date != "" && date <= now.Format(dateLayout)
+}
+
+// Reports whether an open task's due date has passed
+func overdue(task Task, now time.Time) bool {
+	return !task.Completed && task.Due != "" && task.Due < now.Format(dateLayout)
+}
+
+// How long ago an RFC 3339 timestamp was, compactly: 5m, 3h, 4d, 2w, 5mo
+// or 1y; "" for a missing or unreadable time
+func formatAge(stamp string, now time.Time) string {
+	at, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return ""
+	}
+	d := max(now.Sub(at), time.Minute)
+	days := int(d.Hours() / 24)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case days < 14:
+		return fmt.Sprintf("%dd", days)
+	case days < 60:
+		return fmt.Sprintf("%dw", days/7)
+	case days < 365:
+		return fmt.Sprintf("%dmo", days/30)
+	}
+	return fmt.Sprintf("%dy", days/365)
+}
+
+// Sets or clears (with "") the due date of a task
+func (tl *TodoList) SetDue(id int, due string) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Due = due
+			if due == "" {
+				fmt.Printf(T("Cleared due date of task %d\n"), id)
+			} else {
+				fmt.Printf(T("Task %d is due %s\n"), id, due)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Words of date phrases and recurrence rules in other languages, mapped to
+// the English that parseDate and parseRepeat understand. "" drops a word,
+// such as an article. Phrases are translated word by word, so "nächsten
+// Freitag" reads as "next friday" and "in 3 Tagen" as "in 3 days"
+var dateWords = map[string]map[string]string{
+	"de": {
+		"heute": "today", "morgen": "tomorrow", "gestern": "yesterday", "übermorgen": "in 2 days",
+		"nächste": "next", "nächsten": "next", "nächster": "next", "nächstes": "next", "kommenden": "next", "kommende": "next",
+		"am": "", "in": "in", "tag": "day", "tagen": "days", "tage": "days",
+		"woche": "week", "wochen": "weeks", "monat": "month", "monaten": "months", "monate": "months",
+		"montag": "monday", "dienstag": "tuesday", "mittwoch": "wednesday", "donnerstag": "thursday",
+		"freitag": "friday", "samstag": "saturday", "sonnabend": "saturday", "sonntag": "sunday",
+		"täglich": "daily", "wöchentlich": "weekly", "monatlich": "monthly", "jährlich": "yearly",
+	},
+	"es": {
+		"hoy": "today", "mañana": "tomorrow", "manana": "tomorrow", "ayer": "yesterday",
+		"pasado mañana": "in 2 days", "pasado manana": "in 2 days",
+		"próximo": "next", "próxima": "next", "proximo": "next", "proxima": "next", "que viene": "next", "siguiente": "next",
+		"el": "", "la": "", "dentro de": "in", "en": "in",
+		"día": "day", "dia": "day", "días": "days", "dias": "days", "semana": "week", "semanas": "weeks", "mes": "month", "meses": "months",
+		"lunes": "monday", "martes": "tuesday", "miércoles": "wednesday", "miercoles": "wednesday", "jueves": "thursday",
+		"viernes": "friday", "sábado": "saturday", "sabado": "saturday", "domingo": "sunday",
+		"diario": "daily", "diaria": "daily", "semanal": "weekly", "mensual": "monthly", "anual": "yearly",
+	},
+	"fr": {
+		"aujourd'hui": "today", "demain": "tomorrow", "hier": "yesterday", "après-demain": "in 2 days", "apres-demain": "in 2 days",
+		"prochain": "next", "prochaine": "next", "le": "", "la": "", "dans": "in",
+		"jour": "day", "jours": "days", "semaine": "week", "semaines": "weeks", "mois": "month",
+		"lundi": "monday", "mardi": "tuesday", "mercredi": "wednesday", "jeudi": "thursday",
+		"vendredi": "friday", "samedi": "saturday", "dimanche": "sunday",
+		"quotidien": "daily", "quotidienne": "daily", "hebdomadaire": "weekly", "mensuel": "monthly", "mensuelle": "monthly", "annuel": "yearly", "annuelle": "yearly",
+	},
+}
+
+// Languages to read dates in after English: date_languages from the
+// config file, else the locale's language, then the others, so a phrase
+// in any known language is recognized
+func dateLanguages() []string {
+	preferred := cfg.DateLanguages
+	if len(preferred) == 0 {
+		preferred = localeCandidates(detectLocale())
+	}
+	var langs []string
+	for _, lang := range preferred {
+		lang = strings.ToLower(lang)
+		if _, ok := dateWords[lang]; ok && !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	others := make([]string, 0, len(dateWords))
+	for lang := range dateWords {
+		if !slices.Contains(langs, lang) {
+			others = append(others, lang)
+		}
+	}
+	sort.Strings(others)
+	return append(langs, others...)
+}
+
+// Translates a lowercase date phrase or recurrence rule into English
+func translateDateWords(s, lang string) string {
+	words := dateWords[lang]
+	// Phrases of several words first, longest first
+	var phrases []string
+	for phrase := range words {
+		if strings.Contains(phrase, " ") {
+			phrases = append(phrases, phrase)
+		}
+	}
+	sort.Slice(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	for _, phrase := range phrases {
+		s = strings.ReplaceAll(s, phrase, words[phrase])
+	}
+	var out []string
+	for _, word := range strings.Fields(s) {
+		if english, ok := words[word]; ok {
+			word = english
+		}
+		if word != "" {
+			out = append(out, word)
+		}
+	}
+	// "viernes próximo", "vendredi prochain": next comes after the word
+	if n := len(out); n > 1 && out[n-1] == "next" && out[0] != "next" {
+		out = append([]string{"next"}, out[:n-1]...)
+	}
+	return strings.Join(out, " ")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local) // a Wednesday
+	tests := []struct {
+		lang, in, want string
+	}{
+		{"en", "2026-12-01", "2026-12-01"},
+		{"en", "today", "2026-10-14"},
+		{"en", "Tomorrow", "2026-10-15"},
+		{"en", "next friday", "2026-10-16"},
+		{"en", "wed", "2026-10-21"},
+		{"en", "in 3 days", "2026-10-17"},
+		{"en", "2w", "2026-10-28"},
+		{"en", "3bd", "2026-10-19"},
+		{"de", "morgen", "2026-10-15"},
+		{"de", "übermorgen", "2026-10-16"},
+		{"de", "nächsten Freitag", "2026-10-16"},
+		{"de", "am Montag", "2026-10-19"},
+		{"de", "in 3 Tagen", "2026-10-17"},
+		{"de", "in 2 Wochen", "2026-10-28"},
+		{"es", "mañana", "2026-10-15"},
+		{"es", "pasado mañana", "2026-10-16"},
+		{"es", "viernes próximo", "2026-10-16"},
+		{"es", "el próximo lunes", "2026-10-19"},
+		{"es", "dentro de 3 días", "2026-10-17"},
+		{"fr", "aujourd'hui", "2026-10-14"},
+		{"fr", "demain", "2026-10-15"},
+		{"fr", "après-demain", "2026-10-16"},
+		{"fr", "vendredi prochain", "2026-10-16"},
+		{"fr", "dans 2 semaines", "2026-10-28"},
+	}
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.in, func(t *testing.T) {
+			cfg.DateLanguages = []string{tt.lang}
+			got, err := parseDate(tt.in, now)
+			if err != nil || got != tt.want {
+				t.Errorf("parseDate(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+	for _, in := range []string{"someday", "in a week", "2026-13-01"} {
+		if got, err := parseDate(in, now); err == nil {
+			t.Errorf("parseDate(%q) = %q, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const doPrompt = `You translate a user's request about their todo list into operations.
+The current tasks are given as JSON. Supported operations:
+  {"op": "add", "title": "..."}
+  {"op": "complete", "id": 3}
+  {"op": "delete", "id": 3}
+  {"op": "rename", "id": 3, "title": "..."}
+Only use IDs from the task list. Reply with JSON only, in the form:
+{"operations": [...], "unsupported": ["parts of the request you cannot express"]}`
+
+// One operation planned by the model
+type plannedOp struct {
+	Op    string `json:"op"`
+	ID    int    `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// Translates a natural-language request into operations, shows the plan
+// and applies it in one transaction once the user confirms
+func doRequest(tl *TodoList, filename, request string) error {
+	type taskSummary struct {
+		ID        int    `json:"id"`
+		Title     string `json:"title"`
+		Completed bool   `json:"completed"`
+	}
+	summary := make([]taskSummary, len(tl.Tasks))
+	for i, task := range tl.Tasks {
+		summary[i] = taskSummary{task.ID, task.Title, task.Completed}
+	}
+	tasksJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	var llm llmBackend = newLLMClient()
+	reply, err := llm.chat(doPrompt, "Tasks: "+string(tasksJSON)+"\nRequest: "+request)
+	if err != nil {
+		return err
+	}
+	var plan struct {
+		Operations  []plannedOp `json:"operations"`
+		Unsupported []string    `json:"unsupported"`
+	}
+	if err := decodeLLMJSON(reply, &plan); err != nil {
+		return err
+	}
+
+	titles := make(map[int]string, len(tl.Tasks))
+	for _, task := range tl.Tasks {
+		titles[task.ID] = task.Title
+	}
+	for _, part := range plan.Unsupported {
+		fmt.Printf(T("Can't do: %s\n"), part)
+	}
+	if len(plan.Operations) == 0 {
+		fmt.Println(T("Nothing to do."))
+		return nil
+	}
+
+	lines := make([]string, len(plan.Operations))
+	for i, op := range plan.Operations {
+		if lines[i], err = describeOp(op, titles); err != nil {
+			return err
+		}
+	}
+	fmt.Println(T("Planned operations:"))
+	for _, line := range lines {
+		fmt.Println("  " + line)
+	}
+	if !confirm(T("Apply these operations?")) {
+		fmt.Println(T("Nothing changed."))
+		return nil
+	}
+
+	return tl.Transaction(filename, func() error {
+		for _, op := range plan.Operations {
+			if err := applyOp(tl, op); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Describes an operation for the confirmation prompt, rejecting anything
+// malformed or pointing at tasks that don't exist
+func describeOp(op plannedOp, titles map[int]string) (string, error) {
+	title := strings.TrimSpace(op.Title)
+	switch op.Op {
+	case "add":
+		if title == "" {
+			return "", errors.New(T("planned add has no title"))
+		}
+		return fmt.Sprintf(T("add %q"), title), nil
+	case "complete", "delete", "rename":
+		current, ok := titles[op.ID]
+		if !ok {
+			return "", fmt.Errorf(T("planned %s refers to unknown task %d"), op.Op, op.ID)
+		}
+		switch op.Op {
+		case "complete":
+			return fmt.Sprintf(T("complete %d (%s)"), op.ID, current), nil
+		case "delete":
+			return fmt.Sprintf(T("delete %d (%s)"), op.ID, current), nil
+		}
+		if title == "" {
+			return "", fmt.Errorf(T("planned rename of %d has no title"), op.ID)
+		}
+		return fmt.Sprintf(T("rename %d (%s) to %q"), op.ID, current, title), nil
+	}
+	return "", fmt.Errorf(T("unsupported operation %q"), op.Op)
+}
+
+func applyOp(tl *TodoList, op plannedOp) error {
+	switch op.Op {
+	case "add":
+		return tl.AddTask(op.Title)
+	case "complete":
+		return tl.CompleteTask(op.ID)
+	case "delete":
+		return tl.DeleteTask(op.ID)
+	case "rename":
+		for i := range tl.Tasks {
+			if tl.Tasks[i].ID == op.ID {
+				tl.Tasks[i].Title = normalizeTitle(op.Title)
+				fmt.Printf(T("Updated task %d: %s\n"), op.ID, tl.Tasks[i].Title)
+				return nil
+			}
+		}
+		return fmt.Errorf(T("task with ID %d not found"), op.ID)
+	}
+	return fmt.Errorf(T("unsupported operation %q"), op.Op)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const editHeader = `# Edit the tasks below, one per line: <id> [ ] <title>, or [x] when done.
+# Deleting a line deletes the task; a line without an ID adds a new one.
+# Lines starting with # are ignored.
+`
+
+// Matches "<id> [x] title", "[ ] title" or a bare title. A leading number
+// only counts as an ID when a status box follows, so "3 apples" is a title
+var editLineRe = regexp.MustCompile(`^(?:(\d+)\s+\[([ xX])\]|\[([ xX])\])?\s*(.*)$`)
+
+// One parsed line of the edit buffer; ID is 0 for new tasks
+type editLine struct {
+	ID        int
+	Title     string
+	Completed bool
+}
+
+// Dumps the tasks whose title contains filter into a temp file, opens it in
+// the user's editor and applies the result: changed lines update, removed
+// lines delete and lines without an ID add tasks, all in one transaction
+func editAllInEditor(tl *TodoList, filename, filter string) error {
+	var shown []Task
+	filter = strings.ToLower(normalizeTitle(filter))
+	for _, task := range tl.Tasks {
+		if strings.Contains(strings.ToLower(task.Title), filter) {
+			shown = append(shown, task)
+		}
+	}
+
+	f, err := os.CreateTemp("", "todo-edit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	w.WriteString(T(editHeader))
+	for _, task := range shown {
+		fmt.Fprintln(w, formatEditLine(task))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := runEditor(f.Name()); err != nil {
+		return err
+	}
+
+	lines, err := readEditLines(f.Name())
+	if err != nil {
+		return err
+	}
+	return tl.Transaction(filename, func() error {
+		return applyEdits(tl, shown, lines)
+	})
+}
+
+func formatEditLine(task Task) string {
+	status := " "
+	if task.Completed {
+		status = "x"
+	}
+	return fmt.Sprintf("%d [%s] %s", task.ID, status, task.Title)
+}
+
+// Opens path in $VISUAL or $EDITOR, falling back to vi
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may carry its own arguments, e.g. "code --wait"
+	args := append(strings.Fields(editor), path)
+	slog.Debug("running editor", "cmd", args)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(T("editor %s: %w"), editor, err)
+	}
+	return nil
+}
+
+func readEditLines(path string) ([]editLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []editLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		m := editLineRe.FindStringSubmatch(text)
+		line := editLine{
+			Title:     normalizeTitle(m[4]),
+			Completed: strings.EqualFold(m[2]+m[3], "x"),
+		}
+		if m[1] != "" {
+			line.ID, _ = strconv.Atoi(m[1])
+		}
+		if line.Title == "" {
+			return nil, fmt.Errorf(T("line %d: task title cannot be empty"), n)
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Applies the edited lines against the tasks that were shown in the editor
+func applyEdits(tl *TodoList, shown []Task, lines []editLine) error {
+	original := make(map[int]Task, len(shown))
+	for _, task := range shown {
+		original[task.ID] = task
+	}
+
+	kept := make(map[int]bool)
+	for _, line := range lines {
+		if line.ID == 0 {
+			continue
+		}
+		if _, ok := original[line.ID]; !ok {
+			return fmt.Errorf(T("task with ID %d was not part of the edit"), line.ID)
+		}
+		if kept[line.ID] {
+			return fmt.Errorf(T("task with ID %d appears more than once"), line.ID)
+		}
+		kept[line.ID] = true
+	}
+
+	for _, task := range shown {
+		if !kept[task.ID] {
+			if err := tl.DeleteTask(task.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if line.ID == 0 {
+			if err := tl.AddTask(line.Title); err != nil {
+				return err
+			}
+			if line.Completed {
+				tl.Tasks[len(tl.Tasks)-1].Completed = true
+				tl.Tasks[len(tl.Tasks)-1].CompletedAt = time.Now().Format(time.RFC3339)
+			}
+			continue
+		}
+		old := original[line.ID]
+		if old.Title == line.Title && old.Completed == line.Completed {
+			continue
+		}
+		for i := range tl.Tasks {
+			if tl.Tasks[i].ID == line.ID {
+				tl.Tasks[i].Title = line.Title
+				if tl.Tasks[i].Completed != line.Completed {
+					tl.Tasks[i].Completed = line.Completed
+					tl.Tasks[i].CompletedAt = ""
+					if line.Completed {
+						tl.Tasks[i].CompletedAt = time.Now().Format(time.RFC3339)
+					}
+				}
+				fmt.Printf(T("Updated task %d: %s\n"), line.ID, line.Title)
+			}
+		}
+	}
+	return nil
+}
+
+// Handles `todo edit <task> [new title] [flags]`, changing the title and any
+// attribute in place; flags left out keep their value and "" clears one.
+// `edit --all` opens matching tasks in $EDITOR instead
+func editCommand(filename string, args []string) error {
+	editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+	all := editCmd.Bool("all", false, "edit all matching tasks in $EDITOR")
+	due := editCmd.String("due", "", "due date, or \"\" to clear it")
+	priority := editCmd.String("priority", "", "priority: high, medium, low or \"\"")
+	context := editCmd.String("context", "", "context, e.g. @home, or \"\"")
+	effort := editCmd.String("effort", "", "effort: quick, medium, deep or \"\"")
+	goal := editCmd.Int("goal", 0, "ID of the goal the task works towards, or 0")
+	parent := editCmd.String("parent", "", "task this is a subtask of, or \"\" for none")
+	repeat := editCmd.String("repeat", "", "recurrence: daily, weekly, monthly, yearly, workdays, every:3d or \"\"")
+	var tags, untags []string
+	editCmd.Func("tag", "add a tag (repeatable)", func(arg string) error {
+		tags = append(tags, arg)
+		return nil
+	})
+	editCmd.Func("untag", "remove a tag (repeatable)", func(arg string) error {
+		untags = append(untags, arg)
+		return nil
+	})
+	positional := parseInterspersed(editCmd, args)
+
+	tl := loadTodoList(filename)
+	if *all {
+		return editAllInEditor(tl, filename, strings.Join(positional, " "))
+	}
+	if len(positional) == 0 {
+		return errors.New(T("usage: todo edit <task> [new title] [--due d] [--priority p] [--context c] [--effort e] [--goal g] [--parent p] [--repeat r] [--tag t] [--untag t]"))
+	}
+	id, err := tl.Resolve(positional[0])
+	if err != nil {
+		return err
+	}
+	task, _ := tl.Find(id)
+
+	set := map[string]bool{}
+	editCmd.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if title := strings.Join(positional[1:], " "); strings.TrimSpace(title) != "" {
+		task.Title = normalizeTitle(title)
+	}
+	if set["due"] {
+		task.Due = ""
+		if *due != "" {
+			if task.Due, err = parseDate(*due, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	if set["priority"] {
+		if task.Priority, err = parsePriority(*priority); err != nil {
+			return err
+		}
+	}
+	if set["context"] {
+		task.Context = normalizeContext(*context)
+	}
+	if set["effort"] {
+		if task.Effort, err = parseEffort(*effort); err != nil {
+			return err
+		}
+	}
+	if set["repeat"] {
+		if task.Repeat, err = parseRepeat(*repeat); err != nil {
+			return err
+		}
+	}
+	if set["goal"] {
+		if _, ok := tl.findGoal(*goal); *goal != 0 && !ok {
+			return fmt.Errorf(T("goal with ID %d not found"), *goal)
+		}
+		task.Goal = *goal
+	}
+	if tags, err = parseTags(tags); err != nil {
+		return err
+	}
+	if untags, err = parseTags(untags); err != nil {
+		return err
+	}
+	task.Tags = slices.DeleteFunc(union(task.Tags, tags), func(tag string) bool {
+		return slices.Contains(untags, tag)
+	})
+	if len(task.Tags) == 0 {
+		task.Tags = nil
+	}
+
+	return tl.Transaction(filename, func() error {
+		if err := tl.Update(task); err != nil {
+			return err
+		}
+		if set["parent"] {
+			parentID := 0
+			if *parent != "" {
+				if parentID, err = tl.Resolve(*parent); err != nil {
+					return err
+				}
+			}
+			if err := tl.SetParent(id, parentID); err != nil {
+				return err
+			}
+		}
+		fmt.Printf(T("Updated task %d: %s\n"), id, task.Title)
+		return nil
+	})
+}
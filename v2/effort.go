@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How much energy a task takes, from a spare ten minutes to a focused block
+var efforts = []string{"quick", "medium", "deep"}
+
+// Checks an effort level; "" clears it
+func parseEffort(effort string) (string, error) {
+	effort = strings.ToLower(strings.TrimSpace(effort))
+	if effort == "" {
+		return "", nil
+	}
+	for _, known := range efforts {
+		if effort == known {
+			return effort, nil
+		}
+	}
+	return "", fmt.Errorf(T("unknown effort %q (use %s)"), effort, strings.Join(efforts, ", "))
+}
+
+// Sets or clears (with "") the effort of a task
+func (tl *TodoList) SetEffort(id int, effort string) error {
+	effort, err := parseEffort(effort)
+	if err != nil {
+		return err
+	}
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Effort = effort
+			if effort == "" {
+				fmt.Printf(T("Cleared effort of task %d\n"), id)
+			} else {
+				fmt.Printf(T("Task %d is now %s effort\n"), id, effort)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Open tasks that can be done right now: not waiting on anyone, not
+// parked for someday, in the active context and, when an effort is given,
+// only those tagged with it
+func (tl *TodoList) NextTasks(context, effort string) []Task {
+	return tl.Filter(func(task Task) bool {
+		if task.Completed || task.Waiting || task.Someday || !inContext(task, context) {
+			return false
+		}
+		return effort == "" || task.Effort == effort
+	})
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+	"golang.org/x/term"
+)
+
+// Exporters and importers by --format name. Exporters get the fields
+// picked with --fields, or nil for their default selection
+var (
+	exporters = map[string]func(w io.Writer, tasks []Task, fields []string) error{
+		"org":     exportOrg,
+		"csv":     exportCSV,
+		"json":    exportJSON,
+		"todotxt": exportTodoTxt,
+		"ics":     exportICS,
+	}
+	importers = map[string]func(r io.Reader) ([]Task, error){
+		"org":     importOrg,
+		"todotxt": importTodoTxt,
+	}
+)
+
+// Output of list, search, show and done, picked with the global
+// --format: table for people, or one of the other registered renderers
+var outputFormat string
+
+func formatNames[V any](registry map[string]V) string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Writes tasks in the given format to path, or stdout for "" or "-"
+func exportTasks(tasks []Task, format, path string, fields []string) error {
+	export, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf(T("unknown export format %q (available: %s)"), format, formatNames(exporters))
+	}
+	for _, name := range fields {
+		if !fieldNameRe.MatchString(name) {
+			return fmt.Errorf(T("invalid field name %q"), name)
+		}
+	}
+	if path == "" || path == "-" {
+		return export(os.Stdout, tasks, fields)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := export(f, tasks, fields); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Splits a --fields list like "id, title,client"
+func splitFields(list string) []string {
+	var fields []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Flattens a task into named values, using the JSON store names for
+// built-in fields so new ones are exportable without extra wiring. Custom
+// fields sit alongside; a built-in wins if the names clash
+func taskColumns(task Task) map[string]any {
+	data, _ := json.Marshal(task)
+	var columns map[string]any
+	json.Unmarshal(data, &columns)
+	delete(columns, "fields")
+	for name, value := range task.Fields {
+		if _, ok := columns[name]; !ok {
+			columns[name] = value
+		}
+	}
+	return columns
+}
+
+// Default export columns: every built-in field in store order except the
+// internal hash and the fields map, then the custom fields in use
+func defaultColumns(tasks []Task) []string {
+	var columns []string
+	taskType := reflect.TypeFor[Task]()
+	for i := range taskType.NumField() {
+		name, _, _ := strings.Cut(taskType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "hash" && name != "fields" {
+			columns = append(columns, name)
+		}
+	}
+	custom := map[string]string{}
+	for _, task := range tasks {
+		for name := range task.Fields {
+			if !slices.Contains(columns, name) {
+				custom[name] = ""
+			}
+		}
+	}
+	return append(columns, sortedKeys(custom)...)
+}
+
+// A JSON array of tasks; with --fields, objects holding just those fields
+// in the order given
+func exportJSON(w io.Writer, tasks []Task, fields []string) error {
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	if fields == nil {
+		data, err := json.MarshalIndent(tasks, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	for i, task := range tasks {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n  {")
+		columns := taskColumns(task)
+		for j, name := range fields {
+			if j > 0 {
+				bw.WriteString(",")
+			}
+			key, _ := json.Marshal(name)
+			value, err := json.Marshal(columns[name])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "\n    %s: %s", key, value)
+		}
+		bw.WriteString("\n  }")
+	}
+	if len(tasks) > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// What an import does with a task whose UUID is already in the list
+var importStrategies = []string{"skip", "overwrite", "merge", "duplicate"}
+
+// One line of the import report
+type importEntry struct {
+	Action string `json:"action"` // created, updated or skipped
+	ID     int    `json:"id,omitempty"`
+	UUID   string `json:"uuid"`
+	Title  string `json:"title"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Machine-readable account of an import, so repeated imports can be
+// audited
+type importReport struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Skipped int           `json:"skipped"`
+	Tasks   []importEntry `json:"tasks"`
+}
+
+func (r *importReport) record(action string, task Task, reason string) {
+	switch action {
+	case "created":
+		r.Created++
+	case "updated":
+		r.Updated++
+	case "skipped":
+		r.Skipped++
+	}
+	r.Tasks = append(r.Tasks, importEntry{Action: action, ID: task.ID, UUID: task.UUID, Title: task.Title, Reason: reason})
+}
+
+// Writes the report as JSON to path, or stdout for "-"
+func writeImportReport(report importReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Folds an imported task into an existing one: values present in the
+// import win, attachments and fields are combined, and a task done on
+// either side stays done
+func mergeTask(existing, imported Task) Task {
+	merged := existing
+	merged.Completed = existing.Completed || imported.Completed
+	merged.Attachments = union(existing.Attachments, imported.Attachments)
+	merged.Tags = union(existing.Tags, imported.Tags)
+	if len(imported.Fields) > 0 {
+		fields := make(map[string]string, len(existing.Fields)+len(imported.Fields))
+		maps.Copy(fields, existing.Fields)
+		maps.Copy(fields, imported.Fields)
+		merged.Fields = fields
+	}
+	// Every other field is taken from the import when set there, so new
+	// fields merge without extra wiring
+	dst, src := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(imported)
+	for i := range dst.NumField() {
+		switch dst.Type().Field(i).Name {
+		case "ID", "UUID", "Hash", "Completed", "Attachments", "Tags", "Fields":
+			continue
+		}
+		if !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return merged
+}
+
+// Items of a followed by those of b it doesn't have
+func union(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, item := range b {
+		if !slices.Contains(merged, item) {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// Replaces an existing task with an imported one, keeping only its
+// identity in this list
+func overwriteTask(existing, imported Task) Task {
+	imported.ID = existing.ID
+	imported.Hash = existing.Hash
+	imported.UUID = existing.UUID
+	return imported
+}
+
+// Imports of more tasks than this are saved in batches of this size, each
+// followed by a checkpoint, so an interrupted import can be resumed
+const importBatch = 500
+
+// Where the progress of a batched import is kept until it finishes
+func importCheckpointPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".import.json"
+}
+
+// Progress of a batched import: which source it reads, identified by a
+// hash of its contents, and how many of its tasks are already in
+type importCheckpoint struct {
+	Source string       `json:"source"`
+	SHA256 string       `json:"sha256"`
+	Format string       `json:"format"`
+	Done   int          `json:"done"`
+	Total  int          `json:"total"`
+	Report importReport `json:"report"`
+}
+
+func loadImportCheckpoint(filename string) (*importCheckpoint, error) {
+	data, err := readSidecar(importCheckpointPath(filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c importCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", importCheckpointPath(filename), err)
+	}
+	return &c, nil
+}
+
+func saveImportCheckpoint(filename string, c importCheckpoint) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// It lists the titles imported so far, so it is sealed like the store
+	if data, err = sealSidecar(data, storeEncrypted(filename)); err != nil {
+		return err
+	}
+	return os.WriteFile(importCheckpointPath(filename), data, 0644)
+}
+
+// Redraws a progress line on stderr, only when it is a terminal
+type importProgress struct {
+	total, shown int
+	on           bool
+}
+
+func newImportProgress(total int) *importProgress {
+	return &importProgress{total: total, shown: -1, on: !accessible && term.IsTerminal(int(os.Stderr.Fd()))}
+}
+
+func (p *importProgress) update(done int) {
+	percent := done * 100 / max(p.total, 1)
+	if !p.on || percent == p.shown {
+		return
+	}
+	p.shown = percent
+	fmt.Fprintf(os.Stderr, "\r%s %3d%% %d/%d", progressBar(percent), percent, done, p.total)
+	if done == p.total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// Reads tasks in the given format from path ("-" for stdin) and adds them.
+// Tasks whose UUID is already in the list are handled by the strategy:
+// skipped, overwritten, merged or added again as a duplicate. Skip is the
+// default, so re-importing an export doesn't duplicate anything.
+//
+// Large imports are saved in batches with a checkpoint after each; resume
+// carries on after the last one, as long as the source hasn't changed
+func importTasks(tl *TodoList, filename, format, path, strategy string, resume bool) (importReport, error) {
+	var report importReport
+	parse, ok := importers[format]
+	if !ok {
+		return report, fmt.Errorf(T("unknown import format %q (available: %s)"), format, formatNames(importers))
+	}
+	if !slices.Contains(importStrategies, strategy) {
+		return report, fmt.Errorf(T("unknown import strategy %q (available: %s)"), strategy, strings.Join(importStrategies, ", "))
+	}
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return report, err
+	}
+	tasks, err := parse(bytes.NewReader(data))
+	if err != nil {
+		return report, err
+	}
+
+	sum := sha256.Sum256(data)
+	checkpoint := importCheckpoint{Source: path, SHA256: hex.EncodeToString(sum[:]), Format: format, Total: len(tasks)}
+	previous, err := loadImportCheckpoint(filename)
+	if err != nil {
+		return report, err
+	}
+	same := previous != nil && previous.SHA256 == checkpoint.SHA256 && previous.Format == format
+	switch {
+	case resume && previous == nil:
+		return report, errors.New(T("there is no interrupted import to resume"))
+	case resume && !same:
+		return report, fmt.Errorf(T("the interrupted import was of %s, and this file isn't the same; run it without --resume to start over"), previous.Source)
+	case resume:
+		checkpoint.Done, report = previous.Done, previous.Report
+	case same:
+		return report, fmt.Errorf(T("an import of this file stopped after %d of %d tasks; run it again with --resume to carry on, or remove %s to start over"), previous.Done, previous.Total, importCheckpointPath(filename))
+	}
+
+	known := make(map[string]int, len(tl.Tasks))
+	for i, task := range tl.Tasks {
+		known[task.UUID] = i
+	}
+	importOne := func(task Task) error {
+		task.Title = normalizeTitle(task.Title)
+		i, exists := known[task.UUID]
+		if task.UUID != "" && exists {
+			existing := tl.Tasks[i]
+			switch strategy {
+			case "skip":
+				report.record("skipped", existing, "already present")
+				return nil
+			case "overwrite", "merge":
+				updated := overwriteTask(existing, task)
+				if strategy == "merge" {
+					updated = mergeTask(existing, task)
+				}
+				if reflect.DeepEqual(updated, existing) {
+					report.record("skipped", existing, "unchanged")
+					return nil
+				}
+				tl.Tasks[i] = updated
+				report.record("updated", updated, strategy)
+				return nil
+			case "duplicate":
+				task.UUID = ""
+			}
+		}
+		if task.UUID == "" {
+			task.UUID = todo.NewUUID()
+		}
+		task.Hash = todo.NewHash()
+		added, err := tl.add(withRules(task))
+		if err != nil {
+			return err
+		}
+		known[added.UUID] = len(tl.Tasks) - 1
+		report.record("created", added, "")
+		return nil
+	}
+
+	// Small imports go in one transaction, so one undo takes them back
+	if len(tasks) <= importBatch && !resume {
+		err = tl.Transaction(filename, func() error {
+			for _, task := range tasks {
+				if err := importOne(task); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return importReport{}, err
+		}
+		return report, nil
+	}
+
+	progress := newImportProgress(len(tasks))
+	progress.update(checkpoint.Done)
+	for checkpoint.Done < len(tasks) {
+		batch := tasks[checkpoint.Done:min(checkpoint.Done+importBatch, len(tasks))]
+		before := report
+		err := tl.Transaction(filename, func() error {
+			for i, task := range batch {
+				if err := importOne(task); err != nil {
+					return err
+				}
+				progress.update(checkpoint.Done + i + 1)
+			}
+			return nil
+		})
+		if err != nil {
+			// The batch was rolled back, so resuming retries all of it
+			return before, err
+		}
+		checkpoint.Done += len(batch)
+		checkpoint.Report = report
+		if err := saveImportCheckpoint(filename, checkpoint); err != nil {
+			return report, err
+		}
+	}
+	os.Remove(importCheckpointPath(filename))
+	return report, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Custom field names are restricted so they survive every export format
+// (org property drawers in particular)
+var fieldNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A --where condition: the field must equal the value (case-insensitive);
+// "name=" matches tasks without the field
+type whereClause struct {
+	key, value string
+}
+
+func parseWhere(arg string) (whereClause, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok || !fieldNameRe.MatchString(key) {
+		return whereClause{}, fmt.Errorf(T("invalid condition %q (want name=value)"), arg)
+	}
+	return whereClause{key, strings.TrimSpace(value)}, nil
+}
+
+func (w whereClause) matches(task Task) bool {
+	return strings.EqualFold(task.Fields[w.key], w.value)
+}
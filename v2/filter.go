@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Filter flags shared by list, export, print and search, so they all select
+// tasks the same way
+type taskFilter struct {
+	where   []whereClause
+	context string
+	status  string // "", "open" or "done"
+	someday bool
+	overdue bool
+	// Open tasks due today, or due from today through dueBy (YYYY-MM-DD)
+	dueToday bool
+	dueBy    string
+	// Open tasks due before a day (YYYY-MM-DD), overdue ones included
+	dueBefore string
+	tags      []string
+	// Day (YYYY-MM-DD) from which on completed tasks are kept, "" for any
+	completedSince string
+}
+
+func (f *taskFilter) register(fs *flag.FlagSet) {
+	fs.Func("where", "only tasks whose field equals a value (name=value, repeatable)", func(arg string) error {
+		clause, err := parseWhere(arg)
+		f.where = append(f.where, clause)
+		return err
+	})
+	fs.StringVar(&f.context, "context", "", "only tasks in this context")
+	fs.Func("status", "only open or done tasks", func(arg string) error {
+		if arg != "open" && arg != "done" {
+			return fmt.Errorf(T("invalid status %q (want open or done)"), arg)
+		}
+		f.status = arg
+		return nil
+	})
+	fs.BoolFunc("open", "only open tasks, same as --status open", func(string) error {
+		f.status = "open"
+		return nil
+	})
+	fs.BoolFunc("done", "only done tasks, same as --status done", func(string) error {
+		f.status = "done"
+		return nil
+	})
+	fs.Func("completed-since", "only tasks completed on or after a day: 7d, monday, YYYY-MM-DD", func(arg string) error {
+		since, err := parseSince(arg, time.Now())
+		f.completedSince = since
+		return err
+	})
+	fs.BoolVar(&f.someday, "someday", false, "only the someday/maybe backlog")
+	fs.BoolVar(&f.overdue, "overdue", false, "only open tasks past their due date")
+	fs.BoolVar(&f.dueToday, "due-today", false, "only open tasks due today")
+	fs.Func("due-within", "only open tasks due from today through a span or day: 3d, 2w, friday", func(arg string) error {
+		by, err := parseDate(arg, time.Now())
+		f.dueBy = by
+		return err
+	})
+	fs.Func("tag", "only tasks with this tag (repeatable, all must match)", func(arg string) error {
+		tag, err := parseTag(arg)
+		f.tags = append(f.tags, tag)
+		return err
+	})
+}
+
+func (f *taskFilter) keep(task Task) bool {
+	if !inContext(task, normalizeContext(f.context)) {
+		return false
+	}
+	if (f.status == "open" && task.Completed) || (f.status == "done" && !task.Completed) {
+		return false
+	}
+	if f.completedSince != "" && (!task.Completed || task.CompletedAt < f.completedSince) {
+		return false
+	}
+	if f.someday && !task.Someday {
+		return false
+	}
+	if f.overdue && !overdue(task, time.Now()) {
+		return false
+	}
+	today := time.Now().Format(dateLayout)
+	if f.dueToday && (task.Completed || task.Due != today) {
+		return false
+	}
+	if f.dueBy != "" && (task.Completed || task.Due < today || task.Due > f.dueBy) {
+		return false
+	}
+	if f.dueBefore != "" && (task.Completed || task.Due == "" || task.Due >= f.dueBefore) {
+		return false
+	}
+	for _, tag := range f.tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+	for _, clause := range f.where {
+		if !clause.matches(task) {
+			return false
+		}
+	}
+	return true
+}
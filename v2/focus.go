@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Distraction-free view of a single task: the screen shows only that task
+// and a running timer, and single keys act on it
+func focusTask(tl *TodoList, filename string, id int) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New(T("focus needs an interactive terminal"))
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	start := time.Now()
+	message := ""
+	defer trackTimer(filename, "")
+	tracked := ""
+	for {
+		task, ok := tl.Find(id)
+		if !ok {
+			clearScreen()
+			return nil
+		}
+		if task.UUID != tracked {
+			trackTimer(filename, task.UUID)
+			tracked = task.UUID
+		}
+		drawFocus(task, time.Since(start), message)
+
+		select {
+		case <-ticker.C:
+			continue
+		case key, open := <-keys:
+			if !open {
+				clearScreen()
+				return nil
+			}
+			message = ""
+			switch key {
+			case 'q', 3, 27: // q, Ctrl-C, Esc
+				clearScreen()
+				return nil
+			case 'c':
+				// Without the usual confirmation line, which would garble the screen
+				err := tl.Transaction(filename, func() error {
+					_, _, err := tl.complete(id)
+					return err
+				})
+				if err != nil {
+					message = err.Error()
+					continue
+				}
+				message = fmt.Sprintf(T("Completed %q after %s"), task.Title, formatElapsed(time.Since(start)))
+			case 'd':
+				if err := tl.Transaction(filename, func() error { return tl.moveToEnd(id) }); err != nil {
+					message = err.Error()
+					continue
+				}
+				message = fmt.Sprintf(T("Deferred %q"), task.Title)
+			case 'n':
+			default:
+				continue
+			}
+			next, ok := tl.nextOpenAfter(id)
+			if !ok {
+				clearScreen()
+				fmt.Print(T("Nothing left to focus on.") + "\r\n")
+				return nil
+			}
+			id, start = next, time.Now()
+		}
+	}
+}
+
+func clearScreen() {
+	fmt.Print("\x1b[H\x1b[2J")
+}
+
+// Redraws the focus screen. The terminal is in raw mode, so lines end in
+// \r\n explicitly
+func drawFocus(task Task, elapsed time.Duration, message string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J\r\n")
+	fmt.Fprintf(&b, "  %s\r\n\r\n", task.Title)
+	for _, link := range task.Attachments {
+		fmt.Fprintf(&b, "    - %s\r\n", link)
+	}
+	for _, key := range sortedKeys(task.Fields) {
+		fmt.Fprintf(&b, "    %s: %s\r\n", key, task.Fields[key])
+	}
+	fmt.Fprintf(&b, "\r\n  %s\r\n\r\n", formatElapsed(elapsed))
+	b.WriteString("  " + T("[c] complete  [d] defer  [n] next  [q] quit") + "\r\n")
+	if message != "" {
+		fmt.Fprintf(&b, "\r\n  %s\r\n", message)
+	}
+	fmt.Print(b.String())
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// Moves a task to the end of the list, behind everything still to do
+func (tl *TodoList) moveToEnd(id int) error {
+	for i, task := range tl.Tasks {
+		if task.ID == id {
+			tl.Tasks = append(append(tl.Tasks[:i:i], tl.Tasks[i+1:]...), task)
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Finds the next open task after id in list order, wrapping around
+func (tl *TodoList) nextOpenAfter(id int) (int, bool) {
+	start := 0
+	for i, task := range tl.Tasks {
+		if task.ID == id {
+			start = i + 1
+		}
+	}
+	for n := 0; n < len(tl.Tasks); n++ {
+		task := tl.Tasks[(start+n)%len(tl.Tasks)]
+		if !task.Completed && task.ID != id {
+			return task.ID, true
+		}
+	}
+	return 0, false
+}
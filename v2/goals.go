@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Adds a goal and returns it
+func (tl *TodoList) AddGoal(title, by string) Goal {
+	id := 1
+	for _, goal := range tl.Goals {
+		id = max(id, goal.ID+1)
+	}
+	goal := Goal{ID: id, Title: normalizeTitle(title), By: by}
+	tl.Goals = append(tl.Goals, goal)
+	fmt.Printf(T("Added goal: %s (ID: %d)\n"), goal.Title, goal.ID)
+	return goal
+}
+
+// Removes a goal; tasks linked to it stay but lose the link
+func (tl *TodoList) DeleteGoal(id int) error {
+	for i, goal := range tl.Goals {
+		if goal.ID == id {
+			tl.Goals = append(tl.Goals[:i], tl.Goals[i+1:]...)
+			for j := range tl.Tasks {
+				if tl.Tasks[j].Goal == id {
+					tl.Tasks[j].Goal = 0
+				}
+			}
+			fmt.Printf(T("Deleted goal %d: %s\n"), id, goal.Title)
+			return nil
+		}
+	}
+	return fmt.Errorf(T("goal with ID %d not found"), id)
+}
+
+func (tl *TodoList) findGoal(id int) (Goal, bool) {
+	for _, goal := range tl.Goals {
+		if goal.ID == id {
+			return goal, true
+		}
+	}
+	return Goal{}, false
+}
+
+// Links a task to a goal, or unlinks it with goal 0
+func (tl *TodoList) LinkGoal(taskID, goalID int) error {
+	goal, ok := tl.findGoal(goalID)
+	if goalID != 0 && !ok {
+		return fmt.Errorf(T("goal with ID %d not found"), goalID)
+	}
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == taskID {
+			tl.Tasks[i].Goal = goalID
+			if goalID == 0 {
+				fmt.Printf(T("Unlinked task %d from its goal\n"), taskID)
+			} else {
+				fmt.Printf(T("Linked task %d to goal %d: %s\n"), taskID, goalID, goal.Title)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), taskID)
+}
+
+// Prints each goal with how many of its linked tasks are done
+func (tl *TodoList) PrintGoalStatus(now time.Time) {
+	if len(tl.Goals) == 0 {
+		fmt.Println(T("No goals yet. Add one with `todo goal add`."))
+		return
+	}
+	for _, goal := range tl.Goals {
+		done, total := 0, 0
+		for _, task := range tl.Tasks {
+			if task.Goal == goal.ID {
+				total++
+				if task.Completed {
+					done++
+				}
+			}
+		}
+		percent := 0
+		if total > 0 {
+			percent = done * 100 / total
+		}
+		line := fmt.Sprintf(T("%d %s  %s %d%% (%d/%d tasks)"), goal.ID, goal.Title, progressBar(percent), percent, done, total)
+		if goal.By != "" {
+			if by, err := time.ParseInLocation(dateLayout, goal.By, time.Local); err == nil {
+				days := int(by.Sub(now).Hours() / 24)
+				switch {
+				case done == total && total > 0:
+					line += fmt.Sprintf(T(", by %s"), goal.By)
+				case days < 0:
+					line += fmt.Sprintf(T(", overdue since %s"), goal.By)
+				default:
+					line += fmt.Sprintf(T(", %d days left until %s"), days, goal.By)
+				}
+			}
+		}
+		fmt.Println(line)
+	}
+}
+
+// Draws a ten-cell bar, in plain ASCII for screen readers and dumb
+// terminals
+func progressBar(percent int) string {
+	filled := percent / 10
+	if accessible || !utf8Terminal() {
+		return "[" + strings.Repeat("#", filled) + strings.Repeat(".", 10-filled) + "]"
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+}
+
+// Handles `todo goal add|link|unlink|delete|status`
+func goalCommand(filename string, args []string) error {
+	// todo.txt has no place for the goals themselves
+	if backend == "todotxt" {
+		return fmt.Errorf(T("goals need the json or sqlite backend, not %s"), backend)
+	}
+	sub := "status"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	tl := loadTodoList(filename)
+
+	switch sub {
+	case "status":
+		tl.PrintGoalStatus(time.Now())
+		return nil
+
+	case "add":
+		// The title comes first and may span several words
+		var words []string
+		for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			words, args = append(words, args[0]), args[1:]
+		}
+		goalCmd := flag.NewFlagSet("goal add", flag.ExitOnError)
+		by := goalCmd.String("by", "", "target date: YYYY-MM-DD, 3d or 2w")
+		goalCmd.Parse(args)
+		title := strings.Join(append(words, goalCmd.Args()...), " ")
+		if strings.TrimSpace(title) == "" {
+			return errors.New(T("usage: todo goal add <title> [--by YYYY-MM-DD]"))
+		}
+		date := ""
+		if *by != "" {
+			var err error
+			if date, err = parseDate(*by, time.Now()); err != nil {
+				return err
+			}
+		}
+		return tl.Transaction(filename, func() error {
+			tl.AddGoal(title, date)
+			return nil
+		})
+
+	case "link", "unlink":
+		if (sub == "link" && len(args) != 2) || (sub == "unlink" && len(args) != 1) {
+			return errors.New(T("usage: todo goal link <task-id|ref> <goal-id>, todo goal unlink <task-id|ref>"))
+		}
+		taskID, err := tl.Resolve(args[0])
+		if err != nil {
+			return err
+		}
+		goalID := 0
+		if sub == "link" {
+			if goalID, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf(T("invalid goal ID %q"), args[1])
+			}
+		}
+		return tl.Transaction(filename, func() error {
+			return tl.LinkGoal(taskID, goalID)
+		})
+
+	case "delete":
+		if len(args) != 1 {
+			return errors.New(T("usage: todo goal delete <goal-id>"))
+		}
+		goalID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf(T("invalid goal ID %q"), args[0])
+		}
+		return tl.Transaction(filename, func() error {
+			return tl.DeleteGoal(goalID)
+		})
+	}
+	return fmt.Errorf(T("unknown goal command %q"), sub)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A public holiday: its name and the day it falls on in a year
+type holidayRule struct {
+	name string
+	date func(year int) time.Time
+}
+
+// A holiday on the same day every year
+func fixedHoliday(name string, month time.Month, day int) holidayRule {
+	return holidayRule{name, func(year int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}}
+}
+
+// A holiday on the nth weekday of a month; n = -1 is the last one
+func weekdayHoliday(name string, month time.Month, weekday time.Weekday, n int) holidayRule {
+	return holidayRule{name, func(year int) time.Time {
+		if n < 0 {
+			last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+			return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7))
+		}
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1))
+	}}
+}
+
+// A holiday a number of days from Easter Sunday
+func easterHoliday(name string, offset int) holidayRule {
+	return holidayRule{name, func(year int) time.Time {
+		return easterSunday(year).AddDate(0, 0, offset)
+	}}
+}
+
+// Easter Sunday in the Gregorian calendar (the anonymous algorithm)
+func easterSunday(year int) time.Time {
+	a, b, c := year%19, year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Nationwide public holidays by country code. Days off in lieu of a
+// holiday on a weekend aren't included; add them in the config file
+var holidayCalendars = map[string][]holidayRule{
+	"us": {
+		fixedHoliday("New Year's Day", time.January, 1),
+		weekdayHoliday("Martin Luther King Jr. Day", time.January, time.Monday, 3),
+		weekdayHoliday("Presidents' Day", time.February, time.Monday, 3),
+		weekdayHoliday("Memorial Day", time.May, time.Monday, -1),
+		fixedHoliday("Juneteenth", time.June, 19),
+		fixedHoliday("Independence Day", time.July, 4),
+		weekdayHoliday("Labor Day", time.September, time.Monday, 1),
+		weekdayHoliday("Columbus Day", time.October, time.Monday, 2),
+		fixedHoliday("Veterans Day", time.November, 11),
+		weekdayHoliday("Thanksgiving", time.November, time.Thursday, 4),
+		fixedHoliday("Christmas Day", time.December, 25),
+	},
+	"gb": {
+		fixedHoliday("New Year's Day", time.January, 1),
+		easterHoliday("Good Friday", -2),
+		easterHoliday("Easter Monday", 1),
+		weekdayHoliday("Early May bank holiday", time.May, time.Monday, 1),
+		weekdayHoliday("Spring bank holiday", time.May, time.Monday, -1),
+		weekdayHoliday("Summer bank holiday", time.August, time.Monday, -1),
+		fixedHoliday("Christmas Day", time.December, 25),
+		fixedHoliday("Boxing Day", time.December, 26),
+	},
+	"de": {
+		fixedHoliday("Neujahr", time.January, 1),
+		easterHoliday("Karfreitag", -2),
+		easterHoliday("Ostermontag", 1),
+		fixedHoliday("Tag der Arbeit", time.May, 1),
+		easterHoliday("Christi Himmelfahrt", 39),
+		easterHoliday("Pfingstmontag", 50),
+		fixedHoliday("Tag der Deutschen Einheit", time.October, 3),
+		fixedHoliday("1. Weihnachtstag", time.December, 25),
+		fixedHoliday("2. Weihnachtstag", time.December, 26),
+	},
+	"fr": {
+		fixedHoliday("Jour de l'an", time.January, 1),
+		easterHoliday("Lundi de Pâques", 1),
+		fixedHoliday("Fête du Travail", time.May, 1),
+		fixedHoliday("Victoire 1945", time.May, 8),
+		easterHoliday("Ascension", 39),
+		easterHoliday("Lundi de Pentecôte", 50),
+		fixedHoliday("Fête nationale", time.July, 14),
+		fixedHoliday("Assomption", time.August, 15),
+		fixedHoliday("Toussaint", time.November, 1),
+		fixedHoliday("Armistice", time.November, 11),
+		fixedHoliday("Noël", time.December, 25),
+	},
+	"es": {
+		fixedHoliday("Año Nuevo", time.January, 1),
+		fixedHoliday("Epifanía del Señor", time.January, 6),
+		easterHoliday("Viernes Santo", -2),
+		fixedHoliday("Fiesta del Trabajo", time.May, 1),
+		fixedHoliday("Asunción de la Virgen", time.August, 15),
+		fixedHoliday("Fiesta Nacional de España", time.October, 12),
+		fixedHoliday("Todos los Santos", time.November, 1),
+		fixedHoliday("Día de la Constitución", time.December, 6),
+		fixedHoliday("Inmaculada Concepción", time.December, 8),
+		fixedHoliday("Navidad", time.December, 25),
+	},
+}
+
+// Other names the country codes go by
+var holidayAliases = map[string]string{"uk": "gb"}
+
+func holidayCalendar(code string) ([]holidayRule, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if alias, ok := holidayAliases[code]; ok {
+		code = alias
+	}
+	rules, ok := holidayCalendars[code]
+	return rules, ok
+}
+
+// A custom holiday from the config file: "MM-DD name" every year or
+// "YYYY-MM-DD name" once; the name is optional
+var customHolidayRe = regexp.MustCompile(`^(?:(\d{4})-)?(\d{2})-(\d{2})(?:\s+(.+))?$`)
+
+type customHoliday struct {
+	year  int // 0 for every year
+	month time.Month
+	day   int
+	name  string
+}
+
+func parseCustomHoliday(s string) (customHoliday, error) {
+	m := customHolidayRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return customHoliday{}, fmt.Errorf(T("invalid holiday %q (use MM-DD or YYYY-MM-DD, then a name)"), s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	// Checked in a leap year unless one is given, so 02-29 is allowed
+	check := time.Date(cmp.Or(year, 2000), time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if check.Month() != time.Month(month) || check.Day() != day {
+		return customHoliday{}, fmt.Errorf(T("invalid holiday %q (use MM-DD or YYYY-MM-DD, then a name)"), s)
+	}
+	name := m[4]
+	if name == "" {
+		name = T("Holiday")
+	}
+	return customHoliday{year, time.Month(month), day, name}, nil
+}
+
+// Holidays by day (YYYY-MM-DD), worked out a year at a time
+var holidayYears = map[int]map[string]string{}
+
+// The holidays of a year in the configured calendar and the custom ones
+func holidaysIn(year int) map[string]string {
+	if days, ok := holidayYears[year]; ok {
+		return days
+	}
+	days := map[string]string{}
+	rules, _ := holidayCalendar(cfg.HolidayCalendar)
+	for _, rule := range rules {
+		days[rule.date(year).Format(dateLayout)] = rule.name
+	}
+	for _, s := range cfg.Holidays {
+		h, err := parseCustomHoliday(s)
+		if err != nil || (h.year != 0 && h.year != year) {
+			continue
+		}
+		day := time.Date(year, h.month, h.day, 0, 0, 0, 0, time.UTC)
+		if day.Month() == h.month {
+			days[day.Format(dateLayout)] = h.name
+		}
+	}
+	holidayYears[year] = days
+	return days
+}
+
+// The holiday on a day, if there is one
+func holidayOn(day time.Time) (string, bool) {
+	name, ok := holidaysIn(day.Year())[day.Format(dateLayout)]
+	return name, ok
+}
+
+// Whether a day is a working day: a weekday that isn't a holiday
+func businessDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := holidayOn(day)
+	return !holiday
+}
+
+// Moves a date on by n working days
+func addBusinessDays(day time.Time, n int) time.Time {
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if businessDay(day) {
+			n--
+		}
+	}
+	return day
+}
+
+// Handles `todo holidays [--year 2026] [--country de]`: lists the holidays
+// that date math, recurrences and capacity planning skip
+func holidaysCommand(args []string) error {
+	holidaysCmd := flag.NewFlagSet("holidays", flag.ExitOnError)
+	year := holidaysCmd.Int("year", time.Now().Year(), "year to list")
+	country := holidaysCmd.String("country", "", "calendar to show instead of the configured one")
+	holidaysCmd.Parse(args)
+	if *country != "" {
+		if _, ok := holidayCalendar(*country); !ok {
+			return fmt.Errorf(T("unknown holiday calendar %q (available: %s)"), *country, strings.Join(holidayCalendarNames(), ", "))
+		}
+		cfg.HolidayCalendar, cfg.Holidays = *country, nil
+		clear(holidayYears)
+	}
+	if cfg.HolidayCalendar == "" && len(cfg.Holidays) == 0 {
+		return fmt.Errorf(T("no holidays configured; set holiday_calendar in the config file (available: %s)"), strings.Join(holidayCalendarNames(), ", "))
+	}
+	days := holidaysIn(*year)
+	dates := make([]string, 0, len(days))
+	for day := range days {
+		dates = append(dates, day)
+	}
+	slices.Sort(dates)
+	for _, day := range dates {
+		date, _ := time.Parse(dateLayout, day)
+		fmt.Printf("%s  %s  %s\n", day, date.Format("Mon"), days[day])
+	}
+	return nil
+}
+
+func holidayCalendarNames() []string {
+	names := make([]string, 0, len(holidayCalendars))
+	for name := range holidayCalendars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Checks the holiday settings of the config file
+func checkHolidays(c config) error {
+	if c.HolidayCalendar != "" {
+		if _, ok := holidayCalendar(c.HolidayCalendar); !ok {
+			return fmt.Errorf(T("unknown holiday calendar %q (available: %s)"), c.HolidayCalendar, strings.Join(holidayCalendarNames(), ", "))
+		}
+	}
+	var errs []error
+	for _, s := range c.Holidays {
+		if _, err := parseCustomHoliday(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
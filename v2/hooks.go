@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory holding hook executables; empty disables hooks. Every
+// executable named after an event (on-add, on-add.notify, ...) runs for
+// that event, in lexical order
+var hooksDir string
+
+// How long a single hook may run before it's treated as failed
+const hookTimeout = 30 * time.Second
+
+// Default hook location: $TODO_HOOKS_DIR, else ~/.config/todo/hooks
+func defaultHooksDir() string {
+	if dir := cmp.Or(os.Getenv("TODO_HOOKS_DIR"), cfg.HooksDir); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "hooks")
+}
+
+// Lists the executables registered for event
+func findHooks(event string) []string {
+	if hooksDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil {
+		return nil
+	}
+	var hooks []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != event && !strings.HasPrefix(name, event+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		hooks = append(hooks, filepath.Join(hooksDir, name))
+	}
+	sort.Strings(hooks)
+	return hooks
+}
+
+// Runs the hooks for event with task as JSON on stdin. A hook vetoes the
+// operation by exiting non-zero, and may modify the task by printing the
+// changed JSON object; any other output is shown to the user. Identity
+// fields can't be changed by a hook
+func runTaskHook(event string, task Task) (Task, error) {
+	for _, hook := range findHooks(event) {
+		input, err := json.Marshal(task)
+		if err != nil {
+			return task, err
+		}
+		output, err := runHook(hook, event, input)
+		if err != nil {
+			return task, err
+		}
+		if output == nil {
+			continue
+		}
+		changed := task
+		if err := json.Unmarshal(output, &changed); err != nil {
+			return task, fmt.Errorf(T("hook %s printed invalid JSON: %v"), filepath.Base(hook), err)
+		}
+		changed.ID, changed.UUID, changed.Hash = task.ID, task.UUID, task.Hash
+		changed.Title = normalizeTitle(changed.Title)
+		task = changed
+	}
+	return task, nil
+}
+
+// Runs the pre-save hooks with the whole list on stdin. Like task hooks,
+// they may veto the save or print a replacement {"tasks": [...]} document
+func runSaveHook(tl *TodoList) error {
+	for _, hook := range findHooks("pre-save") {
+		input, err := json.Marshal(tl)
+		if err != nil {
+			return err
+		}
+		output, err := runHook(hook, "pre-save", input)
+		if err != nil {
+			return err
+		}
+		if output == nil {
+			continue
+		}
+		var changed TodoList
+		if err := json.Unmarshal(output, &changed); err != nil {
+			return fmt.Errorf(T("hook %s printed invalid JSON: %v"), filepath.Base(hook), err)
+		}
+		tl.Tasks = changed.Tasks
+	}
+	return nil
+}
+
+// Executes one hook. Returns its stdout if it is a JSON object to apply,
+// or nil after passing any other output through to the user
+func runHook(hook, event string, input []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, hook)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "TODO_HOOK="+event)
+
+	start := time.Now()
+	err := cmd.Run()
+	slog.Debug("ran hook", "hook", hook, "event", event, "took", time.Since(start), "err", err)
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if err != nil {
+		if len(output) > 0 {
+			return nil, fmt.Errorf(T("hook %s rejected %s: %s"), filepath.Base(hook), event, output)
+		}
+		return nil, fmt.Errorf(T("hook %s rejected %s: %v"), filepath.Base(hook), event, err)
+	}
+	if bytes.HasPrefix(output, []byte("{")) {
+		return output, nil
+	}
+	if len(output) > 0 {
+		fmt.Println(string(output))
+	}
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Translations live in locales/<lang>.json, mapping each English message
+// (used verbatim as the key) to its translation. Contributing a language
+// means adding one file; anything missing from it falls back to English.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Messages for the active locale; nil means English
+var catalog map[string]string
+
+// Picks the locale from the environment and loads its catalog
+func initLocale() {
+	for _, lang := range localeCandidates(detectLocale()) {
+		data, err := localeFiles.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			continue
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			slog.Warn("ignoring broken translation catalog", "locale", lang, "err", err)
+			continue
+		}
+		catalog = messages
+		return
+	}
+}
+
+// Follows the usual POSIX precedence, with TODO_LANG as an override that
+// only affects this program
+func detectLocale() string {
+	for _, name := range []string{"TODO_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Turns "pt_BR.UTF-8@euro" into ["pt_BR", "pt"] so a regional catalog wins
+// over the generic one
+func localeCandidates(locale string) []string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	candidates := []string{locale}
+	if i := strings.IndexAny(locale, "_-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	return candidates
+}
+
+// T returns the translation of an English message for the active locale
+func T(message string) string {
+	if translated, ok := catalog[message]; ok && translated != "" {
+		return translated
+	}
+	return message
+}
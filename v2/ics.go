@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Text values escaped for iCalendar
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// Writes one content line, folded at 75 octets as RFC 5545 asks, without
+// splitting a UTF-8 character
+func writeICSLine(w io.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(w, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		// Continuation lines start with a space, so they hold one less
+		limit = 74
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+func writeICSHeader(w io.Writer, product, name string) {
+	writeICSLine(w, "BEGIN:VCALENDAR")
+	writeICSLine(w, "VERSION:2.0")
+	writeICSLine(w, "PRODID:-//go-todo-cli//"+product+"//EN")
+	writeICSLine(w, "X-WR-CALNAME:"+icsEscape.Replace(name))
+}
+
+// An all-day event on a task's due date, repeating like the task does.
+// The UID is the task's, so calendars subscribed to a feed keep track of it
+func writeICSEvent(w io.Writer, task Task, summary string, due, now time.Time) {
+	writeICSLine(w, "BEGIN:VEVENT")
+	writeICSLine(w, "UID:"+task.UUID)
+	writeICSLine(w, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+	writeICSLine(w, "DTSTART;VALUE=DATE:"+due.Format("20060102"))
+	writeICSLine(w, "DTEND;VALUE=DATE:"+due.AddDate(0, 0, 1).Format("20060102"))
+	writeICSLine(w, "SUMMARY:"+icsEscape.Replace(summary))
+	if rrule := icsRRule(task.Repeat, due); rrule != "" {
+		writeICSLine(w, "RRULE:"+rrule)
+	}
+	writeICSLine(w, "END:VEVENT")
+}
+
+// Priorities as iCalendar has them: 1 is the highest, 9 the lowest
+var icsPriorities = map[string]int{"high": 1, "medium": 5, "low": 9}
+
+// The task itself, for apps that keep to-dos, such as Apple Reminders
+func writeICSTodo(w io.Writer, task Task, due, now time.Time) {
+	writeICSLine(w, "BEGIN:VTODO")
+	writeICSLine(w, "UID:"+task.UUID+"-todo")
+	writeICSLine(w, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+	if created, err := time.Parse(time.RFC3339, task.CreatedAt); err == nil {
+		writeICSLine(w, "CREATED:"+created.UTC().Format("20060102T150405Z"))
+	}
+	writeICSLine(w, "SUMMARY:"+icsEscape.Replace(task.Title))
+	if task.Notes != "" {
+		writeICSLine(w, "DESCRIPTION:"+icsEscape.Replace(task.Notes))
+	}
+	writeICSLine(w, "DUE;VALUE=DATE:"+due.Format("20060102"))
+	if priority, ok := icsPriorities[task.Priority]; ok {
+		writeICSLine(w, "PRIORITY:"+strconv.Itoa(priority))
+	}
+	if len(task.Tags) > 0 {
+		tags := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			tags[i] = icsEscape.Replace(tag)
+		}
+		writeICSLine(w, "CATEGORIES:"+strings.Join(tags, ","))
+	}
+	if task.Completed {
+		writeICSLine(w, "STATUS:COMPLETED")
+		if done, err := time.Parse(time.RFC3339, task.CompletedAt); err == nil {
+			writeICSLine(w, "COMPLETED:"+done.UTC().Format("20060102T150405Z"))
+		}
+	} else {
+		writeICSLine(w, "STATUS:NEEDS-ACTION")
+		if rrule := icsRRule(task.Repeat, due); rrule != "" {
+			writeICSLine(w, "RRULE:"+rrule)
+		}
+	}
+	writeICSLine(w, "END:VTODO")
+}
+
+// A recurrence rule as an RRULE, "" for none. Months keep to the due
+// date's day, or the month's last day when it is shorter, as the next
+// occurrence does here. Holidays aren't skipped, and every N working
+// days for N above 1 has no RRULE, so it is left out
+func icsRRule(rule string, due time.Time) string {
+	if named, ok := repeatNames[rule]; ok {
+		rule = named
+	}
+	m := repeatRe.FindStringSubmatch(rule)
+	if m == nil {
+		return ""
+	}
+	n, _ := strconv.Atoi(m[1])
+	interval := ""
+	if n > 1 {
+		interval = ";INTERVAL=" + m[1]
+	}
+	switch m[2] {
+	case "b":
+		if n > 1 {
+			return ""
+		}
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+	case "d":
+		return "FREQ=DAILY" + interval
+	case "w":
+		return "FREQ=WEEKLY" + interval
+	case "m":
+		if day := due.Day(); day > 28 {
+			days := make([]string, 0, day-27)
+			for d := 28; d <= day; d++ {
+				days = append(days, strconv.Itoa(d))
+			}
+			return "FREQ=MONTHLY" + interval + ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+		}
+		return "FREQ=MONTHLY" + interval
+	}
+	return "FREQ=YEARLY" + interval
+}
+
+// Writes tasks with a due date as an iCalendar file to import or
+// subscribe to: an all-day event for each open task, for calendars, and a
+// to-do for each task, for apps that keep those. The layout is fixed, so
+// --fields doesn't apply
+func exportICS(w io.Writer, tasks []Task, fields []string) error {
+	if fields != nil {
+		return errors.New(T("the ics format doesn't support --fields; use csv or json"))
+	}
+	bw := bufio.NewWriter(w)
+	now := time.Now()
+	writeICSHeader(bw, "todo export", "todo")
+	for _, task := range tasks {
+		due, err := time.Parse(dateLayout, task.Due)
+		if err != nil {
+			continue
+		}
+		if !task.Completed {
+			writeICSEvent(bw, task, task.Title, due, now)
+		}
+		writeICSTodo(bw, task, due, now)
+	}
+	writeICSLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// Quick-add captures go to an append-only inbox next to the store instead of
+// the store itself, so capturing never reads or rewrites todo.json. The
+// inbox is merged into the list the next time a command loads it.
+func inboxPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".inbox"
+}
+
+// Appends one task to the inbox as a single line. A single O_APPEND write
+// keeps concurrent captures from interleaving
+func captureToInbox(filename, title string) error {
+	line, err := inboxLine(Task{UUID: todo.NewUUID(), Hash: todo.NewHash(), Title: normalizeTitle(title)}, storeEncrypted(filename))
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(inboxPath(filename), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// An inbox entry: the task as a JSON line, or for an encrypted store sealed
+// and base64-encoded, so it still takes one line
+func inboxLine(task Task, encrypt bool) ([]byte, error) {
+	line, err := json.Marshal(task)
+	if err != nil || !encrypt {
+		return line, err
+	}
+	sealed, err := sealSidecar(line, true)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Reads an inbox entry; ok is false for one that is torn or damaged. An
+// error means a sealed entry couldn't be opened for want of the passphrase
+func parseInboxLine(line []byte) (task Task, ok bool, err error) {
+	if !bytes.HasPrefix(line, []byte("{")) {
+		sealed, decodeErr := base64.StdEncoding.DecodeString(string(line))
+		if decodeErr != nil || !todo.IsEncrypted(sealed) {
+			return task, false, nil
+		}
+		key, err := readPassphrase(false)
+		if err != nil {
+			return task, false, err
+		}
+		if line, err = todo.Decrypt(sealed, key); err != nil {
+			return task, false, nil
+		}
+	}
+	if json.Unmarshal(line, &task) != nil || task.Title == "" {
+		return task, false, nil
+	}
+	return task, true, nil
+}
+
+// Rewrites an inbox with its entries sealed, or opened, for todo encrypt
+// and todo decrypt
+func rewriteInbox(path string, encrypt bool) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var out []byte
+	for _, entry := range bytes.Split(data, []byte("\n")) {
+		task, ok, err := parseInboxLine(entry)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		line, err := inboxLine(task, encrypt)
+		if err != nil {
+			return err
+		}
+		out = append(append(out, line...), '\n')
+	}
+	if err := os.WriteFile(path+".tmp", out, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Moves captured tasks from the inbox into the list and saves it. The inbox
+// is renamed before reading so captures made meanwhile start a fresh file,
+// and entries whose UUID is already in the list are skipped in case an
+// earlier merge saved but didn't get to remove the inbox. Captures go
+// through the on-add hooks; one a hook rejects is dropped with a warning
+func (tl *TodoList) MergeInbox(filename string) (int, error) {
+	draining := inboxPath(filename) + ".merging"
+	// A leftover from a merge that failed to save is finished first; the
+	// current inbox waits for the next run rather than overwriting it
+	if _, err := os.Stat(draining); os.IsNotExist(err) {
+		if err := os.Rename(inboxPath(filename), draining); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	f, err := os.Open(draining)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	known := make(map[string]bool, len(tl.Tasks))
+	for _, task := range tl.Tasks {
+		known[task.UUID] = true
+	}
+
+	var captured []Task
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		task, ok, err := parseInboxLine(scanner.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			// A torn final line from an interrupted capture; skip it
+			slog.Warn("skipping unreadable inbox entry", "file", draining)
+			continue
+		}
+		if known[task.UUID] {
+			continue
+		}
+		known[task.UUID] = true
+		captured = append(captured, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(captured) > 0 {
+		err := tl.Transaction(filename, func() error {
+			for _, task := range captured {
+				if _, err := tl.add(withRules(task)); err != nil {
+					fmt.Printf(T("Dropped captured task %q: %v\n"), task.Title, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		slog.Info("merged inbox", "file", filename, "tasks", len(captured))
+	}
+	f.Close()
+	return len(captured), os.Remove(draining)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Local-only usage statistics, kept next to the store and never sent
+// anywhere. Set TODO_INSIGHTS=off to stop recording
+type usageStats struct {
+	Since      time.Time                `json:"since"`
+	Commands   map[string]*commandStats `json:"commands"`
+	StoreBytes int64                    `json:"store_bytes"`
+}
+
+// How often a command ran and how long it took
+type commandStats struct {
+	Count   int           `json:"count"`
+	Total   time.Duration `json:"total_ns"`
+	Slowest time.Duration `json:"slowest_ns"`
+}
+
+func statsPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".stats.json"
+}
+
+func loadUsageStats(filename string) (*usageStats, error) {
+	stats := &usageStats{Since: time.Now(), Commands: map[string]*commandStats{}}
+	data, err := os.ReadFile(statsPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, err
+	}
+	if stats.Commands == nil {
+		stats.Commands = map[string]*commandStats{}
+	}
+	return stats, nil
+}
+
+// Adds one run of command to the stats file. Failures are only logged;
+// insights must never get in the way of the command itself
+func recordUsage(filename, command string, took time.Duration) {
+	if strings.EqualFold(os.Getenv("TODO_INSIGHTS"), "off") {
+		return
+	}
+	stats, err := loadUsageStats(filename)
+	if err != nil {
+		slog.Warn("could not read usage stats", "err", err)
+		return
+	}
+	cs := stats.Commands[command]
+	if cs == nil {
+		cs = &commandStats{}
+		stats.Commands[command] = cs
+	}
+	cs.Count++
+	cs.Total += took
+	cs.Slowest = max(cs.Slowest, took)
+	if info, err := os.Stat(filename); err == nil {
+		stats.StoreBytes = info.Size()
+	}
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err == nil {
+		err = os.WriteFile(statsPath(filename), data, 0644)
+	}
+	if err != nil {
+		slog.Warn("could not write usage stats", "err", err)
+	}
+}
+
+// Prints the recorded usage, busiest commands first
+func printInsights(filename string, tl *TodoList) error {
+	stats, err := loadUsageStats(filename)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(stats.Commands))
+	runs := 0
+	for name, cs := range stats.Commands {
+		names = append(names, name)
+		runs += cs.Count
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := stats.Commands[names[i]], stats.Commands[names[j]]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Printf(T("Usage since %s (%d runs)\n"), stats.Since.Format("2006-01-02"), runs)
+	if len(names) > 0 {
+		fmt.Println("")
+		fmt.Printf("%-12s %6s %10s %10s\n", T("Command"), T("Runs"), T("Average"), T("Slowest"))
+		for _, name := range names {
+			cs := stats.Commands[name]
+			avg := cs.Total / time.Duration(cs.Count)
+			fmt.Printf("%-12s %6d %10s %10s\n", name, cs.Count, roundDuration(avg), roundDuration(cs.Slowest))
+		}
+	}
+	fmt.Println("")
+	fmt.Printf(T("Store: %s, %d tasks, %d bytes\n"), filename, len(tl.Tasks), stats.StoreBytes)
+	return nil
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Looks up a secret stored in the OS keychain: the login keychain on macOS
+// (security), the Secret Service on Linux and BSD (secret-tool)
+func keychainSecret(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", fmt.Errorf(T("no keychain support on %s"), runtime.GOOS)
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(T("keychain lookup for %s/%s failed: %v"), service, account, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Tasks have a stable URI, todo://<list>/<uuid>, for linking to them from
+// other tasks, notes apps and notifications. The list is the store's file
+// name without its extension, so the default store's tasks are todo://todo/…
+var taskURIRe = regexp.MustCompile(`todo://([A-Za-z0-9._-]+)/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+
+func listName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+func taskURI(filename string, task Task) string {
+	return "todo://" + listName(filename) + "/" + task.UUID
+}
+
+// Splits a task URI into its list and UUID
+func parseTaskURI(uri string) (list, uuid string, err error) {
+	m := taskURIRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(uri)))
+	if m == nil || m[0] != strings.ToLower(strings.TrimSpace(uri)) {
+		return "", "", fmt.Errorf(T("invalid task URI %q (expected todo://<list>/<uuid>)"), uri)
+	}
+	return m[1], m[2], nil
+}
+
+// Tasks of this list that a task links to from its title or fields
+func (tl *TodoList) linkedTasks(filename string, task Task) []Task {
+	texts := []string{task.Title}
+	for _, key := range sortedKeys(task.Fields) {
+		texts = append(texts, task.Fields[key])
+	}
+	var linked []Task
+	seen := map[string]bool{task.UUID: true}
+	for _, text := range texts {
+		for _, m := range taskURIRe.FindAllStringSubmatch(strings.ToLower(text), -1) {
+			if m[1] != strings.ToLower(listName(filename)) || seen[m[2]] {
+				continue
+			}
+			seen[m[2]] = true
+			for _, t := range tl.Tasks {
+				if t.UUID == m[2] {
+					linked = append(linked, t)
+				}
+			}
+		}
+	}
+	return linked
+}
+
+// Handles `todo open-ref <uri> [--tui]`: shows the linked task, or opens
+// the interactive list on it
+func openRefCommand(filename string, args []string) error {
+	openCmd := flag.NewFlagSet("open-ref", flag.ExitOnError)
+	tui := openCmd.Bool("tui", false, "open the task in the interactive list")
+	rest := parseInterspersed(openCmd, args)
+	if len(rest) != 1 {
+		return errors.New(T("usage: todo open-ref <todo://list/uuid> [--tui]"))
+	}
+	list, uuid, err := parseTaskURI(rest[0])
+	if err != nil {
+		return err
+	}
+	if list != strings.ToLower(listName(filename)) {
+		return fmt.Errorf(T("%s is in list %q, not %q; pick its store with --file"), rest[0], list, listName(filename))
+	}
+	tl := loadTodoList(filename)
+	id, err := tl.Resolve(uuid)
+	if err != nil {
+		if archive, aerr := loadArchive(filename); aerr == nil {
+			if archivedID, aerr := archive.Resolve(uuid); aerr == nil {
+				return fmt.Errorf(T("the task is archived as %d; bring it back with todo restore %d"), archivedID, archivedID)
+			}
+		}
+		return err
+	}
+	if *tui {
+		return runTUI(tl, filename, id)
+	}
+	return tl.ShowTask(id, filename)
+}
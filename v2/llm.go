@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Something that can answer a chat prompt; commands only depend on this,
+// so other backends can be plugged in next to the OpenAI-compatible one
+type llmBackend interface {
+	chat(system, user string) (string, error)
+	name() string
+}
+
+// Client for any OpenAI-compatible chat completions endpoint. Configured
+// with TODO_LLM_URL (default the OpenAI API), TODO_LLM_MODEL and the key
+// from TODO_LLM_API_KEY or the keychain entry service "todo-cli",
+// account "llm"
+type llmClient struct {
+	baseURL string
+	model   string
+	apiKey  string
+	http    *http.Client
+}
+
+func newLLMClient() *llmClient {
+	c := &llmClient{
+		baseURL: strings.TrimSuffix(os.Getenv("TODO_LLM_URL"), "/"),
+		model:   os.Getenv("TODO_LLM_MODEL"),
+		apiKey:  os.Getenv("TODO_LLM_API_KEY"),
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+	if c.baseURL == "" {
+		c.baseURL = "https://api.openai.com/v1"
+	}
+	if c.model == "" {
+		c.model = "gpt-4o-mini"
+	}
+	if c.apiKey == "" {
+		key, err := keychainSecret("todo-cli", "llm")
+		if err != nil {
+			// Local servers (llama.cpp, Ollama) usually need no key
+			slog.Info("no LLM API key", "err", err)
+		}
+		c.apiKey = key
+	}
+	return c
+}
+
+func (c *llmClient) name() string {
+	return c.model
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Sends one system+user exchange and returns the model's reply
+func (c *llmClient) chat(system, user string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": c.model,
+		"messages": []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		"temperature": 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	slog.Debug("llm request", "url", req.URL.String(), "model", c.model, "status", resp.Status, "took", time.Since(start))
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(T("LLM endpoint returned %s: %s"), resp.Status, strings.TrimSpace(string(data)))
+	}
+	var reply struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return "", err
+	}
+	if len(reply.Choices) == 0 {
+		return "", errors.New(T("LLM endpoint returned no answer"))
+	}
+	return reply.Choices[0].Message.Content, nil
+}
+
+// Decodes the JSON object in a model reply into v, tolerating the prose or
+// code fences models like to wrap it in
+func decodeLLMJSON(reply string, v any) error {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf(T("LLM reply contained no JSON: %s"), reply)
+	}
+	return json.Unmarshal([]byte(reply[start:end+1]), v)
+}
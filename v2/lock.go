@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long a command waits for another one to be done with the store
+const lockTimeout = 10 * time.Second
+
+// Commands that run for a long time or until stopped. They don't hold the
+// lock throughout, only while saving, so other commands aren't shut out;
+// each save rereads the store first, keeping what those commands changed
+var unlockedCommands = map[string]bool{"tui": true, "bot": true, "focus": true, "open-ref": true, "dashboard": true, "serve": true, "token": true, "sandbox": true, "store": true}
+
+// The lock file next to the store; it is never removed, since removing a
+// lock file others may have open defeats the lock
+func lockPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".lock"
+}
+
+// Whether this process holds the store's lock
+var storeLocked bool
+
+// Takes the store's advisory lock, so concurrent commands read, change and
+// save the store one after the other instead of overwriting each other's
+// changes. Waits up to lockTimeout for the holder; the returned function
+// releases the lock. Taking it again while held is a no-op
+func lockStore(filename string) (func(), error) {
+	if storeLocked {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(lockPath(filename), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf(T("%s is in use by another todo command (lock file %s); try again"), filename, lockPath(filename))
+		}
+		slog.Debug("waiting for store lock", "file", lockPath(filename))
+		time.Sleep(50 * time.Millisecond)
+	}
+	storeLocked = true
+	return func() {
+		unlockFile(f)
+		f.Close()
+		storeLocked = false
+	}, nil
+}
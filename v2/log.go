@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Configures the default slog logger from the global flags. Only warnings
+// and errors are shown unless --verbose or --debug is given; --log-file
+// sends the log to a file (appending) instead of stderr. The returned
+// function closes the log file, if any
+func setupLogging(verbose, debug bool, logFile string) (func(), error) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	var out io.Writer = os.Stderr
+	closeLog := func() {}
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return closeLog, err
+		}
+		out = f
+		closeLog = func() { f.Close() }
+	}
+
+	handler := slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(handler))
+	return closeLog, nil
+}
@@ -1,222 +1,1462 @@
 package main
 
 import (
-	"encoding/json"
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
 )
 
-// Represents a todo item
-type Task struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
-}
+// The data model lives in the library so other programs can share it
+type (
+	Task = todo.Task
+	Goal = todo.Goal
+)
 
-// Manages a list of tasks
+// Manages a list of tasks; the CLI layer adds hooks, output and
+// translations on top of the library's list
 type TodoList struct {
-	Tasks  []Task `json:"tasks"`
-	nextID int
+	todo.List
+	// Loaded from the offline copy, so it can't be saved
+	readOnly bool
 }
 
 // Adds a new task to the list
-func (tl *TodoList) AddTask(title string) {
-	task := Task{
-		ID:        tl.nextID,
-		Title:     title,
-		Completed: false,
+func (tl *TodoList) AddTask(title string) error {
+	return tl.AddTaskFrom(Task{Title: title})
+}
+
+// Adds a new task built from the given fields
+func (tl *TodoList) AddTaskFrom(task Task) error {
+	task.Title = normalizeTitle(task.Title)
+	task, err := tl.add(withRules(task))
+	if err != nil {
+		return err
+	}
+	fmt.Printf(T("Added task: %s (ID: %d)\n"), task.Title, task.ID)
+	return nil
+}
+
+// Runs the on-add hooks for a new task and inserts it unless vetoed. A
+// task without a UUID or hash is given them first, so hooks see them;
+// ones it comes with, e.g. from an import or sync, are kept
+func (tl *TodoList) add(task Task) (Task, error) {
+	task.ID = tl.NextID()
+	if task.UUID == "" {
+		task.UUID = todo.NewUUID()
+	}
+	if task.Hash == "" {
+		task.Hash = todo.NewHash()
 	}
-	tl.Tasks = append(tl.Tasks, task)
-	tl.nextID++
-	fmt.Printf("Added task: %s (ID: %d)\n", title, task.ID)
+	task, err := runTaskHook("on-add", task)
+	if err != nil {
+		return task, err
+	}
+	return tl.List.Add(task), nil
 }
 
 // Prints all tasks in the list
 func (tl *TodoList) ListTasks() {
-	if len(tl.Tasks) == 0 {
-		fmt.Println("No tasks found.")
+	tl.PrintTasks(tl.Tasks)
+}
+
+// Prints the given tasks as a table; refs stay unique across the whole list
+func (tl *TodoList) PrintTasks(tasks []Task) {
+	tl.writeTasks(os.Stdout, tasks, nil)
+}
+
+// Prints tasks as a tree, subtasks indented under their parents
+func (tl *TodoList) PrintTree(tasks []Task) {
+	ordered, depth := treeOrder(tasks)
+	tl.writeTasks(os.Stdout, ordered, depth)
+}
+
+// Writes tasks as a table, indenting titles by depth (nil for a flat list)
+func (tl *TodoList) writeTasks(w io.Writer, tasks []Task, depth map[int]int) {
+	if len(tasks) == 0 {
+		fmt.Fprintln(w, T("No tasks found."))
 		return
 	}
 
-	fmt.Println("ID | Status | Task")
-	fmt.Println("----------------------")
-	for _, task := range tl.Tasks {
+	refs := tl.ShortRefs()
+	now := time.Now()
+	if accessible {
+		// One labeled line per task: no table layout or symbols to decode
+		for _, task := range tasks {
+			status := T("open")
+			if task.Completed {
+				status = T("done")
+			} else if task.Waiting {
+				status = T("waiting")
+			} else if task.Someday {
+				status = T("someday")
+			}
+			if pinnedOpen(task) {
+				status += ", " + T("pinned")
+			}
+			line := fmt.Sprintf(T("Task %d, ref %s, status: %s, title: %s"), task.ID, refs[task.ID], status, task.Title)
+			if parent, ok := tl.parentOf(task); ok {
+				line += ", " + fmt.Sprintf(T("subtask of task %d"), parent.ID)
+			}
+			_, labels := taskDetails(task, now)
+			for _, label := range labels {
+				line += ", " + label
+			}
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+
+	refWidth := minRefLen
+	for _, task := range tasks {
+		refWidth = max(refWidth, len(refs[task.ID]))
+	}
+
+	fmt.Fprintf(w, T("ID | %-*s | Status | Task\n"), refWidth, T("Ref"))
+	fmt.Fprintln(w, "----------------------"+strings.Repeat("-", refWidth+3))
+	mark := doneMark()
+	for _, task := range tasks {
 		status := " "
 		if task.Completed {
-			status = "✓"
+			status = mark
+		}
+		title := task.Title
+		if task.Priority == "high" && !task.Completed {
+			title = colorize(title, "1;31")
+		}
+		title = treeIndent(depth[task.ID]) + pinPrefix(task) + title
+		if marks, _ := taskDetails(task, now); len(marks) > 0 {
+			title += "  " + strings.Join(marks, "  ")
+		}
+		fmt.Fprintf(w, "%2d | %s | %s | %s\n", task.ID, padRight(refs[task.ID], refWidth), padRight("["+status+"]", 6), title)
+	}
+}
+
+// Details listed after a task's title: compact marks for the table and
+// labeled phrases for accessible output
+func taskDetails(task Task, now time.Time) (marks, labels []string) {
+	if task.Priority != "" {
+		marks = append(marks, fmt.Sprintf(T("%s priority"), task.Priority))
+		labels = append(labels, fmt.Sprintf(T("priority: %s"), task.Priority))
+	}
+	if task.Due != "" && !task.Completed {
+		if overdue(task, now) {
+			marks = append(marks, "!"+fmt.Sprintf(T("overdue since %s"), task.Due))
+			labels = append(labels, fmt.Sprintf(T("overdue since %s"), task.Due))
+		} else {
+			marks = append(marks, fmt.Sprintf(T("due %s"), task.Due))
+			labels = append(labels, fmt.Sprintf(T("due: %s"), task.Due))
+		}
+	}
+	if task.Repeat != "" && !task.Completed {
+		marks = append(marks, fmt.Sprintf(T("repeats %s"), task.Repeat))
+		labels = append(labels, fmt.Sprintf(T("repeats: %s"), task.Repeat))
+	}
+	if len(task.Tags) > 0 {
+		marks = append(marks, formatTags(task.Tags))
+		labels = append(labels, fmt.Sprintf(T("tags: %s"), strings.Join(task.Tags, ", ")))
+	}
+	if task.Context != "" {
+		marks = append(marks, task.Context)
+		labels = append(labels, fmt.Sprintf(T("context: %s"), task.Context))
+	}
+	if task.Effort != "" {
+		marks = append(marks, "("+task.Effort+")")
+		labels = append(labels, fmt.Sprintf(T("effort: %s"), task.Effort))
+	}
+	if task.Waiting && !task.Completed {
+		marks = append(marks, "["+waitingNote(task)+"]")
+		if task.WaitingOn != "" || task.FollowUp != "" {
+			labels = append(labels, waitingNote(task))
+		}
+	}
+	if task.Completed {
+		if age := formatAge(task.CompletedAt, now); age != "" {
+			marks = append(marks, fmt.Sprintf(T("done %s ago"), age))
+			labels = append(labels, fmt.Sprintf(T("completed %s ago"), age))
 		}
-		fmt.Printf("%2d | [%s]    | %s\n", task.ID, status, task.Title)
+	} else if age := formatAge(task.CreatedAt, now); age != "" {
+		marks = append(marks, fmt.Sprintf(T("%s ago"), age))
+		labels = append(labels, fmt.Sprintf(T("created %s ago"), age))
 	}
+	return marks, labels
 }
 
 // Marks a task as completed
 func (tl *TodoList) CompleteTask(id int) error {
-	for i, task := range tl.Tasks {
-		if task.ID == id {
-			tl.Tasks[i].Completed = true
-			fmt.Printf("Marked task %d as completed: %s\n", id, task.Title)
-			return nil
-		}
+	task, next, err := tl.complete(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(T("Marked task %d as completed: %s\n"), id, task.Title)
+	if next.ID != 0 {
+		fmt.Printf(T("Next occurrence: task %d, due %s\n"), next.ID, next.Due)
+	}
+	return nil
+}
+
+// Marks a completed task as open again, for a mistaken complete
+func (tl *TodoList) ReopenTask(id int) error {
+	task, err := tl.reopen(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(T("Reopened task %d: %s\n"), id, task.Title)
+	return nil
+}
+
+// Clears the completed flag and time. A recurring task's next occurrence
+// stays; delete it if it is no longer wanted
+func (tl *TodoList) reopen(id int) (Task, error) {
+	task, ok := tl.Find(id)
+	if !ok {
+		return Task{}, fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	if !task.Completed {
+		return task, fmt.Errorf(T("task %d is not completed"), id)
+	}
+	task.Completed, task.CompletedAt = false, ""
+	return task, tl.Update(task)
+}
+
+// Runs the on-complete hooks and marks the task done unless vetoed. A
+// recurring task gets its next occurrence added, which is returned as
+// next; otherwise next is the zero Task
+func (tl *TodoList) complete(id int) (task, next Task, err error) {
+	task, ok := tl.Find(id)
+	if !ok {
+		return Task{}, Task{}, fmt.Errorf(T("task with ID %d not found"), id)
 	}
-	return fmt.Errorf("task with ID %d not found", id)
+	if err := tl.checkSubtasksDone(task); err != nil {
+		return task, Task{}, err
+	}
+	task.Completed = true
+	task.CompletedAt = time.Now().Format(time.RFC3339)
+	completed, err := runTaskHook("on-complete", task)
+	if err != nil {
+		return task, Task{}, err
+	}
+	if err := tl.Update(completed); err != nil {
+		return completed, Task{}, err
+	}
+	if completed.Repeat != "" {
+		next, err = tl.addNextOccurrence(completed, time.Now())
+	}
+	return completed, next, err
 }
 
 // Removes a task from the list
 func (tl *TodoList) DeleteTask(id int) error {
-	for i, task := range tl.Tasks {
-		if task.ID == id {
-			// Remove the task by slicing it out
-			tl.Tasks = append(tl.Tasks[:i], tl.Tasks[i+1:]...)
-			fmt.Printf("Deleted task %d: %s\n", id, task.Title)
-			return nil
-		}
+	task, ok := tl.Find(id)
+	if !ok {
+		return fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	if _, err := runTaskHook("on-delete", task); err != nil {
+		return err
+	}
+	if _, err := tl.List.DeleteTask(id); err != nil {
+		return err
+	}
+	fmt.Printf(T("Deleted task %d: %s\n"), id, task.Title)
+	if moved := tl.adoptChildren(task); moved > 0 {
+		fmt.Printf(T("Moved %d subtasks of task %d up a level\n"), moved, id)
 	}
-	return fmt.Errorf("task with ID %d not found", id)
+	return nil
 }
 
-// SaveToFile saves the todo list to a JSON file
-func (tl *TodoList) SaveToFile(filename string) error {
-	data, err := json.MarshalIndent(tl, "", "  ")
+// Transaction applies a batch of mutations and persists them with a single
+// save. If fn or the save fails, the list is rolled back to its state before
+// the call so callers never observe a partially applied batch. Saved
+// batches are journaled so `todo undo` can reverse them
+func (tl *TodoList) Transaction(filename string, fn func() error) error {
+	backup, err := tl.commit(filename, fn)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(filename, data, 0644)
+	recordUndo(filename, backup, &tl.List)
+	return nil
 }
 
-// Loads the todo list from a JSON file
-func (tl *TodoList) LoadFromFile(filename string) error {
-	data, err := ioutil.ReadFile(filename)
+// Applies and saves a batch like Transaction, returning the list as it was
+// before, but leaves the undo journal alone
+func (tl *TodoList) commit(filename string, fn func() error) (*todo.List, error) {
+	if tl.readOnly {
+		return nil, errors.New(T("the store can't be read, and the offline copy is read-only"))
+	}
+	held := storeLocked
+	unlock, err := lockStore(filename)
 	if err != nil {
-		// If the file doesn't exist, start with an empty list
-		if os.IsNotExist(err) {
-			tl.Tasks = []Task{}
-			tl.nextID = 1
-			return nil
+		return nil, err
+	}
+	defer unlock()
+	// Long-running commands only lock the store to save, so it may have
+	// changed since they loaded it; apply fn to it as it is now
+	if !held {
+		if err := tl.LoadFromFile(filename); err != nil {
+			return nil, err
 		}
-		return err
 	}
+	backup := tl.List.Clone()
 
-	err = json.Unmarshal(data, tl)
+	err = fn()
+	if err == nil {
+		err = runSaveHook(tl)
+	}
+	if err == nil {
+		tl.StampRevisions(backup, time.Now())
+	}
+	if err == nil {
+		err = tl.SaveToFile(filename)
+	}
 	if err != nil {
-		return err
+		slog.Info("transaction rolled back", "file", filename, "err", err)
+		tl.List = *backup
+		return nil, err
 	}
+	return backup, nil
+}
 
-	// Find the highest ID to set nextID correctly
-	maxID := 0
-	for _, task := range tl.Tasks {
-		if task.ID > maxID {
-			maxID = task.ID
+// Storage backend, "json" (todo.json), "sqlite" (todo.db) or "todotxt"
+// (todo.txt)
+var backend string
+
+// Stores opened so far, by file; the SQLite store remembers what it loaded
+// so a later save only writes the changes
+var stores = map[string]todo.Store{}
+
+// Returns the store for filename in the selected backend
+func openStore(filename string) (todo.Store, error) {
+	if store, ok := stores[filename]; ok {
+		return store, nil
+	}
+	var store todo.Store
+	switch backend {
+	case "json":
+		store = todo.FileStore{Path: filename, Recovered: func(err error) {
+			fmt.Fprintf(os.Stderr, T("Warning: %v\nRecovered the tasks from %s; the damaged file is kept as %s\n"), err, filename+".bak", filename+".corrupt")
+		}, Passphrase: storePassphrase(filename)}
+	case "sqlite":
+		if _, err := os.Stat(sqlitePath(filename)); os.IsNotExist(err) {
+			if err := migrateToSQLite(filename); err != nil {
+				return nil, err
+			}
+		}
+		db, err := openSQLiteStore(sqlitePath(filename))
+		if err != nil {
+			return nil, err
 		}
+		store = db
+	case "todotxt":
+		store = todoTxtStore{path: todoTxtPath(filename)}
+	default:
+		return nil, fmt.Errorf(T("unknown backend %q (use json, sqlite or todotxt)"), backend)
+	}
+	stores[filename] = store
+	return store, nil
+}
+
+// Saves the todo list to the store
+func (tl *TodoList) SaveToFile(filename string) error {
+	if tl.readOnly {
+		return errors.New(T("the store can't be read, and the offline copy is read-only"))
+	}
+	start := time.Now()
+	store, err := openStore(filename)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(&tl.List); err != nil {
+		return err
 	}
-	tl.nextID = maxID + 1
+	refreshStoreCache(filename, &tl.List)
+	slog.Debug("saved store", "file", filename, "backend", backend, "tasks", len(tl.Tasks), "took", time.Since(start))
+	return nil
+}
 
+// Loads the todo list from the store
+func (tl *TodoList) LoadFromFile(filename string) error {
+	start := time.Now()
+	store, err := openStore(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filename); backend == "json" && os.IsNotExist(err) {
+		// A missing directory is more likely an unmounted drive than a new
+		// store
+		if _, err := os.Stat(filepath.Dir(filename)); err != nil {
+			return err
+		}
+		slog.Info("store not found, starting empty", "file", filename)
+	}
+	list, err := store.Load()
+	if err != nil {
+		return err
+	}
+	tl.List = *list
+	refreshStoreCache(filename, list)
+	slog.Debug("loaded store", "file", filename, "backend", backend, "tasks", len(tl.Tasks), "took", time.Since(start))
 	return nil
 }
 
 func printUsage() {
-	fmt.Println("Todo CLI - A simple task manager")
+	fmt.Println(T("Todo CLI - A simple task manager"))
+	fmt.Println("")
+	fmt.Println(T("Usage:"))
+	fmt.Println(T("  todo [options] [command] [arguments]"))
 	fmt.Println("")
-	fmt.Println("Usage:")
-	fmt.Println("  todo [command] [arguments]")
+	fmt.Println(T("Options:"))
+	fmt.Println(T("  --accessible              Plain labeled output for screen readers"))
+	fmt.Println(T("  --verbose                 Log what the program is doing"))
+	fmt.Println(T("  --debug                   Log detailed diagnostics"))
+	fmt.Println(T("  --log-file <path>         Write the log to a file instead of stderr"))
+	fmt.Println(T("  --backend json|sqlite|todotxt"))
+	fmt.Println(T("                            Where tasks are kept (default $TODO_BACKEND or json);"))
+	fmt.Println(T("                            sqlite moves an existing todo.json into todo.db,"))
+	fmt.Println(T("                            todotxt keeps them in todo.txt for other todo.txt apps"))
+	fmt.Println(T("  --file <path>             Task store to use (default $TODO_FILE, then file: in"))
+	fmt.Println(T("                            the config file, then ./todo.json if it exists,"))
+	fmt.Println(T("                            else $XDG_DATA_HOME/todo/todo.json)"))
+	fmt.Println(T("  --format table|json|csv   Output of list, search, show and done; json and"))
+	fmt.Println(T("                            csv are for scripts, e.g. piped into jq; also"))
+	fmt.Println(T("                            markdown, cell and template:{{.ID}} {{.Title}}"))
 	fmt.Println("")
-	fmt.Println("Commands:")
-	fmt.Println("  add <task description>    Add a new task")
-	fmt.Println("  list                      List all tasks")
-	fmt.Println("  complete <task-id>        Mark a task as completed")
-	fmt.Println("  delete <task-id>          Delete a task")
+	fmt.Println(T("Commands:"))
+	fmt.Println(T("  add <task description>    Add a new task"))
+	fmt.Println(T("  add --url <url> [title]   Add a web page, titled after the page"))
+	fmt.Println(T("  add --context @home ...   Add a task that can only be done in a context"))
+	fmt.Println(T("  add --effort quick ...    Add a task with an effort (quick, medium, deep)"))
+	fmt.Println(T("  add --goal <goal-id> ...  Add a task towards a goal"))
+	fmt.Println(T("  add --due <date> ...      Add a task with a due date (tomorrow, in 3 days)"))
+	fmt.Println(T("  add --priority high ...   Add a task with a priority (high, medium, low)"))
+	fmt.Println(T("  add --tag home ...        Add a task with tags (repeatable)"))
+	fmt.Println(T("  add --parent <task> ...   Add a subtask; list shows subtasks as a tree"))
+	fmt.Println(T("  add --repeat weekly ...   Add a recurring task (daily, weekly, monthly,"))
+	fmt.Println(T("                            yearly, workdays, every:3d); done adds the next"))
+	fmt.Println(T("                            one, skipping holidays"))
+	fmt.Println(T("  add --note \"text\" ...     Add a task with notes, a longer description"))
+	fmt.Println(T("  upcoming [--count 3]      Show the next due dates of recurring tasks"))
+	fmt.Println(T("  recurrence preview <task|rule> [--count 10]"))
+	fmt.Println(T("                            List the due dates a repeat rule will produce"))
+	fmt.Println(T("  add --audio <file>        Add a task transcribed from a voice memo"))
+	fmt.Println(T("  q <task description>      Quickly capture a task to the inbox"))
+	fmt.Println(T("  list [--where name=value] List all tasks, or those with matching fields"))
+	fmt.Println(T("                            (filtered to the active context; --any-context)"))
+	fmt.Println(T("  list --someday            List the someday/maybe backlog"))
+	fmt.Println(T("  list --status open|done   List only open or only done tasks"))
+	fmt.Println(T("  list --overdue            List open tasks past their due date"))
+	fmt.Println(T("  list --due-today          List open tasks due today"))
+	fmt.Println(T("  list --due-within 3d      List open tasks due in the next days (or by a day)"))
+	fmt.Println(T("  list --completed-since 7d List tasks completed in the last week"))
+	fmt.Println(T("  list --sort priority      List the most urgent tasks first"))
+	fmt.Println(T("  list --sort due --reverse Sort by id, title, due, priority or created"))
+	fmt.Println(T("  list --tag finance        List tasks with a tag (repeatable)"))
+	fmt.Println(T("  search <query> [--regex]  Find tasks by title, filtered like list (--open)"))
+	fmt.Println(T("  next [--effort quick]     List open tasks you can do now, ordered by any"))
+	fmt.Println(T("                            suggest hooks (e.g. weather deferring #outdoor)"))
+	fmt.Println(T("                            and, on days full of meetings, quick tasks first"))
+	fmt.Println(T("  show <task-id|ref>        Show all details of a task, with its todo:// link"))
+	fmt.Println(T("  open-ref <todo://...>     Show a linked task (--tui opens it in the tui)"))
+	fmt.Println(T("  scan [path]               Track TODO(owner): code comments as tasks"))
+	fmt.Println(T("  attach <task> <file>...   Copy files into the attachment store (next to the"))
+	fmt.Println(T("                            store, or $TODO_ATTACHMENTS_DIR) and attach them"))
+	fmt.Println(T("  attachments gc            Delete stored files no task refers to (--dry-run)"))
+	fmt.Println(T("  complete <task-id|ref>    Mark a task as completed (--cascade <task> also"))
+	fmt.Println(T("                            completes its open subtasks)"))
+	fmt.Println(T("  complete 3 5 9, 2-7       complete and delete take several IDs and ranges;"))
+	fmt.Println(T("  complete --all --tag x    --all acts on every task the filter flags match"))
+	fmt.Println(T("  reopen <task-id|ref>      Mark a completed task as open again (uncomplete)"))
+	fmt.Println(T("  id-strategy [name]        Show or set how new tasks get IDs: increase (past"))
+	fmt.Println(T("                            the highest), reuse (lowest free) or sequence (never"))
+	fmt.Println(T("                            reused). Kept in the store; id_strategy in the"))
+	fmt.Println(T("                            config file sets it for new stores"))
+	fmt.Println(T("  archive                   Move completed tasks to todo.archive.json"))
+	fmt.Println(T("  encrypt                   Keep the store encrypted with a passphrase"))
+	fmt.Println(T("                            ($TODO_PASSPHRASE, the keychain or asked for)"))
+	fmt.Println(T("  decrypt                   Turn the store back into plain JSON"))
+	fmt.Println(T("  store status [target...]  Check the backend and sync targets answer, and how"))
+	fmt.Println(T("                            fast; offline_cache: true keeps a read-only copy"))
+	fmt.Println(T("                            for when the store can't be reached"))
+	fmt.Println(T("  list --archived           Browse the archive, filtered like list"))
+	fmt.Println(T("  restore <archived-task>   Move an archived task back, with its subtasks"))
+	fmt.Println(T("  done [--since yesterday]  List tasks completed since a day (default today);"))
+	fmt.Println(T("                            --format standup groups them by project and adds"))
+	fmt.Println(T("                            what is due today, for pasting into standups"))
+	fmt.Println(T("  delete <task-id|ref>      Delete a task; its subtasks move up a level, or"))
+	fmt.Println(T("                            go too with --cascade <task>"))
+	fmt.Println(T("  undo                      Reverse the last change (redo reapplies it)"))
+	fmt.Println(T("  sandbox [--hooks]         Try commands on a copy of the store, then commit"))
+	fmt.Println(T("                            or discard; hooks stay off without --hooks"))
+	fmt.Println(T("  tui                       Browse, tick off, add, edit and filter tasks"))
+	fmt.Println(T("                            in a full-screen interactive list"))
+	fmt.Println(T("  focus <task-id|ref>       Work on one task with a timer, distraction-free"))
+	fmt.Println(T("  dashboard [--refresh 30s] Read-only full-screen overview for a spare"))
+	fmt.Println(T("                            monitor: counts, due today, timers, completions"))
+	fmt.Println(T("  set <task-id|ref> k=v...  Set fields: title, notes, priority, due, context,"))
+	fmt.Println(T("                            effort, repeat or custom ones (empty value clears);"))
+	fmt.Println(T("                            tag+=x, tag-=x"))
+	fmt.Println(T("  set --filter <terms> ...  Make the same changes to every matching task, e.g."))
+	fmt.Println(T("                            --filter \"tag:old status:open\" (--dry-run, --yes)"))
+	fmt.Println(T("  due <task-id|ref> <date>  Set or clear (\"\") a task's due date"))
+	fmt.Println(T("  prioritize <task> <level> Set or clear (\"\") a task's priority"))
+	fmt.Println(T("  tag <task-id|ref> <tag>.. Add tags to a task (untag removes them)"))
+	fmt.Println(T("  effort <task-id|ref> lvl  Set how much effort a task takes"))
+	fmt.Println(T("  waiting [<task-id|ref>]   List waiting tasks, or park one (--on who,"))
+	fmt.Println(T("                            --follow-up 3d; --clear to resume)"))
+	fmt.Println(T("  someday <task-id|ref>     Park a task in someday/maybe (--clear to promote)"))
+	fmt.Println(T("  pin [<task-id|ref>...]    Keep tasks at the top of list and today views"))
+	fmt.Println(T("                            (--clear to unpin; lists pinned tasks without one)"))
+	fmt.Println(T("  review                    Promote or drop someday/maybe tasks"))
+	fmt.Println(T("  goal add <title> [--by d] Add a goal, optionally with a target date"))
+	fmt.Println(T("  goal link <task> <goal>   Link a task to a goal (unlink <task> to undo)"))
+	fmt.Println(T("  goal [status]             Show progress towards each goal"))
+	fmt.Println(T("  goal delete <goal-id>     Delete a goal, keeping its tasks"))
+	fmt.Println(T("  rules [list]              List the tagging rules ($TODO_RULES_FILE or"))
+	fmt.Println(T("                            ~/.config/todo/rules.json) applied to new tasks"))
+	fmt.Println(T("  rules test \"<title>\"      Show which rules would fire for a title"))
+	fmt.Println(T("  template [list]           List task templates ($TODO_TEMPLATES_DIR or"))
+	fmt.Println(T("                            ~/.config/todo/templates/<name>.json)"))
+	fmt.Println(T("  template apply <name>     Add a template's tasks; --set Param=value fills"))
+	fmt.Println(T("                            in a parameter, the others are asked for"))
+	fmt.Println(T("  context [set @x|clear]    Show, set or clear the active context"))
+	fmt.Println(T("  context list              List contexts in use"))
+	fmt.Println(T("  context assign <id> @x    Put a task in a context"))
+	fmt.Println(T("  edit <task> [title]       Change a task's title or, with --due, --priority,"))
+	fmt.Println(T("                            --context, --effort, --goal, --parent, --repeat, --tag,"))
+	fmt.Println(T("                            --untag, its details"))
+	fmt.Println(T("  edit --all [filter]       Edit matching tasks in $EDITOR"))
+	fmt.Println(T("  note <task> [text]        Set a task's notes, or edit them in $EDITOR"))
+	fmt.Println(T("  export [-o file]          Export tasks (--format org|csv|json|todotxt|ics),"))
+	fmt.Println(T("                            filtered like list, --fields id,title,... to pick"))
+	fmt.Println(T("                            columns"))
+	fmt.Println(T("  import <file>             Import tasks (--format org|todotxt)"))
+	fmt.Println(T("                            (--strategy skip|overwrite|merge|duplicate,"))
+	fmt.Println(T("                            --report file for a JSON report, --resume to"))
+	fmt.Println(T("                            carry on after an interrupted large import)"))
+	fmt.Println(T("  print [--today] [-o file] Print a checkbox sheet of open tasks (--format"))
+	fmt.Println(T("                            text|pdf), filtered like list"))
+	fmt.Println(T("  qr [task] [--invert]      Show a task, or open tasks filtered like list, as a"))
+	fmt.Println(T("                            QR code to scan with a phone (-o file.png)"))
+	fmt.Println(T("  sync [--dry-run] <target> Sync both ways with another store; --direction"))
+	fmt.Println(T("                            pull|push|both, --remote-owns due,... and"))
+	fmt.Println(T("                            --local-owns tags,... are remembered per target"))
+	fmt.Println(T("                            --resolve settles tasks changed on both sides"))
+	fmt.Println(T("                            field by field on a full screen, otherwise the"))
+	fmt.Println(T("                            latest change wins. Targets: a path, a todo serve"))
+	fmt.Println(T("                            (https://host:8080, $TODO_SYNC_TOKEN), WebDAV"))
+	fmt.Println(T("                            (davs://host/todo.json), S3 (s3://bucket/key) or"))
+	fmt.Println(T("                            todoist (token in todoist_token in the config)"))
+	fmt.Println(T("  bot telegram              Take commands and send reminders over Telegram"))
+	fmt.Println(T("  calendar import [<url>]   Import busy time from an .ics calendar (default"))
+	fmt.Println(T("                            $TODO_CALENDAR_URL); add and due warn about full days"))
+	fmt.Println(T("  calendar [--days 7]       Show meetings, tasks due and free hours per day"))
+	fmt.Println(T("  holidays [--year 2026]    List the holidays of holiday_calendar in the config"))
+	fmt.Println(T("                            file; due dates like 3bd count working days"))
+	fmt.Println(T("  serve [--port 8080]       Serve a JSON REST API (GET/POST/PATCH/DELETE /tasks);"))
+	fmt.Println(T("                            and /feed.ics; needs TODO_SERVE_TOKEN or a token"))
+	fmt.Println(T("  token create [--list l,...] [--write] [--fields f,...] [--expires 30d]"))
+	fmt.Println(T("                            Make a scoped token for serve and its feed"))
+	fmt.Println(T("  token list | revoke <id>  Show tokens, or revoke one"))
+	fmt.Println(T("  breakdown <task-id|ref>   Ask an LLM to propose subtasks"))
+	fmt.Println(T("  do <request>              Describe changes in plain language (via an LLM)"))
+	fmt.Println(T("  run <script.star> [args]  Run a Starlark script against the tasks"))
+	fmt.Println(T("  insights                  Show local usage statistics"))
+	fmt.Println(T("  version [--check]         Show the version, or check for updates"))
+	fmt.Println(T("  self-update               Update to the latest signed release"))
 	fmt.Println("")
-	fmt.Println("Examples:")
+	fmt.Println(T("Examples:"))
 	fmt.Println("  todo add \"Buy groceries\"")
 	fmt.Println("  todo list")
 	fmt.Println("  todo complete 2")
 	fmt.Println("  todo delete 3")
+	fmt.Println("  todo complete a3f")
+	fmt.Println("  todo done --since yesterday --format standup")
+	fmt.Println("  todo set 7 client=ACME sprint=14")
+	fmt.Println("  todo set --filter \"tag:oldproj\" tag+=newproj tag-=oldproj priority=low")
+	fmt.Println("  todo list --where client=ACME")
+	fmt.Println("  todo add --due \"next friday\" Send invoices")
+	fmt.Println("  todo add \"pay rent\" --tag finance --tag home")
+	fmt.Println("  todo context set @home")
+	fmt.Println("  todo next --effort quick")
+	fmt.Println("  todo waiting 4 --on \"Bob's reply\" --follow-up 3d")
+	fmt.Println("  todo goal add \"Run a 10k\" --by 2025-06-01")
+	fmt.Println("  todo template apply onboarding --set ClientName=ACME --set DueOffset=5")
+	fmt.Println("  todo export --format csv --status open --context @work --fields id,title,due")
+}
+
+// Parses flags wherever they appear, so `add "pay rent" --tag home` works
+// like `add --tag home "pay rent"`; arguments after -- are never flags
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if consumed := args[:len(args)-len(rest)]; len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
 }
 
+// Loads the store on first use, so commands that don't touch tasks (help,
+// usage) never pay for reading and parsing it
+func loadTodoList(filename string) *TodoList {
+	todoList, err := openTodoList(filename)
+	if err != nil {
+		if cached, ok := cachedTodoList(filename, err); ok {
+			return cached
+		}
+		fmt.Printf(T("Error loading tasks: %v\n"), err)
+		// Carrying on would save over the tasks with the wrong passphrase,
+		// or start an empty database next to them
+		if errors.Is(err, todo.ErrPassphrase) || errors.Is(err, encryptedMigrationError{}) {
+			os.Exit(1)
+		}
+	}
+	return todoList
+}
+
+// Loads the list like loadTodoList, but returns the error instead of
+// carrying on with an empty list
+func openTodoList(filename string) (*TodoList, error) {
+	todoList := &TodoList{}
+	if err := todoList.LoadFromFile(filename); err != nil {
+		return todoList, err
+	}
+	if _, err := todoList.MergeInbox(filename); err != nil {
+		fmt.Printf(T("Error merging inbox: %v\n"), err)
+	}
+	// The config picks the strategy for stores without one; a store keeps
+	// the one it recorded, so machines sharing it agree
+	if todoList.IDStrategy == "" {
+		todoList.IDStrategy = cfg.IDStrategy
+	} else if cfg.IDStrategy != "" && cfg.IDStrategy != todoList.IDStrategy {
+		slog.Info("store keeps its own ID strategy", "store", todoList.IDStrategy, "config", cfg.IDStrategy)
+	}
+	return todoList, nil
+}
+
+// Replaces tables and symbols with plain labeled text, for screen readers
+var accessible bool
+
 func main() {
-	// Define command-line flags
-	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
-	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
-	completeCmd := flag.NewFlagSet("complete", flag.ExitOnError)
-	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
-
-	// Set up todo list and data file
-	todoList := TodoList{}
-	filename := "todo.json"
-
-	// Load existing tasks from file
-	err := todoList.LoadFromFile(filename)
+	initLocale()
+	registerRenderers()
+
+	var err error
+	if cfg, err = loadConfig(configPath()); err != nil {
+		fmt.Printf(T("Error: %v\n"), err)
+		os.Exit(1)
+	}
+
+	// Global options come before the command; the config file supplies
+	// their defaults
+	flag.BoolVar(&accessible, "accessible", os.Getenv("TODO_ACCESSIBLE") != "" || cfg.Accessible, "plain labeled output for screen readers")
+	verbose := flag.Bool("verbose", false, "log what the program is doing")
+	debug := flag.Bool("debug", false, "log detailed diagnostics")
+	logFile := flag.String("log-file", "", "write the log to a file instead of stderr")
+	flag.StringVar(&backend, "backend", cmp.Or(os.Getenv("TODO_BACKEND"), cfg.Backend, "json"), "storage backend: json, sqlite or todotxt")
+	flag.StringVar(&outputFormat, "format", cmp.Or(cfg.Format, "table"), "output of list, search, show and done: table, json, csv, markdown, cell or template:<text>")
+	file := flag.String("file", "", "task store to use instead of the default")
+	flag.Usage = printUsage
+	flag.Parse()
+	args := flag.Args()
+
+	closeLog, err := setupLogging(*verbose, *debug, *logFile)
 	if err != nil {
-		fmt.Printf("Error loading tasks: %v\n", err)
+		fmt.Printf(T("Error: %v\n"), err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	if backend != "json" && backend != "sqlite" && backend != "todotxt" {
+		fmt.Printf(T("Error: %v\n"), fmt.Errorf(T("unknown backend %q (use json, sqlite or todotxt)"), backend))
+		os.Exit(1)
+	}
+	if err := checkOutputFormat(outputFormat); err != nil {
+		fmt.Printf(T("Error: %v\n"), err)
+		os.Exit(1)
 	}
 
+	hooksDir = defaultHooksDir()
+
 	// Check if a command was provided
-	if len(os.Args) < 2 {
+	if len(args) < 1 {
 		printUsage()
 		return
 	}
 
-	// Handle commands
-	switch os.Args[1] {
+	filename, err := storeFile(*file)
+	if err != nil {
+		fmt.Printf(T("Error: %v\n"), err)
+		os.Exit(1)
+	}
+	slog.Debug("using store", "file", filename)
+	if !unlockedCommands[args[0]] {
+		// A store whose directory is gone can't be locked; with an offline
+		// copy, commands carry on unlocked to read it, and saves are refused
+		unlock, err := lockStore(filename)
+		if err == nil {
+			defer unlock()
+		} else if _, dirErr := os.Stat(filepath.Dir(backendPath(filename))); !cfg.OfflineCache || dirErr == nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+	}
+
+	// Record the run in the local usage stats once the command is done
+	start := time.Now()
+	record := args[0] != "help" && args[0] != "insights"
+	defer func() {
+		if record {
+			recordUsage(filename, args[0], time.Since(start))
+		}
+	}()
+
+	// Handle commands; each one sets up only the flags and state it needs
+	switch args[0] {
+	case "q":
+		// Quick capture: never loads the store, just appends to the inbox
+		title := strings.Join(args[1:], " ")
+		if strings.TrimSpace(title) == "" {
+			fmt.Println(T("Error: Task description required"))
+			os.Exit(1)
+		}
+		if err := captureToInbox(filename, title); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		fmt.Printf(T("Captured: %s\n"), title)
+
 	case "add":
-		addCmd.Parse(os.Args[2:])
-		if addCmd.NArg() < 1 {
-			fmt.Println("Error: Task description required")
-			return
+		addCmd := flag.NewFlagSet("add", flag.ExitOnError)
+		link := addCmd.String("url", "", "capture a web page, titled after the page")
+		audio := addCmd.String("audio", "", "voice memo to transcribe into the task title")
+		context := addCmd.String("context", "", "context the task can be done in, e.g. @home")
+		effort := addCmd.String("effort", "", "effort the task takes: quick, medium or deep")
+		goal := addCmd.Int("goal", 0, "ID of the goal the task works towards")
+		due := addCmd.String("due", "", "due date: YYYY-MM-DD, tomorrow, next friday, in 3 days")
+		priority := addCmd.String("priority", "", "priority: high, medium or low")
+		parent := addCmd.String("parent", "", "ID or ref of the task this is a subtask of")
+		repeat := addCmd.String("repeat", "", "recurrence: daily, weekly, monthly, yearly, workdays or every:3d")
+		note := addCmd.String("note", "", "longer description; todo note edits it later")
+		var tags []string
+		addCmd.Func("tag", "tag the task (repeatable)", func(arg string) error {
+			tags = append(tags, arg)
+			return nil
+		})
+		title := parseInterspersed(addCmd, args[1:])
+		level, err := parseEffort(*effort)
+		if err == nil {
+			*priority, err = parsePriority(*priority)
+		}
+		if err == nil {
+			tags, err = parseTags(tags)
+		}
+		if err == nil {
+			*repeat, err = parseRepeat(*repeat)
+		}
+		if err == nil && *due != "" {
+			*due, err = parseDate(*due, time.Now())
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
 		}
 		// Collect all arguments as the task description
-		taskDesc := strings.Join(os.Args[2:], " ")
-		todoList.AddTask(taskDesc)
-		todoList.SaveToFile(filename)
+		task := Task{
+			Title:    strings.Join(title, " "),
+			Context:  normalizeContext(*context),
+			Effort:   level,
+			Goal:     *goal,
+			Due:      *due,
+			Priority: *priority,
+			Tags:     tags,
+			Repeat:   *repeat,
+			Notes:    strings.TrimSpace(*note),
+		}
+		// A bare URL is captured the same way as --url
+		if *link == "" && isURL(task.Title) {
+			*link, task.Title = task.Title, ""
+		}
+		if *link != "" {
+			task.Attachments = []string{*link}
+			if task.Title == "" {
+				task.Title = fetchTitle(*link)
+			}
+		}
+		if *audio != "" {
+			if task.Title != "" {
+				fmt.Println(T("Error: --audio takes the title from the recording; leave out the description"))
+				os.Exit(1)
+			}
+			// Keep the recording next to the task in case the transcript is off
+			path, err := filepath.Abs(*audio)
+			if err == nil {
+				task.Title, err = newTranscriber().transcribe(path)
+			}
+			if err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+				os.Exit(1)
+			}
+			task.Attachments = append(task.Attachments, path)
+		}
+		if strings.TrimSpace(task.Title) == "" {
+			fmt.Println(T("Error: Task description required"))
+			os.Exit(1)
+		}
+		todoList := loadTodoList(filename)
+		err = todoList.Transaction(filename, func() error {
+			if _, ok := todoList.findGoal(task.Goal); task.Goal != 0 && !ok {
+				return fmt.Errorf(T("goal with ID %d not found"), task.Goal)
+			}
+			if *parent != "" {
+				id, err := todoList.Resolve(*parent)
+				if err != nil {
+					return err
+				}
+				p, _ := todoList.Find(id)
+				task.Parent = p.UUID
+			}
+			if warning := todoList.dayFullWarning(filename, task); warning != "" {
+				fmt.Println(warning)
+			}
+			return todoList.AddTaskFrom(task)
+		})
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
 
 	case "list":
-		listCmd.Parse(os.Args[2:])
-		todoList.ListTasks()
+		listCmd := flag.NewFlagSet("list", flag.ExitOnError)
+		var filter taskFilter
+		filter.register(listCmd)
+		anyContext := listCmd.Bool("any-context", false, "ignore the active context")
+		sortBy := listCmd.String("sort", cmp.Or(cfg.ListSort, "id"), "order: "+strings.Join(sortOrders, ", "))
+		reverse := listCmd.Bool("reverse", cfg.ListReverse, "reverse the sort order")
+		archived := listCmd.Bool("archived", false, "list archived tasks instead")
+		listCmd.Parse(args[1:])
+		state, err := loadState(filename)
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+		}
+		if filter.context == "" && !*anyContext {
+			filter.context = state.Context
+		}
+		todoList := loadTodoList(filename)
+		if *archived {
+			if todoList, err = loadArchive(filename); err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+				os.Exit(1)
+			}
+		}
+		tasks := todoList.Filter(func(task Task) bool {
+			// Someday tasks stay out of the active list unless asked for
+			return filter.keep(task) && task.Someday == filter.someday
+		})
+		if err := sortTasks(tasks, *sortBy, *reverse); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		if outputFormat != "table" {
+			if err := renderTasks(todoList, tasks); err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+				os.Exit(1)
+			}
+			return
+		}
+		todoList.PrintTree(tasks)
+		if !*archived {
+			todoList.PrintFollowUps(time.Now())
+			todoList.PrintSomedayReminder(state, time.Now())
+		}
 
-	case "complete":
-		completeCmd.Parse(os.Args[2:])
-		if completeCmd.NArg() != 1 {
-			fmt.Println("Error: Task ID required")
+	case "search":
+		searchCmd := flag.NewFlagSet("search", flag.ExitOnError)
+		regex := searchCmd.Bool("regex", false, "treat the query as a regular expression")
+		var filter taskFilter
+		filter.register(searchCmd)
+		query := strings.Join(parseInterspersed(searchCmd, args[1:]), " ")
+		if strings.TrimSpace(query) == "" {
+			fmt.Println(T("Error: Search query required"))
+			os.Exit(1)
+		}
+		matches, err := titleMatcher(query, *regex)
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		todoList := loadTodoList(filename)
+		tasks := todoList.Filter(func(task Task) bool {
+			return filter.keep(task) && matches(task.Title)
+		})
+		if outputFormat == "table" {
+			todoList.PrintTasks(tasks)
+		} else if err := renderTasks(todoList, tasks); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		// Like grep, no match is a failure scripts can test for
+		if len(tasks) == 0 {
+			os.Exit(1)
+		}
+
+	case "show":
+		if len(args) != 2 {
+			fmt.Println(T("Error: Task ID required"))
+			os.Exit(1)
+		}
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil && outputFormat != "table" {
+			task, _ := todoList.Find(id)
+			err = writeTask(os.Stdout, &todoList.List, task, outputFormat)
+		} else if err == nil {
+			err = todoList.ShowTask(id, filename)
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "focus":
+		if len(args) != 2 {
+			fmt.Println(T("Error: Task ID required"))
 			return
 		}
-		id, err := strconv.Atoi(os.Args[2])
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil {
+			err = focusTask(todoList, filename, id)
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "next":
+		nextCmd := flag.NewFlagSet("next", flag.ExitOnError)
+		effort := nextCmd.String("effort", "", "only tasks of this effort: quick, medium or deep")
+		nextCmd.Parse(args[1:])
+		level, err := parseEffort(*effort)
+		if err == nil {
+			var state sessionState
+			state, err = loadState(filename)
+			todoList := loadTodoList(filename)
+			tasks := todoList.NextTasks(state.Context, level)
+			suggestions := contextSuggestions(tasks)
+			if s, ok := calendarSuggestion(filename, time.Now()); ok {
+				suggestions = append(suggestions, s)
+			}
+			rankBySuggestions(tasks, suggestions)
+			printSuggestions(suggestions)
+			todoList.PrintTasks(tasks)
+			todoList.PrintFollowUps(time.Now())
+		}
 		if err != nil {
-			fmt.Printf("Error: Invalid task ID '%s'\n", os.Args[2])
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "due":
+		if len(args) != 3 {
+			fmt.Println(T("Error: Task ID and date required (\"\" clears the due date)"))
 			return
 		}
-		err = todoList.CompleteTask(id)
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		due := ""
+		if err == nil && args[2] != "" {
+			due, err = parseDate(args[2], time.Now())
+		}
+		if err == nil {
+			task, _ := todoList.Find(id)
+			task.Due = due
+			if warning := todoList.dayFullWarning(filename, task); warning != "" {
+				fmt.Println(warning)
+			}
+			err = todoList.Transaction(filename, func() error {
+				return todoList.SetDue(id, due)
+			})
+		}
 		if err != nil {
-			fmt.Println(err)
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "tag", "untag":
+		if len(args) < 3 {
+			fmt.Println(T("Error: Task ID and at least one tag required"))
 			return
 		}
-		todoList.SaveToFile(filename)
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		var tags []string
+		if err == nil {
+			tags, err = parseTags(args[2:])
+		}
+		if err == nil {
+			err = todoList.Transaction(filename, func() error {
+				if args[0] == "untag" {
+					return todoList.UntagTask(id, tags)
+				}
+				return todoList.TagTask(id, tags)
+			})
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "prioritize":
+		if len(args) != 3 {
+			fmt.Println(T("Error: Task ID and priority (high, medium, low or \"\") required"))
+			return
+		}
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil {
+			err = todoList.Transaction(filename, func() error {
+				return todoList.SetPriority(id, args[2])
+			})
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "effort":
+		if len(args) != 3 {
+			fmt.Println(T("Error: Task ID and effort (quick, medium, deep or \"\") required"))
+			return
+		}
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil {
+			err = todoList.Transaction(filename, func() error {
+				return todoList.SetEffort(id, args[2])
+			})
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "waiting":
+		if err := waitingCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "pin":
+		if err := pinCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+	case "store":
+		if err := storeCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+	case "someday":
+		if err := somedayCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "review":
+		if err := reviewSomeday(loadTodoList(filename), filename); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "goal":
+		if err := goalCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "rules":
+		if err := rulesCommand(args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "template":
+		if err := templateCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "context":
+		if err := contextCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "set":
+		if err := setCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "complete":
+		if err := completeCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "reopen", "uncomplete":
+		if len(args) != 2 {
+			fmt.Println(T("Error: Task ID required"))
+			os.Exit(1)
+		}
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil {
+			err = todoList.Transaction(filename, func() error { return todoList.ReopenTask(id) })
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
 
 	case "delete":
-		deleteCmd.Parse(os.Args[2:])
-		if deleteCmd.NArg() != 1 {
-			fmt.Println("Error: Task ID required")
+		if err := deleteCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "note":
+		if err := noteCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "edit":
+		if err := editCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "breakdown":
+		if len(args) != 2 {
+			fmt.Println(T("Error: Task ID required"))
 			return
 		}
-		id, err := strconv.Atoi(os.Args[2])
+		todoList := loadTodoList(filename)
+		id, err := todoList.Resolve(args[1])
+		if err == nil {
+			err = breakdownTask(todoList, filename, id)
+		}
 		if err != nil {
-			fmt.Printf("Error: Invalid task ID '%s'\n", os.Args[2])
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "do":
+		request := strings.Join(args[1:], " ")
+		if strings.TrimSpace(request) == "" {
+			fmt.Println(T("Error: Request required"))
+			return
+		}
+		todoList := loadTodoList(filename)
+		if err := doRequest(todoList, filename, request); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "export":
+		exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+		format := exportCmd.String("format", "org", "export format")
+		output := exportCmd.String("o", "", "write to a file instead of stdout")
+		columns := exportCmd.String("fields", "", "comma-separated fields to export, e.g. id,title,client")
+		var filter taskFilter
+		filter.register(exportCmd)
+		exportCmd.Parse(args[1:])
+		tasks := loadTodoList(filename).Filter(filter.keep)
+		if err := exportTasks(tasks, *format, *output, splitFields(*columns)); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "upcoming":
+		upcomingCmd := flag.NewFlagSet("upcoming", flag.ExitOnError)
+		count := upcomingCmd.Int("count", 3, "how many occurrences to show per task")
+		upcomingCmd.Parse(args[1:])
+		loadTodoList(filename).PrintUpcoming(max(*count, 1), time.Now())
+
+	case "encrypt", "decrypt":
+		if err := cryptCommand(filename, args[0] == "encrypt"); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "holidays":
+		if err := holidaysCommand(args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "scan":
+		if err := scanCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "recurrence":
+		if err := recurrenceCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "id-strategy":
+		todoList := loadTodoList(filename)
+		if len(args) == 1 {
+			fmt.Printf(T("New tasks get IDs by %s\n"), cmp.Or(todoList.IDStrategy, todo.IDsIncrease))
 			return
 		}
-		err = todoList.DeleteTask(id)
+		strategy := args[1]
+		if len(args) != 2 || !todo.ValidIDStrategy(strategy) {
+			fmt.Println(T("Error: usage: todo id-strategy [increase|reuse|sequence]"))
+			os.Exit(1)
+		}
+		err := todoList.Transaction(filename, func() error {
+			todoList.IDStrategy = strategy
+			if strategy == todo.IDsSequence {
+				// Count on from the highest ID in use
+				for _, task := range todoList.Tasks {
+					todoList.LastID = max(todoList.LastID, task.ID)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		fmt.Printf(T("New tasks get IDs by %s\n"), strategy)
+
+	case "archive":
+		todoList := loadTodoList(filename)
+		moved := 0
+		// Undo only sees the store, so moves to and from the archive
+		// aren't journaled: undoing one half would lose or duplicate tasks
+		_, err := todoList.commit(filename, func() error {
+			var err error
+			moved, err = todoList.Archive(filename)
+			return err
+		})
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+		fmt.Printf(T("Archived %d completed tasks to %s\n"), moved, archivePath(filename))
+
+	case "restore":
+		if len(args) != 2 {
+			fmt.Println(T("Error: Task ID required"))
+			os.Exit(1)
+		}
+		todoList := loadTodoList(filename)
+		_, err := todoList.commit(filename, func() error {
+			return todoList.Restore(filename, args[1])
+		})
 		if err != nil {
-			fmt.Println(err)
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "done":
+		if err := doneCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "attach":
+		if err := attachCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "attachments":
+		if err := attachmentsCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "open-ref":
+		if err := openRefCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "dashboard":
+		if err := dashboardCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "tui":
+		if err := runTUI(loadTodoList(filename), filename, 0); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "sandbox":
+		if err := sandboxCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "undo", "redo":
+		if err := undoCommand(filename, args[0] == "redo"); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "qr":
+		if err := qrCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "print":
+		printCmd := flag.NewFlagSet("print", flag.ExitOnError)
+		today := printCmd.Bool("today", false, "only tasks due today or overdue")
+		format := printCmd.String("format", "text", "sheet format: text or pdf")
+		output := printCmd.String("o", "", "write to a file instead of stdout")
+		var filter taskFilter
+		filter.register(printCmd)
+		printCmd.Parse(args[1:])
+		now := time.Now()
+		header := fmt.Sprintf(T("Open tasks, %s"), now.Format(dateLayout))
+		if *today {
+			header = fmt.Sprintf(T("Today, %s"), now.Format(dateLayout))
+		}
+		tasks := loadTodoList(filename).Filter(func(task Task) bool {
+			if task.Completed || task.Someday || !filter.keep(task) {
+				return false
+			}
+			return !*today || dateReached(task.Due, now) || task.Pinned
+		})
+		sortTasks(tasks, "priority", false)
+		if err := printSheet(tasks, header, *format, *output); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "bot":
+		if len(args) != 2 || args[1] != "telegram" {
+			fmt.Println(T("Error: Bot network required: todo bot telegram"))
 			return
 		}
-		todoList.SaveToFile(filename)
+		bot, err := newTelegramBot()
+		if err == nil {
+			err = runBot(filename, bot, botAllowedChats())
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "calendar":
+		if err := calendarCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "serve":
+		if err := serveCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "token":
+		if err := tokenCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "sync":
+		if err := syncCommand(filename, args[1:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "import":
+		importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+		format := importCmd.String("format", "org", "import format: org or todotxt")
+		strategy := importCmd.String("strategy", "skip", "for tasks already present: skip, overwrite, merge or duplicate")
+		reportPath := importCmd.String("report", "", "write a JSON report of created, updated and skipped tasks (- for stdout)")
+		resume := importCmd.Bool("resume", false, "carry on with an interrupted import of the same file")
+		importCmd.Parse(args[1:])
+		if importCmd.NArg() != 1 {
+			fmt.Println(T("Error: File to import required (- for stdin)"))
+			return
+		}
+		todoList := loadTodoList(filename)
+		report, err := importTasks(todoList, filename, *format, importCmd.Arg(0), *strategy, *resume)
+		if err == nil && *reportPath != "" {
+			err = writeImportReport(report, *reportPath)
+		}
+		// Keep stdout clean when the report goes there
+		if err == nil && *reportPath != "-" {
+			fmt.Printf(T("Imported %d tasks, updated %d, skipped %d\n"), report.Created, report.Updated, report.Skipped)
+		}
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "run":
+		if len(args) < 2 {
+			fmt.Println(T("Error: Script file required"))
+			return
+		}
+		todoList := loadTodoList(filename)
+		if err := runScript(todoList, filename, args[1], args[2:]); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "version":
+		versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
+		check := versionCmd.Bool("check", false, "check whether a newer release is available")
+		versionCmd.Parse(args[1:])
+		if err := printVersion(*check); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "self-update":
+		if err := selfUpdate(); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			os.Exit(1)
+		}
+
+	case "insights":
+		if err := printInsights(filename, loadTodoList(filename)); err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+		}
 
 	case "help":
 		printUsage()
 
 	default:
-		fmt.Printf("Unknown command: %s\n", os.Args[1])
+		record = false
+		fmt.Printf(T("Unknown command: %s\n"), args[0])
 		printUsage()
 	}
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Handles `todo note <task> [text...]`: sets the task's notes to the text,
+// or opens them in $EDITOR without it. Emptying the notes removes them
+func noteCommand(filename string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(T("usage: todo note <task-id|ref> [text]"))
+	}
+	tl := loadTodoList(filename)
+	id, err := tl.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+	task, _ := tl.Find(id)
+	notes := strings.Join(args[1:], " ")
+	if len(args) == 1 {
+		if notes, err = editNotes(task.Notes); err != nil {
+			return err
+		}
+	}
+	notes = strings.TrimSpace(notes)
+	if notes == task.Notes {
+		fmt.Println(T("Notes unchanged"))
+		return nil
+	}
+	task.Notes = notes
+	if err := tl.Transaction(filename, func() error { return tl.Update(task) }); err != nil {
+		return err
+	}
+	if notes == "" {
+		fmt.Printf(T("Removed the notes of task %d\n"), id)
+	} else {
+		fmt.Printf(T("Updated the notes of task %d\n"), id)
+	}
+	return nil
+}
+
+// Lets the user edit notes in $EDITOR and returns what they saved
+func editNotes(notes string) (string, error) {
+	f, err := os.CreateTemp("", "todo-note-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if notes != "" {
+		notes += "\n"
+	}
+	if _, err := f.WriteString(notes); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := runEditor(f.Name()); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(f.Name())
+	return string(data), err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Emacs org-mode: each task is a top-level TODO/DONE heading, with its UUID
+// and custom fields in the property drawer and attachments as links in the
+// body. The layout is fixed, so --fields doesn't apply
+func exportOrg(w io.Writer, tasks []Task, fields []string) error {
+	if fields != nil {
+		return errors.New(T("the org format doesn't support --fields; use csv or json"))
+	}
+	bw := bufio.NewWriter(w)
+	for _, task := range tasks {
+		keyword := "TODO"
+		if task.Completed {
+			keyword = "DONE"
+		}
+		fmt.Fprintf(bw, "* %s ", keyword)
+		for cookie, priority := range orgPriorities {
+			if priority == task.Priority {
+				fmt.Fprintf(bw, "[#%s] ", cookie)
+			}
+		}
+		// Org users conventionally keep GTD contexts as @tags
+		tags := task.Tags
+		if task.Context != "" {
+			tags = append([]string{task.Context}, tags...)
+		}
+		if len(tags) > 0 {
+			fmt.Fprintf(bw, "%s :%s:\n", task.Title, strings.Join(tags, ":"))
+		} else {
+			fmt.Fprintf(bw, "%s\n", task.Title)
+		}
+		if due, err := time.ParseInLocation(dateLayout, task.Due, time.Local); err == nil {
+			// Planning lines go right below the heading
+			fmt.Fprintf(bw, "  DEADLINE: <%s>\n", due.Format("2006-01-02 Mon"))
+		}
+		fmt.Fprintf(bw, "  :PROPERTIES:\n  :ID:       %s\n", task.UUID)
+		for _, key := range sortedKeys(task.Fields) {
+			fmt.Fprintf(bw, "  :%s: %s\n", key, task.Fields[key])
+		}
+		fmt.Fprintf(bw, "  :END:\n")
+		for _, link := range task.Attachments {
+			fmt.Fprintf(bw, "  - [[%s]]\n", link)
+		}
+	}
+	return bw.Flush()
+}
+
+// Org's default priority cookies
+var orgPriorities = map[string]string{"A": "high", "B": "medium", "C": "low"}
+
+var (
+	orgHeadingRe  = regexp.MustCompile(`^(\*+)\s+(?:(TODO|DONE)\s+)?(?:\[#([A-Z])\]\s+)?(.*?)(?:\s+(:[^\s]+:))?\s*$`)
+	orgPropertyRe = regexp.MustCompile(`^\s*:([A-Za-z_-]+):\s*(.*?)\s*$`)
+	orgLinkRe     = regexp.MustCompile(`\[\[([^\]]+)\](?:\[[^\]]*\])?\]`)
+	orgDeadlineRe = regexp.MustCompile(`DEADLINE:\s*<(\d{4}-\d{2}-\d{2})`)
+)
+
+// Reads TODO and DONE headings at any level as tasks; headings without a
+// keyword only give structure and are skipped. :ID: properties become the
+// task UUID, other properties custom fields, and links in the body become
+// attachments. The first @tag becomes the context, other tags become tags,
+// DEADLINE the due date and [#A]-[#C] the priority. SCHEDULED has no
+// counterpart on tasks yet and is dropped
+func importOrg(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	var current *Task
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := orgHeadingRe.FindStringSubmatch(line); m != nil && strings.HasPrefix(line, "*") {
+			current = nil
+			if m[2] == "" || strings.TrimSpace(m[4]) == "" {
+				continue
+			}
+			task := Task{Title: m[4], Completed: m[2] == "DONE", Priority: orgPriorities[m[3]]}
+			for _, tag := range strings.Split(strings.Trim(m[5], ":"), ":") {
+				switch {
+				case strings.HasPrefix(tag, "@") && task.Context == "":
+					task.Context = tag
+				case tag != "":
+					if tag, err := parseTag(tag); err == nil && !slices.Contains(task.Tags, tag) {
+						task.Tags = append(task.Tags, tag)
+					}
+				}
+			}
+			tasks = append(tasks, task)
+			current = &tasks[len(tasks)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := orgPropertyRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case strings.EqualFold(m[1], "ID"):
+				current.UUID = strings.ToLower(m[2])
+			case strings.EqualFold(m[1], "PROPERTIES"), strings.EqualFold(m[1], "END"):
+			case m[2] != "" && fieldNameRe.MatchString(m[1]):
+				if current.Fields == nil {
+					current.Fields = map[string]string{}
+				}
+				current.Fields[m[1]] = m[2]
+			}
+			continue
+		}
+		if m := orgDeadlineRe.FindStringSubmatch(line); m != nil {
+			current.Due = m[1]
+		}
+		for _, m := range orgLinkRe.FindAllStringSubmatch(line, -1) {
+			current.Attachments = append(current.Attachments, m[1])
+		}
+	}
+	return tasks, scanner.Err()
+}
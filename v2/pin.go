@@ -0,0 +1,88 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Most tasks pinned at once without max_pins in the config file
+const defaultMaxPins = 5
+
+// Whether a task is pinned and still open; done tasks keep the flag but
+// no longer jump the queue
+func pinnedOpen(task Task) bool {
+	return task.Pinned && !task.Completed
+}
+
+// The pin mark and a space before a pinned task's title, or nothing
+func pinPrefix(task Task) string {
+	if !pinnedOpen(task) {
+		return ""
+	}
+	return pinMark() + " "
+}
+
+// Open tasks pinned to the top of list and today views
+func (tl *TodoList) PinnedTasks() []Task {
+	return tl.Filter(pinnedOpen)
+}
+
+// Pins a task to the top of list and today views, or unpins it
+func (tl *TodoList) SetPinned(id int, pinned bool) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID != id {
+			continue
+		}
+		task := &tl.Tasks[i]
+		if pinned && !task.Pinned {
+			if limit := cmp.Or(cfg.MaxPins, defaultMaxPins); len(tl.PinnedTasks()) >= limit {
+				return fmt.Errorf(T("%d tasks are pinned already, the most max_pins allows; unpin one first"), limit)
+			}
+		}
+		task.Pinned = pinned
+		if pinned {
+			fmt.Printf(T("Pinned task %d: %s\n"), id, task.Title)
+		} else {
+			fmt.Printf(T("Unpinned task %d: %s\n"), id, task.Title)
+		}
+		return nil
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Handles `todo pin [<task-id|ref>...] [--clear]`. Without a task it lists
+// the pinned ones
+func pinCommand(filename string, args []string) error {
+	var refs []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		refs, args = append(refs, args[0]), args[1:]
+	}
+	pinCmd := flag.NewFlagSet("pin", flag.ExitOnError)
+	clear := pinCmd.Bool("clear", false, "unpin the tasks")
+	pinCmd.Parse(args)
+	refs = append(refs, pinCmd.Args()...)
+
+	tl := loadTodoList(filename)
+	if len(refs) == 0 {
+		tl.PrintTasks(tl.PinnedTasks())
+		return nil
+	}
+	ids := make([]int, 0, len(refs))
+	for _, ref := range refs {
+		id, err := tl.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	return tl.Transaction(filename, func() error {
+		for _, id := range ids {
+			if err := tl.SetPinned(id, !*clear); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+package todo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// Encrypted files start with this line, then a salt, a nonce and the
+// AES-256-GCM sealed contents. The key is derived from a passphrase with
+// PBKDF2-SHA256
+const encryptedHeader = "todo-encrypted-v1\n"
+
+const (
+	saltSize      = 16
+	kdfIterations = 600_000
+)
+
+// ErrEncrypted is returned when loading an encrypted file without a
+// passphrase
+var ErrEncrypted = errors.New("store is encrypted")
+
+// ErrPassphrase is returned when an encrypted file doesn't open with the
+// passphrase given, or has been tampered with
+var ErrPassphrase = errors.New("wrong passphrase, or the encrypted file is damaged")
+
+// IsEncrypted reports whether data is an encrypted file's contents
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedHeader))
+}
+
+// Encrypt seals data with a key derived from the passphrase and a fresh
+// salt, so the same passphrase never reuses a key
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(encryptedHeader), salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, []byte(encryptedHeader)), nil
+}
+
+// Decrypt opens what Encrypt sealed
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("not an encrypted file")
+	}
+	data = data[len(encryptedHeader):]
+	if len(data) < saltSize {
+		return nil, ErrPassphrase
+	}
+	aead, err := newAEAD(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(encryptedHeader))
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
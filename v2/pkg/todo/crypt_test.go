@@ -0,0 +1,117 @@
+package todo
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, plain := range [][]byte{[]byte(`{"tasks": []}`), {}, bytes.Repeat([]byte("x"), 1<<16)} {
+		sealed, err := Encrypt(plain, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(sealed) {
+			t.Errorf("sealed data doesn't start with the header")
+		}
+		if len(plain) > 0 && bytes.Contains(sealed, plain) {
+			t.Errorf("sealed data contains the plain text")
+		}
+		opened, err := Decrypt(sealed, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(opened, plain) {
+			t.Errorf("Decrypt = %q, want %q", opened, plain)
+		}
+	}
+}
+
+func TestEncryptFreshSalt(t *testing.T) {
+	a, err := Encrypt([]byte("same"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Encrypt([]byte("same"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("sealing the same data twice gave the same output")
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	sealed, err := Encrypt([]byte(`{"tasks": []}`), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-1] ^= 1
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+	}{
+		{"wrong passphrase", sealed, "PW"},
+		{"tampered", flipped, "pw"},
+		{"truncated", sealed[:len(sealed)-5], "pw"},
+		{"header only", []byte(encryptedHeader), "pw"},
+		{"no nonce", sealed[:len(encryptedHeader)+saltSize+4], "pw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(tt.data, tt.passphrase); !errors.Is(err, ErrPassphrase) {
+				t.Errorf("Decrypt error = %v, want ErrPassphrase", err)
+			}
+		})
+	}
+	if _, err := Decrypt([]byte(`{"tasks": []}`), "pw"); err == nil || errors.Is(err, ErrPassphrase) {
+		t.Errorf("Decrypt of plain data error = %v, want a not-encrypted error", err)
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{encryptedHeader + "rest", true},
+		{encryptedHeader, true},
+		{"todo-encrypted-v1", false},
+		{"todo-encrypted-v2\n", false},
+		{`{"tasks": []}`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsEncrypted([]byte(tt.data)); got != tt.want {
+			t.Errorf("IsEncrypted(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFileStoreEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.json")
+	key := func(passphrase string) func() (string, error) {
+		return func() (string, error) { return passphrase, nil }
+	}
+	list := &List{Tasks: []Task{{ID: 1, UUID: "u1", Hash: "h1", Title: "secret"}}}
+	if err := (FileStore{Path: path, Passphrase: key("pw")}).Save(list); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := FileStore{Path: path, Passphrase: key("pw")}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Tasks) != 1 || loaded.Tasks[0].Title != "secret" {
+		t.Errorf("loaded %+v", loaded.Tasks)
+	}
+	if _, err := (FileStore{Path: path}).Load(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Load without a passphrase error = %v, want ErrEncrypted", err)
+	}
+	if _, err := (FileStore{Path: path, Passphrase: key("nope")}).Load(); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("Load with the wrong passphrase error = %v, want ErrPassphrase", err)
+	}
+}
@@ -0,0 +1,72 @@
+package todo
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewHash generates the stable hash a new task is referred to by
+func NewHash() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Derives a hash for tasks saved before hashes existed. It only depends on
+// fields already in the file, so the ref stays the same until the next save
+// persists it
+func legacyHash(task Task) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d\x00%s", task.ID, task.Title)))
+	return hex.EncodeToString(sum[:10])
+}
+
+// NewUUID generates a random (version 4) UUID, the canonical identity of a
+// task
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// Derives a name-based (version 5) UUID from the task hash for tasks saved
+// before UUIDs existed, so the identity is stable until it's persisted
+func legacyUUID(task Task) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(task.Hash))
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// FillIdentity gives a task read from a store that doesn't record hashes
+// or UUIDs ones derived from what it does record, so they stay the same
+// from one load to the next until a save persists them
+func FillIdentity(t *Task) {
+	if t.Hash == "" {
+		t.Hash = legacyHash(*t)
+	}
+	if t.UUID == "" {
+		t.UUID = legacyUUID(*t)
+	}
+}
+
+// Namespace for legacy task UUIDs (the RFC 4122 URL namespace)
+var uuidNamespace = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,69 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Renderer writes tasks in one output format. The list is the whole store
+// the tasks were picked from, for formats that show subtask trees or refs
+type Renderer interface {
+	Render(w io.Writer, l *List, tasks []Task) error
+}
+
+// RenderFunc lets a plain function be a Renderer
+type RenderFunc func(w io.Writer, l *List, tasks []Task) error
+
+// Render calls f
+func (f RenderFunc) Render(w io.Writer, l *List, tasks []Task) error {
+	return f(w, l, tasks)
+}
+
+// RendererFactory makes a renderer from what follows the format name and a
+// colon, such as the template in "template:{{.Title}}"; arg is "" when
+// nothing does
+type RendererFactory func(arg string) (Renderer, error)
+
+// ErrUnknownFormat is returned by LookupRenderer for an unregistered name
+var ErrUnknownFormat = errors.New("unknown output format")
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]RendererFactory{}
+)
+
+// RegisterRenderer makes a format available under name, replacing any
+// renderer registered as name before, including the CLI's own
+func RegisterRenderer(name string, factory RendererFactory) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[name] = factory
+}
+
+// LookupRenderer returns the renderer for a format, "name" or "name:arg"
+func LookupRenderer(format string) (Renderer, error) {
+	name, arg, _ := strings.Cut(format, ":")
+	renderersMu.RLock()
+	factory, ok := renderers[name]
+	renderersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, name)
+	}
+	return factory(arg)
+}
+
+// RendererNames returns the registered format names, sorted
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
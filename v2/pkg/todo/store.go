@@ -0,0 +1,345 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Store loads and saves a whole list
+type Store interface {
+	Load() (*List, error)
+	Save(*List) error
+}
+
+// ErrCorrupt is returned for a store file that can't be parsed
+var ErrCorrupt = errors.New("corrupt store file")
+
+// FileStore keeps a list in a JSON file, the todo.json format. A missing
+// file loads as an empty list. Each save keeps the previous file as
+// <path>.bak, which Load falls back to if the file is corrupt
+type FileStore struct {
+	Path string
+	// Recovered, if set, is called with the error when the file couldn't
+	// be read and the backup was loaded instead
+	Recovered func(err error)
+	// Passphrase, if set, makes saves encrypted with the passphrase it
+	// returns. It is only called when needed; encrypted files can't be
+	// loaded without it
+	Passphrase func() (string, error)
+}
+
+var _ Store = FileStore{}
+
+// BackupPath is where the previous version of the file is kept
+func (s FileStore) BackupPath() string {
+	return s.Path + ".bak"
+}
+
+// Load reads the file, giving tasks from older files a hash and UUID. If
+// the file is corrupt and a backup exists, the damaged file is moved to
+// <path>.corrupt and the backup is put back in its place
+func (s FileStore) Load() (*List, error) {
+	l, err := s.readList(s.Path)
+	if !errors.Is(err, ErrCorrupt) {
+		return l, err
+	}
+	if _, statErr := os.Stat(s.BackupPath()); statErr != nil {
+		return nil, err
+	}
+	backup, backupErr := s.readList(s.BackupPath())
+	if backupErr != nil {
+		return nil, err
+	}
+	if renameErr := os.Rename(s.Path, s.Path+".corrupt"); renameErr != nil {
+		return nil, renameErr
+	}
+	if saveErr := s.Save(backup); saveErr != nil {
+		return nil, saveErr
+	}
+	if s.Recovered != nil {
+		s.Recovered(err)
+	}
+	return backup, nil
+}
+
+func (s FileStore) readList(path string) (*List, error) {
+	l := &List{Tasks: []Task{}}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if header, _ := r.(*bufio.Reader).Peek(len(encryptedHeader)); IsEncrypted(header) {
+		if r, err = s.decrypt(r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := l.decode(r); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", path, ErrCorrupt, err)
+	}
+	for i := range l.Tasks {
+		FillIdentity(&l.Tasks[i])
+	}
+	return l, nil
+}
+
+// Reads and opens an encrypted file whole; only plain files are streamed
+func (s FileStore) decrypt(r io.Reader) (io.Reader, error) {
+	if s.Passphrase == nil {
+		return nil, ErrEncrypted
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(plain), nil
+}
+
+// Save streams the list to the file one task at a time, so memory use
+// doesn't grow with the size of the store, and swaps the new file in
+// atomically. An encrypted list is sealed whole, so it is built in memory
+func (s FileStore) Save(l *List) error {
+	// Write a temp file next to the store and rename it over, so a crash
+	// or full disk mid-write never leaves a truncated todo.json behind
+	f, err := os.CreateTemp(filepath.Dir(s.Path), "."+filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(s.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if s.Passphrase != nil {
+		err = s.encrypt(w, l)
+	} else {
+		err = l.encode(w)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.Path); err == nil {
+		if err := s.keepBackup(); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(f.Name(), s.Path); err != nil {
+		return err
+	}
+	// Make the rename itself durable; not every platform can sync a
+	// directory, so failing to is not an error
+	if dir, err := os.Open(filepath.Dir(s.Path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+func (s FileStore) encrypt(w io.Writer, l *List) error {
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return err
+	}
+	var plain bytes.Buffer
+	if err := l.encode(&plain); err != nil {
+		return err
+	}
+	sealed, err := Encrypt(plain.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// Points the backup at the current file. Saves never write into an
+// existing file, so a hard link is enough; filesystems without links get
+// a copy
+func (s FileStore) keepBackup() error {
+	tmp := s.BackupPath() + ".tmp"
+	os.Remove(tmp)
+	if err := os.Link(s.Path, tmp); err != nil {
+		if err := copyFile(s.Path, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, s.BackupPath())
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Writes the same layout json.MarshalIndent would produce, task by task
+func (l *List) encode(w io.Writer) error {
+	if len(l.Tasks) == 0 {
+		if _, err := io.WriteString(w, "{\n  \"tasks\": []"); err != nil {
+			return err
+		}
+	} else {
+		if _, err := io.WriteString(w, "{\n  \"tasks\": [\n"); err != nil {
+			return err
+		}
+		for i, task := range l.Tasks {
+			data, err := json.MarshalIndent(task, "    ", "  ")
+			if err != nil {
+				return err
+			}
+			sep := ",\n"
+			if i == len(l.Tasks)-1 {
+				sep = "\n"
+			}
+			if _, err := fmt.Fprintf(w, "    %s%s", data, sep); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "  ]"); err != nil {
+			return err
+		}
+	}
+	// Goals are few, so they are written in one go
+	if len(l.Goals) > 0 {
+		data, err := json.MarshalIndent(l.Goals, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, ",\n  \"goals\": %s", data); err != nil {
+			return err
+		}
+	}
+	if l.IDStrategy != "" {
+		data, err := json.Marshal(l.IDStrategy)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, ",\n  \"id_strategy\": %s", data); err != nil {
+			return err
+		}
+	}
+	if l.LastID != 0 {
+		if _, err := fmt.Fprintf(w, ",\n  \"last_id\": %d", l.LastID); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n}")
+	return err
+}
+
+// Walks the JSON object token by token, decoding tasks one at a time instead
+// of holding the whole document in memory
+func (l *List) decode(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "tasks":
+			err = l.decodeTasks(dec)
+		case "goals":
+			err = dec.Decode(&l.Goals)
+		case "id_strategy":
+			err = dec.Decode(&l.IDStrategy)
+		case "last_id":
+			err = dec.Decode(&l.LastID)
+		default:
+			// Skip fields we don't know about
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func (l *List) decodeTasks(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		// "tasks": null
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected tasks array, got %v", tok)
+	}
+	for dec.More() {
+		var task Task
+		if err := dec.Decode(&task); err != nil {
+			return err
+		}
+		l.Tasks = append(l.Tasks, task)
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testList() *List {
+	return &List{
+		Tasks: []Task{
+			{ID: 1, UUID: "u1", Hash: "h1", Title: "Call the bank", Tags: []string{"finance"}, Fields: map[string]string{"client": "ACME"}},
+			{ID: 2, UUID: "u2", Hash: "h2", Title: "Quote \"this\" é", Completed: true, Notes: "line one\nline two"},
+		},
+		Goals:      []Goal{{ID: 1, Title: "Run a 10k", By: "2026-12-01"}},
+		IDStrategy: IDsSequence,
+		LastID:     7,
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		list *List
+	}{
+		{"full", testList()},
+		{"empty", &List{Tasks: []Task{}}},
+		{"goals only", &List{Tasks: []Task{}, Goals: []Goal{{ID: 3, Title: "Ship"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.list.encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+			// The streamed layout matches what json.MarshalIndent gives
+			want, err := json.MarshalIndent(tt.list, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("encode wrote\n%s\nwant\n%s", buf.String(), want)
+			}
+			got := &List{Tasks: []Task{}}
+			if err := got.decode(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.list) {
+				t.Errorf("decoded %+v, want %+v", got, tt.list)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var full bytes.Buffer
+	if err := testList().encode(&full); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ name, data string }{
+		{"empty", ""},
+		{"truncated", full.String()[:full.Len()/2]},
+		{"missing closing brace", strings.TrimSuffix(full.String(), "}")},
+		{"not an object", `[1, 2]`},
+		{"tasks not an array", `{"tasks": {"id": 1}}`},
+		{"bad task", `{"tasks": [{"id": "one"}]}`},
+		{"garbage", "\x00\x01todo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&List{}).decode(strings.NewReader(tt.data)); err == nil {
+				t.Errorf("decode(%q) succeeded", tt.data)
+			}
+		})
+	}
+	// Unknown keys and null tasks are fine
+	l := &List{}
+	if err := l.decode(strings.NewReader(`{"tasks": null, "later": {"x": [1]}, "last_id": 4}`)); err != nil || l.LastID != 4 {
+		t.Errorf("decode = %v, last_id %d", err, l.LastID)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store := FileStore{Path: filepath.Join(t.TempDir(), "todo.json")}
+	empty, err := store.Load()
+	if err != nil || len(empty.Tasks) != 0 {
+		t.Fatalf("Load of a missing file = %+v, %v", empty, err)
+	}
+	want := testList()
+	if err := store.Save(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+	if _, err := os.Stat(store.BackupPath()); !os.IsNotExist(err) {
+		t.Errorf("first save left a backup: %v", err)
+	}
+	if err := store.Save(&List{Tasks: []Task{}}); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := FileStore{Path: store.BackupPath()}.Load()
+	if err != nil || len(backup.Tasks) != len(want.Tasks) {
+		t.Errorf("backup holds %d tasks, %v; want the %d saved before", len(backup.Tasks), err, len(want.Tasks))
+	}
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.json")
+	var recovered error
+	store := FileStore{Path: path, Recovered: func(err error) { recovered = err }}
+	if err := store.Save(testList()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(testList()); err != nil {
+		t.Fatal(err)
+	}
+	damaged := []byte(`{"tasks": [{"id": 1, "title": "half`)
+	if err := os.WriteFile(path, damaged, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Tasks) != 2 {
+		t.Errorf("recovered %d tasks, want 2", len(list.Tasks))
+	}
+	if !errors.Is(recovered, ErrCorrupt) {
+		t.Errorf("Recovered got %v, want ErrCorrupt", recovered)
+	}
+	if kept, err := os.ReadFile(path + ".corrupt"); err != nil || !bytes.Equal(kept, damaged) {
+		t.Errorf("damaged file kept as %q, %v", kept, err)
+	}
+	// The backup is put back in place, so the store reads cleanly again
+	recovered = nil
+	if _, err := store.Load(); err != nil || recovered != nil {
+		t.Errorf("Load after recovery = %v, recovered %v", err, recovered)
+	}
+}
+
+func TestFileStoreCorruptWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (FileStore{Path: path}).Load(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load error = %v, want ErrCorrupt", err)
+	}
+	if _, err := os.Stat(path + ".corrupt"); !os.IsNotExist(err) {
+		t.Error("the damaged file was moved without a backup to replace it")
+	}
+}
@@ -0,0 +1,271 @@
+// Package todo is the task engine behind the todo CLI: the data model, the
+// list operations and persistence, without any terminal output. Other Go
+// programs can embed it to read and change the same todo.json:
+//
+//	store := todo.FileStore{Path: "todo.json"}
+//	list, err := store.Load()
+//	if err != nil {
+//		return err
+//	}
+//	list.AddTask("Water the plants")
+//	return store.Save(list)
+package todo
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// ErrNotFound is returned for a task ID that isn't in the list
+var ErrNotFound = errors.New("task not found")
+
+// Represents a todo item. UUID is the task's identity across machines and
+// imports; ID is the short alias shown to users
+type Task struct {
+	ID        int    `json:"id"`
+	UUID      string `json:"uuid,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	// Longer description; may span several lines
+	Notes string `json:"notes,omitempty"`
+	// When the task was added (RFC 3339)
+	CreatedAt string `json:"created_at,omitempty"`
+	// When the task was completed (RFC 3339)
+	CompletedAt string `json:"completed_at,omitempty"`
+	// Revision, raised by each saved change, and when that change was
+	// made (RFC 3339); syncs let the later revision win
+	Rev       int    `json:"rev,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	// URLs and file references attached to the task
+	Attachments []string `json:"attachments,omitempty"`
+	// high, medium or low
+	Priority string `json:"priority,omitempty"`
+	// Date (YYYY-MM-DD) the task has to be done by
+	Due string `json:"due,omitempty"`
+	// Free-form labels such as finance or home
+	Tags []string `json:"tags,omitempty"`
+	// GTD context the task can be done in, e.g. @home
+	Context string `json:"context,omitempty"`
+	// Energy the task takes: quick, medium or deep
+	Effort string `json:"effort,omitempty"`
+	// Blocked on someone else; waiting tasks aren't next actions
+	Waiting   bool   `json:"waiting,omitempty"`
+	WaitingOn string `json:"waiting_on,omitempty"`
+	// Date (YYYY-MM-DD) to chase up a waiting task
+	FollowUp string `json:"follow_up,omitempty"`
+	// Parked in the someday/maybe backlog, out of the active list
+	Someday bool `json:"someday,omitempty"`
+	// Kept at the top of list and today views whatever their order
+	Pinned bool `json:"pinned,omitempty"`
+	// ID of the goal the task contributes to
+	Goal int `json:"goal,omitempty"`
+	// Recurrence rule, e.g. weekly or every:3d; completing the task adds
+	// the next occurrence
+	Repeat string `json:"repeat,omitempty"`
+	// UUID of the task this is a subtask of
+	Parent string `json:"parent,omitempty"`
+	// User-defined metadata, e.g. client=ACME
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Represents an outcome that tasks work towards, e.g. "Run a 10k"
+type Goal struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	// Target date (YYYY-MM-DD), if any
+	By string `json:"by,omitempty"`
+}
+
+// ID allocation strategies. The strategy is recorded in the list, so every
+// machine sharing a store numbers new tasks the same way
+const (
+	// One past the highest ID in the list; the default
+	IDsIncrease = "increase"
+	// The lowest ID not in use, keeping IDs small
+	IDsReuse = "reuse"
+	// A counter kept with the list, so an ID is never handed out twice,
+	// even after its task is deleted
+	IDsSequence = "sequence"
+)
+
+// ValidIDStrategy reports whether s names an ID allocation strategy
+func ValidIDStrategy(s string) bool {
+	return s == IDsIncrease || s == IDsReuse || s == IDsSequence
+}
+
+// List holds the tasks and goals of one store. The zero value is an empty
+// list ready to use
+type List struct {
+	Tasks []Task `json:"tasks"`
+	Goals []Goal `json:"goals,omitempty"`
+	// How new tasks get IDs; "" means IDsIncrease
+	IDStrategy string `json:"id_strategy,omitempty"`
+	// Highest ID handed out under IDsSequence
+	LastID int `json:"last_id,omitempty"`
+	nextID int
+}
+
+// NextID returns the ID the next added task will get
+func (l *List) NextID() int {
+	switch l.IDStrategy {
+	case IDsReuse:
+		used := make(map[int]bool, len(l.Tasks))
+		for _, task := range l.Tasks {
+			used[task.ID] = true
+		}
+		id := 1
+		for used[id] {
+			id++
+		}
+		return id
+	case IDsSequence:
+		last := l.LastID
+		for _, task := range l.Tasks {
+			last = max(last, task.ID)
+		}
+		return last + 1
+	}
+	if l.nextID == 0 {
+		l.nextID = 1
+		for _, task := range l.Tasks {
+			l.nextID = max(l.nextID, task.ID+1)
+		}
+	}
+	return l.nextID
+}
+
+// Add appends a task under the next free ID, giving it a UUID and hash
+// unless it already has them (e.g. when it comes from an import), and
+// returns it as stored
+func (l *List) Add(task Task) Task {
+	task.ID = l.NextID()
+	if task.UUID == "" {
+		task.UUID = NewUUID()
+	}
+	if task.Hash == "" {
+		task.Hash = NewHash()
+	}
+	if task.CreatedAt == "" {
+		task.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	l.Tasks = append(l.Tasks, task)
+	l.nextID++
+	if l.IDStrategy == IDsSequence {
+		l.LastID = max(l.LastID, task.ID)
+	}
+	return task
+}
+
+// AddTask adds a new open task with the given title
+func (l *List) AddTask(title string) Task {
+	return l.Add(Task{Title: title})
+}
+
+// Find returns the task with the given ID
+func (l *List) Find(id int) (Task, bool) {
+	for _, task := range l.Tasks {
+		if task.ID == id {
+			return task, true
+		}
+	}
+	return Task{}, false
+}
+
+// Update replaces the task with the same ID
+func (l *List) Update(task Task) error {
+	for i := range l.Tasks {
+		if l.Tasks[i].ID == task.ID {
+			l.Tasks[i] = task
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// CompleteTask marks a task as done and returns it
+func (l *List) CompleteTask(id int) (Task, error) {
+	for i := range l.Tasks {
+		if l.Tasks[i].ID == id {
+			l.Tasks[i].Completed = true
+			l.Tasks[i].CompletedAt = time.Now().Format(time.RFC3339)
+			return l.Tasks[i], nil
+		}
+	}
+	return Task{}, ErrNotFound
+}
+
+// DeleteTask removes a task and returns it
+func (l *List) DeleteTask(id int) (Task, error) {
+	for i, task := range l.Tasks {
+		if task.ID == id {
+			l.Tasks = slices.Delete(l.Tasks, i, i+1)
+			return task, nil
+		}
+	}
+	return Task{}, ErrNotFound
+}
+
+// SameContent reports whether two copies of a task differ only in their ID,
+// which is local to each list, and revision
+func SameContent(a, b Task) bool {
+	a.ID, b.ID = 0, 0
+	a.Rev, b.Rev = 0, 0
+	a.UpdatedAt, b.UpdatedAt = "", ""
+	return reflect.DeepEqual(a, b)
+}
+
+// StampRevisions raises the revision of each task changed since before and
+// records when. A task whose revision the change already raised, such as a
+// newer copy taken from a sync, keeps it
+func (l *List) StampRevisions(before *List, now time.Time) {
+	old := make(map[string]Task, len(before.Tasks))
+	for _, task := range before.Tasks {
+		old[task.UUID] = task
+	}
+	stamp := now.Format(time.RFC3339)
+	for i := range l.Tasks {
+		task := &l.Tasks[i]
+		prev, ok := old[task.UUID]
+		switch {
+		case !ok && task.Rev == 0:
+			task.Rev, task.UpdatedAt = 1, stamp
+		case !ok, task.Rev > prev.Rev, SameContent(prev, *task):
+		default:
+			task.Rev, task.UpdatedAt = max(prev.Rev, task.Rev)+1, stamp
+		}
+	}
+}
+
+// Filter returns the tasks keep accepts, in list order
+func (l *List) Filter(keep func(Task) bool) []Task {
+	var tasks []Task
+	for _, task := range l.Tasks {
+		if keep(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// Clone returns a copy of the list that shares no slices or maps with it,
+// so it can be restored after the original was modified in place
+func (l *List) Clone() *List {
+	clone := &List{
+		Tasks:      make([]Task, len(l.Tasks)),
+		Goals:      slices.Clone(l.Goals),
+		IDStrategy: l.IDStrategy,
+		LastID:     l.LastID,
+		nextID:     l.nextID,
+	}
+	for i, task := range l.Tasks {
+		task.Attachments = slices.Clone(task.Attachments)
+		task.Tags = slices.Clone(task.Tags)
+		task.Fields = maps.Clone(task.Fields)
+		clone.Tasks[i] = task
+	}
+	return clone
+}
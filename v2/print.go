@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Columns a sheet line wraps at; fits A4 and Letter in both formats
+const sheetWidth = 72
+
+// One printed line: a task title behind a checkbox, or an indented
+// continuation or detail line belonging to the task above
+type sheetLine struct {
+	text  string
+	box   bool
+	small bool
+}
+
+// Renderers for `todo print --format`, by name
+var sheetFormats = map[string]func(w io.Writer, header string, lines []sheetLine) error{
+	"text": writeTextSheet,
+	"pdf":  writePDFSheet,
+}
+
+// Lays tasks out as checkbox lines, wrapping long titles and putting due
+// dates, tags and the like on a smaller line below
+func buildSheet(tasks []Task, now time.Time) []sheetLine {
+	var lines []sheetLine
+	for _, task := range tasks {
+		for i, text := range wrapWords(task.Title, sheetWidth-4) {
+			lines = append(lines, sheetLine{text: text, box: i == 0})
+		}
+		if _, labels := taskDetails(task, now); len(labels) > 0 {
+			for _, text := range wrapWords(strings.Join(labels, "; "), sheetWidth-4) {
+				lines = append(lines, sheetLine{text: text, small: true})
+			}
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, sheetLine{text: T("Nothing planned")})
+	}
+	return lines
+}
+
+// Breaks s into lines of at most width terminal cells, at spaces where it
+// can and mid-word only for words longer than a line
+func wrapWords(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for displayWidth(word) > width {
+			if line != "" {
+				lines, line = append(lines, line), ""
+			}
+			runes := []rune(word)
+			n := 0
+			for w := 0; n < len(runes) && w+displayWidth(string(runes[n])) <= width; n++ {
+				w += displayWidth(string(runes[n]))
+			}
+			lines, word = append(lines, string(runes[:n])), string(runes[n:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case displayWidth(line)+1+displayWidth(word) <= width:
+			line += " " + word
+		default:
+			lines, line = append(lines, line), word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Splits lines into pages of at most perPage, never leaving a task's
+// continuation lines on a different page from its checkbox
+func paginate(lines []sheetLine, perPage int) [][]sheetLine {
+	var pages [][]sheetLine
+	start := 0
+	for start < len(lines) {
+		end := min(start+perPage, len(lines))
+		if end < len(lines) && !lines[end].box {
+			// Back up to the start of the task that would be split
+			for cut := end - 1; cut > start; cut-- {
+				if lines[cut].box {
+					end = cut
+					break
+				}
+			}
+		}
+		pages = append(pages, lines[start:end])
+		start = end
+	}
+	return pages
+}
+
+// Plain text sheet with "[ ]" boxes; pages are separated by form feeds,
+// which printers and `lp` turn into page breaks
+func writeTextSheet(w io.Writer, header string, lines []sheetLine) error {
+	const perPage = 54
+	bw := bufio.NewWriter(w)
+	pages := paginate(lines, perPage)
+	for i, page := range pages {
+		if i > 0 {
+			bw.WriteString("\f")
+		}
+		pageNo := fmt.Sprintf(T("Page %d of %d"), i+1, len(pages))
+		fmt.Fprintf(bw, "%s%s\n", padRight(header, sheetWidth-displayWidth(pageNo)), pageNo)
+		fmt.Fprintf(bw, "%s\n\n", strings.Repeat("=", sheetWidth))
+		for _, line := range page {
+			switch {
+			case line.box:
+				fmt.Fprintf(bw, "[ ] %s\n", line.text)
+			default:
+				fmt.Fprintf(bw, "    %s\n", line.text)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// PDF page geometry in points: A4 with 2cm margins
+const (
+	pdfWidth   = 595
+	pdfHeight  = 842
+	pdfMargin  = 56
+	pdfLeading = 16
+)
+
+// Minimal single-font PDF: Helvetica from the standard 14, so nothing is
+// embedded and any viewer or printer can render it. Text is WinAnsi
+// encoded; characters outside it print as "?"
+func writePDFSheet(w io.Writer, header string, lines []sheetLine) error {
+	top := pdfHeight - pdfMargin
+	perPage := (top - 2*pdfLeading - pdfMargin) / pdfLeading
+	pages := paginate(lines, perPage)
+
+	var objects []string
+	kids := make([]string, len(pages))
+	for i, page := range pages {
+		var c bytes.Buffer
+		fmt.Fprintf(&c, "BT /F2 14 Tf %d %d Td (%s) Tj ET\n", pdfMargin, top, pdfString(header))
+		fmt.Fprintf(&c, "BT /F1 9 Tf %d %d Td (%s) Tj ET\n", pdfWidth-pdfMargin-60, top, pdfString(fmt.Sprintf(T("Page %d of %d"), i+1, len(pages))))
+		fmt.Fprintf(&c, "0.5 w %d %d m %d %d l S\n", pdfMargin, top-8, pdfWidth-pdfMargin, top-8)
+		y := top - 2*pdfLeading
+		for _, line := range page {
+			size := 11
+			if line.small {
+				size = 9
+			}
+			if line.box {
+				fmt.Fprintf(&c, "0.8 w %d %d 9 9 re S\n", pdfMargin, y-1)
+			}
+			fmt.Fprintf(&c, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n", size, pdfMargin+16, y, pdfString(line.text))
+			y -= pdfLeading
+		}
+		// Objects 1-4 are fixed; each page takes a page and a content object
+		pageObj := 5 + 2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfWidth, pdfHeight, pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", c.Len(), c.String()))
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}, objects...)
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Encodes s as a WinAnsi PDF string literal body
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Writes a sheet of tasks in the given format to path, or stdout
+func printSheet(tasks []Task, header, format, path string) error {
+	render, ok := sheetFormats[format]
+	if !ok {
+		return fmt.Errorf(T("unknown print format %q (available: %s)"), format, formatNames(sheetFormats))
+	}
+	lines := buildSheet(tasks, time.Now())
+	if path == "" || path == "-" {
+		if format == "pdf" && term.IsTerminal(int(os.Stdout.Fd())) {
+			return errors.New(T("not writing a PDF to the terminal; use -o file.pdf or redirect the output"))
+		}
+		return render(os.Stdout, header, lines)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(f, header, lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
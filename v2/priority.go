@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Priority levels, most urgent first
+var priorities = []string{"high", "medium", "low"}
+
+// Checks a priority level; "" clears it
+func parsePriority(priority string) (string, error) {
+	priority = strings.ToLower(strings.TrimSpace(priority))
+	if priority == "" || slices.Contains(priorities, priority) {
+		return priority, nil
+	}
+	return "", fmt.Errorf(T("unknown priority %q (use %s)"), priority, strings.Join(priorities, ", "))
+}
+
+// Sort key: high, medium, no priority, then low
+func priorityRank(priority string) int {
+	switch priority {
+	case "high":
+		return 0
+	case "medium":
+		return 1
+	case "low":
+		return 3
+	}
+	return 2
+}
+
+// Sets or clears (with "") the priority of a task
+func (tl *TodoList) SetPriority(id int, priority string) error {
+	priority, err := parsePriority(priority)
+	if err != nil {
+		return err
+	}
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Priority = priority
+			if priority == "" {
+				fmt.Printf(T("Cleared priority of task %d\n"), id)
+			} else {
+				fmt.Printf(T("Task %d is now %s priority\n"), id, priority)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// The orders list --sort takes
+var sortOrders = []string{"id", "title", "due", "priority", "created"}
+
+// Orders tasks for list --sort; the sort is stable so ties keep list order.
+// Open pinned tasks come first and tasks without a due date or created
+// time go last, either way round
+func sortTasks(tasks []Task, by string, reverse bool) error {
+	var key func(a, b Task) int
+	var missing func(t Task) bool
+	switch by {
+	case "", "id":
+		key = func(a, b Task) int { return cmp.Compare(a.ID, b.ID) }
+	case "title":
+		key = func(a, b Task) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) }
+	case "due":
+		missing = func(t Task) bool { return t.Due == "" }
+		key = func(a, b Task) int { return strings.Compare(a.Due, b.Due) }
+	case "priority":
+		key = func(a, b Task) int { return priorityRank(a.Priority) - priorityRank(b.Priority) }
+	case "created":
+		missing = func(t Task) bool { return t.CreatedAt == "" }
+		created := func(t Task) time.Time {
+			at, _ := time.Parse(time.RFC3339, t.CreatedAt)
+			return at
+		}
+		key = func(a, b Task) int { return created(a).Compare(created(b)) }
+	default:
+		return fmt.Errorf(T("unknown sort order %q (use %s)"), by, strings.Join(sortOrders, ", "))
+	}
+	slices.SortStableFunc(tasks, func(a, b Task) int {
+		if pinnedOpen(a) != pinnedOpen(b) {
+			if pinnedOpen(a) {
+				return -1
+			}
+			return 1
+		}
+		if missing != nil && missing(a) != missing(b) {
+			if missing(a) {
+				return 1
+			}
+			return -1
+		}
+		if reverse {
+			return key(b, a)
+		}
+		return key(a, b)
+	})
+	return nil
+}
+
+// Wraps text in an ANSI style when printing to a terminal that wants
+// colour; screen readers and NO_COLOR users get the plain text
+func colorize(s, style string) string {
+	if accessible || os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
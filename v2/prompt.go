@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Asks a yes/no question on the terminal; anything but yes means no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := stdinReader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes" || answer == T("yes")
+}
+
+// Asks a free-form question on the terminal and returns the trimmed,
+// lowercased answer
+func ask(question string) string {
+	fmt.Printf("%s ", question)
+	answer, _ := stdinReader.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(answer))
+}
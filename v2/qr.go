@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"rsc.io/qr"
+)
+
+// Modules of blank margin around the code; scanners need some quiet zone
+const qrQuietZone = 2
+
+// Plain text a phone shows when the code is scanned: the task with its
+// details, or a checklist of several tasks
+func qrText(tasks []Task, now time.Time) string {
+	if len(tasks) == 1 {
+		task := tasks[0]
+		text := task.Title
+		if _, labels := taskDetails(task, now); len(labels) > 0 {
+			text += "\n" + strings.Join(labels, "\n")
+		}
+		for _, link := range task.Attachments {
+			text += "\n" + link
+		}
+		return text
+	}
+	var b strings.Builder
+	for _, task := range tasks {
+		fmt.Fprintf(&b, "[ ] %s\n", task.Title)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Encodes text as a QR code, failing with a hint when it doesn't fit
+func encodeQR(text string) (*qr.Code, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, errors.New(T("too much text for a QR code; pick fewer tasks with filters"))
+	}
+	return code, nil
+}
+
+// Draws the code with half blocks, two modules per character cell so it
+// stays square. Light modules are drawn, which suits dark terminals;
+// invert swaps that for light ones. Terminals without UTF-8 get "##"
+func writeQR(w io.Writer, code *qr.Code, invert bool) {
+	light := func(x, y int) bool { return code.Black(x, y) == invert }
+	from, to := -qrQuietZone, code.Size+qrQuietZone
+	if !utf8Terminal() {
+		for y := from; y < to; y++ {
+			for x := from; x < to; x++ {
+				if light(x, y) {
+					io.WriteString(w, "##")
+				} else {
+					io.WriteString(w, "  ")
+				}
+			}
+			io.WriteString(w, "\n")
+		}
+		return
+	}
+	for y := from; y < to; y += 2 {
+		for x := from; x < to; x++ {
+			top, bottom := light(x, y), y+1 < to && light(x, y+1)
+			switch {
+			case top && bottom:
+				io.WriteString(w, "█")
+			case top:
+				io.WriteString(w, "▀")
+			case bottom:
+				io.WriteString(w, "▄")
+			default:
+				io.WriteString(w, " ")
+			}
+		}
+		io.WriteString(w, "\n")
+	}
+}
+
+// Handles `todo qr [task] [--invert] [-o code.png]`: one task, or the open
+// tasks matching the filter flags, e.g. `todo qr --tag shopping`
+func qrCommand(filename string, args []string) error {
+	var ref string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		ref, args = args[0], args[1:]
+	}
+	qrCmd := flag.NewFlagSet("qr", flag.ExitOnError)
+	invert := qrCmd.Bool("invert", false, "draw dark modules, for light terminal backgrounds")
+	output := qrCmd.String("o", "", "write a PNG image instead of drawing in the terminal")
+	var filter taskFilter
+	filter.register(qrCmd)
+	qrCmd.Parse(args)
+	if ref == "" && qrCmd.NArg() > 0 {
+		ref = qrCmd.Arg(0)
+	}
+
+	tl := loadTodoList(filename)
+	var tasks []Task
+	if ref != "" {
+		id, err := tl.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		task, _ := tl.Find(id)
+		tasks = []Task{task}
+	} else {
+		if filter.status == "" {
+			filter.status = "open"
+		}
+		tasks = tl.Filter(func(task Task) bool {
+			return filter.keep(task) && task.Someday == filter.someday
+		})
+		if len(tasks) == 0 {
+			return errors.New(T("no tasks to share"))
+		}
+	}
+
+	code, err := encodeQR(qrText(tasks, time.Now()))
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return os.WriteFile(*output, code.PNG(), 0o644)
+	}
+	writeQR(os.Stdout, code, *invert)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Shortest prefix shown in list output and accepted when resolving a ref,
+// short enough to type but long enough to rarely collide
+const minRefLen = 3
+
+// Resolve turns a user-supplied reference into a task ID. Numeric IDs take
+// precedence, then full UUIDs; anything else is matched as a hash prefix,
+// like git revisions. A number is only ever an ID, so a mistyped one can't
+// pick out another task by its hash
+func (tl *TodoList) Resolve(ref string) (int, error) {
+	if allDigits(ref) {
+		id, _ := strconv.Atoi(ref)
+		for _, task := range tl.Tasks {
+			if task.ID == id || task.Hash == ref {
+				return task.ID, nil
+			}
+		}
+		return 0, fmt.Errorf(T("task with ID %s not found"), ref)
+	}
+
+	ref = strings.ToLower(ref)
+	for _, task := range tl.Tasks {
+		if task.UUID == ref {
+			return task.ID, nil
+		}
+	}
+	if len(ref) < minRefLen {
+		return 0, fmt.Errorf(T("task with ID %s not found"), ref)
+	}
+	var matches []Task
+	for _, task := range tl.Tasks {
+		if strings.HasPrefix(task.Hash, ref) {
+			matches = append(matches, task)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf(T("task with ID %s not found"), ref)
+	case 1:
+		return matches[0].ID, nil
+	}
+	ids := make([]string, len(matches))
+	for i, task := range matches {
+		ids[i] = strconv.Itoa(task.ID)
+	}
+	return 0, fmt.Errorf(T("ref %s is ambiguous: matches tasks %s"), ref, strings.Join(ids, ", "))
+}
+
+// Returns the shortest unique hash prefix for every task, keyed by ID.
+// Prefixes run on to a letter, as numbers are read as IDs
+func (tl *TodoList) ShortRefs() map[int]string {
+	sorted := make([]Task, len(tl.Tasks))
+	copy(sorted, tl.Tasks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Hash < sorted[j].Hash })
+
+	refs := make(map[int]string, len(sorted))
+	for i, task := range sorted {
+		// In sorted order the longest shared prefix is always with a neighbour
+		n := minRefLen
+		if i > 0 {
+			n = max(n, commonPrefixLen(task.Hash, sorted[i-1].Hash)+1)
+		}
+		if i < len(sorted)-1 {
+			n = max(n, commonPrefixLen(task.Hash, sorted[i+1].Hash)+1)
+		}
+		for n < len(task.Hash) && allDigits(task.Hash[:n]) {
+			n++
+		}
+		refs[task.ID] = task.Hash[:min(n, len(task.Hash))]
+	}
+	return refs
+}
+
+func allDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
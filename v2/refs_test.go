@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+func refsList() *TodoList {
+	return &TodoList{List: todo.List{Tasks: []Task{
+		{ID: 1, UUID: "0b0e9a2c-7f5d-4e21-9d43-5a1b2c3d4e5f", Hash: "a1b2c3d4e5f6a7b8c9d0"},
+		{ID: 2, UUID: "1c1f8b3d-6e4c-4d10-8c32-4b0a1b2c3d4e", Hash: "a1b9f0e1d2c3b4a5f6e7"},
+		{ID: 3, UUID: "2d2a7c4e-5d3b-4cff-bb21-3a9f0a1b2c3d", Hash: "881fe0d1c2b3a4f5e6d7"},
+		{ID: 4, UUID: "3e3b6d5f-4c2a-4bee-aa10-298e9f0a1b2c", Hash: "12345678901234567890"},
+	}}}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{ref: "1", want: 1},
+		{ref: "3", want: 3},
+		// Numbers are IDs, even when a hash starts with them
+		{ref: "881", wantErr: true},
+		{ref: "123", wantErr: true},
+		{ref: "12345678901234567890", want: 4},
+		{ref: "881f", want: 3},
+		{ref: "881FE", want: 3},
+		{ref: "a1b2", want: 1},
+		{ref: "a1b", wantErr: true}, // ambiguous
+		{ref: "a1", wantErr: true},  // too short
+		{ref: "fff", wantErr: true},
+		{ref: "2d2a7c4e-5d3b-4cff-bb21-3a9f0a1b2c3d", want: 3},
+	}
+	tl := refsList()
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := tl.Resolve(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve(%q) = %d, want an error", tt.ref, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve(%q) = %d, %v, want %d", tt.ref, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortRefs(t *testing.T) {
+	tl := refsList()
+	want := map[int]string{1: "a1b2", 2: "a1b9", 3: "881f", 4: "12345678901234567890"}
+	refs := tl.ShortRefs()
+	for id, ref := range want {
+		if refs[id] != ref {
+			t.Errorf("ShortRefs()[%d] = %q, want %q", id, refs[id], ref)
+		}
+		if got, err := tl.Resolve(refs[id]); err != nil || got != id {
+			t.Errorf("Resolve(%q) = %d, %v, want %d", refs[id], got, err, id)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+var syncClient = &http.Client{Timeout: 30 * time.Second}
+
+// Syncs with another machine's `todo serve` through its /sync endpoint.
+// The token comes from TODO_SYNC_TOKEN or the keychain entry service
+// "todo-cli", account "sync"; it needs write access and no field limits
+type serverProvider struct {
+	base  string
+	token string
+}
+
+func newServerProvider(base string) (SyncProvider, error) {
+	if _, err := url.Parse(base); err != nil {
+		return nil, err
+	}
+	p := &serverProvider{base: strings.TrimSuffix(base, "/"), token: os.Getenv("TODO_SYNC_TOKEN")}
+	if p.token == "" {
+		p.token, _ = keychainSecret("todo-cli", "sync")
+	}
+	return p, nil
+}
+
+func (p *serverProvider) Name() string {
+	return "server " + p.base
+}
+
+func (p *serverProvider) Pull() ([]Task, error) {
+	var tasks []Task
+	return tasks, p.call(http.MethodGet, nil, &tasks)
+}
+
+func (p *serverProvider) Push(changes []syncChange) error {
+	return p.call(http.MethodPost, changes, nil)
+}
+
+func (p *serverProvider) Resolve(local, remote Task) Task {
+	return lastWriterWins(local, remote)
+}
+
+// Sends a request to /sync, decoding the answer into out
+func (p *serverProvider) call(method string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, p.base+"/sync", payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := syncClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf(T("%s answered %s: %s"), p.base, resp.Status, apiErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// A todo.json kept somewhere reachable over HTTP, read whole and written
+// back whole. The write only goes through if the file is still the one
+// read (If-Match on its ETag), so two machines pushing at once can't
+// overwrite each other; the loser syncs again
+type remoteFile struct {
+	name string
+	// Reads the file: its contents and ETag, or nil data if it is missing
+	get func() (data []byte, etag string, err error)
+	// Writes the file if it still has the ETag; "" when it was missing
+	put  func(data []byte, etag string) error
+	list *todo.List
+	etag string
+	// Whether the file is written sealed: when our store or the file
+	// already is encrypted
+	encrypt bool
+}
+
+func (p *remoteFile) bindStore(tl *TodoList, filename string) {
+	p.encrypt = storeEncrypted(filename)
+}
+
+func (p *remoteFile) Name() string {
+	return p.name
+}
+
+func (p *remoteFile) Pull() ([]Task, error) {
+	data, etag, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	p.list, p.etag = &todo.List{}, etag
+	if todo.IsEncrypted(data) {
+		key, err := readPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = todo.Decrypt(data, key); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		p.encrypt = true
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, p.list); err != nil {
+			return nil, fmt.Errorf("%s: %w: %w", p.name, todo.ErrCorrupt, err)
+		}
+	}
+	for i := range p.list.Tasks {
+		todo.FillIdentity(&p.list.Tasks[i])
+	}
+	return p.list.Tasks, nil
+}
+
+func (p *remoteFile) Push(changes []syncChange) error {
+	applyChanges(p.list, changes)
+	data, err := json.MarshalIndent(p.list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = sealSidecar(data, p.encrypt); err != nil {
+		return err
+	}
+	return p.put(data, p.etag)
+}
+
+func (p *remoteFile) Resolve(local, remote Task) Task {
+	return lastWriterWins(local, remote)
+}
+
+// Checks the answer to a remote file request. A failed If-Match means
+// another machine wrote the file since it was read
+func checkRemoteResponse(name string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf(T("%s changed while syncing; sync again"), name)
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf(T("%s answered %s: %s"), name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Reads a remote file with req, sent by send
+func getRemoteFile(name string, req *http.Request, send func(*http.Request) (*http.Response, error)) ([]byte, string, error) {
+	resp, err := send(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if err := checkRemoteResponse(name, resp); err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, resp.Header.Get("ETag"), err
+}
+
+// Writes a remote file with req, only over the version with etag, or only
+// if there is none yet
+func putRemoteFile(name string, req *http.Request, etag string, send func(*http.Request) (*http.Response, error)) error {
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	} else {
+		req.Header.Set("If-None-Match", "*")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkRemoteResponse(name, resp)
+}
+
+// A todo.json on a WebDAV server such as Nextcloud. The user and password
+// come from TODO_SYNC_USER and TODO_SYNC_PASSWORD, sync_user and
+// sync_password in the config file, or the URL, which isn't remembered
+func newDAVProvider(target string) (SyncProvider, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return nil, fmt.Errorf(T("invalid WebDAV target %q (want dav[s]://host/path/todo.json)"), syncTargetKey(target))
+	}
+	user := cmp.Or(os.Getenv("TODO_SYNC_USER"), cfg.SyncUser)
+	password := cmp.Or(os.Getenv("TODO_SYNC_PASSWORD"), cfg.SyncPassword)
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+		u.User = nil
+	}
+	send := func(req *http.Request) (*http.Response, error) {
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		return syncClient.Do(req)
+	}
+	name := "webdav " + u.String()
+	return &remoteFile{
+		name: name,
+		get: func() ([]byte, string, error) {
+			req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+			if err != nil {
+				return nil, "", err
+			}
+			return getRemoteFile(name, req, send)
+		},
+		put: func(data []byte, etag string) error {
+			req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			return putRemoteFile(name, req, etag, send)
+		},
+	}, nil
+}
+
+// A todo.json as an object in an S3 bucket, s3://bucket/key. Credentials
+// come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+// the region from AWS_REGION; AWS_ENDPOINT_URL points at other S3
+// compatible services such as MinIO
+func newS3Provider(target string) (SyncProvider, error) {
+	bucket, key, ok := strings.Cut(target, "/")
+	if !ok || bucket == "" || key == "" {
+		return nil, fmt.Errorf(T("invalid S3 target %q (want s3://bucket/key)"), "s3://"+target)
+	}
+	creds := s3Credentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil, errors.New(T("S3 sync needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY"))
+	}
+	object := "https://" + bucket + ".s3." + creds.region + ".amazonaws.com/" + s3Escape(key)
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		// Path style, which S3 compatible services all take
+		object = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + s3Escape(key)
+	}
+	name := "s3 " + bucket + "/" + key
+	send := func(req *http.Request) (*http.Response, error) {
+		if err := creds.sign(req, time.Now()); err != nil {
+			return nil, err
+		}
+		return syncClient.Do(req)
+	}
+	return &remoteFile{
+		name: name,
+		get: func() ([]byte, string, error) {
+			req, err := http.NewRequest(http.MethodGet, object, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			return getRemoteFile(name, req, send)
+		},
+		put: func(data []byte, etag string) error {
+			req, err := http.NewRequest(http.MethodPut, object, bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			return putRemoteFile(name, req, etag, send)
+		},
+	}, nil
+}
+
+type s3Credentials struct {
+	accessKey, secretKey, sessionToken, region string
+}
+
+// Signs a request with AWS Signature Version 4
+func (c s3Credentials) sign(req *http.Request, now time.Time) error {
+	var payload []byte
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if payload, err = io.ReadAll(body); err != nil {
+			return err
+		}
+	}
+	sum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(sum[:])
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "if-match" || lower == "if-none-match" || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Escapes an object key the way S3 signs it: every byte but unreserved
+// characters, keeping the slashes
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// Registers the output formats of list, search, show and done. Commands
+// only look formats up by the --format name, so a format registered here,
+// or by a program embedding the library, needs no command changes
+func registerRenderers() {
+	todo.RegisterRenderer("table", plainRenderer(renderTable))
+	todo.RegisterRenderer("json", plainRenderer(func(w io.Writer, _ *todo.List, tasks []Task) error {
+		return exportJSON(w, tasks, nil)
+	}))
+	todo.RegisterRenderer("csv", plainRenderer(func(w io.Writer, _ *todo.List, tasks []Task) error {
+		return exportCSV(w, tasks, nil)
+	}))
+	todo.RegisterRenderer("markdown", plainRenderer(renderMarkdown))
+	todo.RegisterRenderer("cell", plainRenderer(renderCells))
+	todo.RegisterRenderer("template", newTemplateRenderer)
+}
+
+// A factory for a format that takes no argument
+func plainRenderer(f todo.RenderFunc) todo.RendererFactory {
+	return func(arg string) (todo.Renderer, error) {
+		if arg != "" {
+			return nil, errors.New(T("this output format takes no argument"))
+		}
+		return f, nil
+	}
+}
+
+// Writes tasks to stdout in the --format picked
+func renderTasks(tl *TodoList, tasks []Task) error {
+	r, err := todo.LookupRenderer(outputFormat)
+	if err != nil {
+		return err
+	}
+	return r.Render(os.Stdout, &tl.List, tasks)
+}
+
+// Checks the --format value, with the formats to pick from if it's unknown
+func checkOutputFormat(format string) error {
+	_, err := todo.LookupRenderer(format)
+	if errors.Is(err, todo.ErrUnknownFormat) {
+		name, _, _ := strings.Cut(format, ":")
+		return fmt.Errorf(T("unknown output format %q (available: %s)"), name, strings.Join(todo.RendererNames(), ", "))
+	}
+	return err
+}
+
+// The table list and search print, as a tree
+func renderTable(w io.Writer, l *todo.List, tasks []Task) error {
+	tl := &TodoList{List: *l}
+	ordered, depth := treeOrder(tasks)
+	tl.writeTasks(w, ordered, depth)
+	return nil
+}
+
+// A Markdown task list, subtasks nested under their parents
+func renderMarkdown(w io.Writer, _ *todo.List, tasks []Task) error {
+	ordered, depth := treeOrder(tasks)
+	now := time.Now()
+	for _, task := range ordered {
+		box := " "
+		if task.Completed {
+			box = "x"
+		}
+		line := fmt.Sprintf("%s- [%s] %s", strings.Repeat("  ", depth[task.ID]), box, task.Title)
+		if marks, _ := taskDetails(task, now); len(marks) > 0 {
+			line += " — " + strings.Join(marks, ", ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// One line per task as the interactive list shows it, for status bars and
+// pickers such as fzf
+func renderCells(w io.Writer, _ *todo.List, tasks []Task) error {
+	ordered, depth := treeOrder(tasks)
+	mark, now := doneMark(), time.Now()
+	for _, task := range ordered {
+		if _, err := fmt.Fprintln(w, taskCell(task, depth[task.ID], mark, now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// template:<text> runs a Go template once per task, e.g.
+// --format 'template:{{.ID}} {{.Title}} {{join .Tags ","}}'. Each task
+// ends a line unless the template ends with a newline itself
+func newTemplateRenderer(text string) (todo.Renderer, error) {
+	if text == "" {
+		return nil, errors.New(T("template format needs a template, e.g. template:{{.ID}} {{.Title}}"))
+	}
+	tmpl, err := template.New("format").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return todo.RenderFunc(func(w io.Writer, _ *todo.List, tasks []Task) error {
+		for _, task := range tasks {
+			if err := tmpl.Execute(w, task); err != nil {
+				return err
+			}
+			if !strings.HasSuffix(text, "\n") {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}), nil
+}
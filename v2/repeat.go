@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named recurrence rules and what they stand for
+var repeatNames = map[string]string{
+	"daily":    "every:1d",
+	"weekly":   "every:1w",
+	"monthly":  "every:1m",
+	"yearly":   "every:1y",
+	"workdays": "every:1b",
+}
+
+var repeatRe = regexp.MustCompile(`^every:(\d+)([bdwmy])$`)
+
+// Checks a --repeat rule: daily, weekly, monthly, yearly, workdays or
+// every:N with a unit of d, w, m, y or b for working days (every:3d,
+// every:2w). The names can also be given
+// in the languages parseDate reads ("wöchentlich"). "" means no recurrence
+func parseRepeat(rule string) (string, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if rule == "" {
+		return "", nil
+	}
+	if _, ok := repeatNames[rule]; ok {
+		return rule, nil
+	}
+	for _, lang := range dateLanguages() {
+		if name := translateDateWords(rule, lang); repeatNames[name] != "" {
+			return name, nil
+		}
+	}
+	if m := repeatRe.FindStringSubmatch(rule); m != nil {
+		if n, _ := strconv.Atoi(m[1]); n > 0 {
+			return rule, nil
+		}
+	}
+	return "", fmt.Errorf(T("invalid repeat rule %q (use daily, weekly, monthly, yearly, workdays or every:N with d, w, m, y or b, e.g. every:3d)"), rule)
+}
+
+// Moves a date on by one interval of a valid rule. Months and years are
+// clamped to the end of the month, so monthly from Jan 31 gives Feb 28.
+// An occurrence on a holiday is skipped for the one after it
+func nextOccurrence(rule string, from time.Time) time.Time {
+	next := addInterval(rule, from)
+	// Bounded, in case custom holidays cover every occurrence of a rule
+	for range 100 {
+		if _, holiday := holidayOn(next); !holiday {
+			break
+		}
+		next = addInterval(rule, next)
+	}
+	return next
+}
+
+func addInterval(rule string, from time.Time) time.Time {
+	if named, ok := repeatNames[rule]; ok {
+		rule = named
+	}
+	m := repeatRe.FindStringSubmatch(rule)
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "b":
+		return addBusinessDays(from, n)
+	case "d":
+		return from.AddDate(0, 0, n)
+	case "w":
+		return from.AddDate(0, 0, 7*n)
+	case "m":
+		return addMonths(from, n)
+	default:
+		return addMonths(from, 12*n)
+	}
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// The due date of the occurrence after a task. The schedule keeps to the
+// due date when the task is done on time; done late, the next one counts
+// from today so a missed week doesn't leave it overdue straight away
+func nextDue(task Task, now time.Time) string {
+	today, _ := time.Parse(dateLayout, now.Format(dateLayout))
+	from := today
+	if due, err := time.Parse(dateLayout, task.Due); err == nil && !due.Before(today) {
+		from = due
+	}
+	return nextOccurrence(task.Repeat, from).Format(dateLayout)
+}
+
+// Adds the next occurrence of a completed recurring task: an open copy
+// with a new identity and the next due date
+func (tl *TodoList) addNextOccurrence(done Task, now time.Time) (Task, error) {
+	next := done
+	next.ID, next.UUID, next.Hash = 0, "", ""
+	next.Completed, next.CompletedAt = false, ""
+	next.Due = nextDue(done, now)
+	next.Attachments = slices.Clone(done.Attachments)
+	next.Tags = slices.Clone(done.Tags)
+	next.Fields = maps.Clone(done.Fields)
+	return tl.add(next)
+}
+
+// The first count due dates of a rule starting on start, each worked out
+// from the one before, as completing every occurrence on time would
+func dueDates(rule, start string, count int) []string {
+	var dates []string
+	due := start
+	for range count {
+		dates = append(dates, due)
+		next, _ := time.Parse(dateLayout, due)
+		due = nextOccurrence(rule, next).Format(dateLayout)
+	}
+	return dates
+}
+
+// Handles `todo recurrence preview <task|rule> [--count 10] [--from date]`:
+// lists the due dates a task's rule, or a rule not yet given to any task,
+// will produce
+func recurrenceCommand(filename string, args []string) error {
+	if len(args) == 0 || args[0] != "preview" {
+		return errors.New(T("usage: todo recurrence preview <task-id|ref|rule> [--count 10] [--from date]"))
+	}
+	previewCmd := flag.NewFlagSet("recurrence preview", flag.ExitOnError)
+	count := previewCmd.Int("count", 10, "how many occurrences to show")
+	from := previewCmd.String("from", "", "first due date (default: the task's due date, else today)")
+	rest := parseInterspersed(previewCmd, args[1:])
+	if len(rest) != 1 {
+		return errors.New(T("usage: todo recurrence preview <task-id|ref|rule> [--count 10] [--from date]"))
+	}
+	now := time.Now()
+	start := now.Format(dateLayout)
+	var rule string
+	tl := loadTodoList(filename)
+	if id, err := tl.Resolve(rest[0]); err == nil {
+		task, _ := tl.Find(id)
+		if task.Repeat == "" {
+			return fmt.Errorf(T("task %d doesn't repeat; give it a rule with set %d repeat=weekly"), id, id)
+		}
+		rule, start = task.Repeat, cmp.Or(task.Due, start)
+		fmt.Printf(T("Task %d, %s, repeats %s:\n"), task.ID, task.Title, rule)
+	} else {
+		if rule, err = parseRepeat(rest[0]); err != nil {
+			return err
+		}
+		fmt.Printf(T("Repeating %s:\n"), rule)
+	}
+	if *from != "" {
+		var err error
+		if start, err = parseDate(*from, now); err != nil {
+			return err
+		}
+	}
+	for i, due := range dueDates(rule, start, max(*count, 1)) {
+		day, _ := time.Parse(dateLayout, due)
+		fmt.Printf("%3d  %s  %s\n", i+1, due, day.Format("Mon"))
+	}
+	return nil
+}
+
+// Prints the next few due dates of every open recurring task
+func (tl *TodoList) PrintUpcoming(count int, now time.Time) {
+	tasks := tl.Filter(func(task Task) bool { return task.Repeat != "" && !task.Completed })
+	if len(tasks) == 0 {
+		fmt.Println(T("No recurring tasks."))
+		return
+	}
+	for _, task := range tasks {
+		dates := dueDates(task.Repeat, cmp.Or(task.Due, now.Format(dateLayout)), count)
+		fmt.Printf("%2d %s (%s): %s\n", task.ID, task.Title, task.Repeat, strings.Join(dates, ", "))
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A tagging rule from the rules file: new tasks whose title matches get
+// its tags, and its priority, context and effort where they have none
+type rule struct {
+	Name     string   `json:"name"`
+	Match    string   `json:"match"`
+	Tags     []string `json:"tags,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	Context  string   `json:"context,omitempty"`
+	Effort   string   `json:"effort,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Where the rules are kept: $TODO_RULES_FILE or rules.json in the user's
+// config directory
+func rulesFile() string {
+	if path := cmp.Or(os.Getenv("TODO_RULES_FILE"), cfg.RulesFile); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "rules.json")
+}
+
+// The rules, read and checked once per run; no file means no rules
+var loadRules = sync.OnceValues(func() ([]rule, error) {
+	path := rulesFile()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf(T("rules file %s: %v"), path, err)
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprint(i + 1)
+		}
+		if r.Match == "" {
+			return nil, fmt.Errorf(T("rule %s: no match pattern"), r.Name)
+		}
+		// Checked as written so errors don't show the added (?i)
+		if _, err := regexp.Compile(r.Match); err != nil {
+			return nil, fmt.Errorf(T("rule %s: %v"), r.Name, err)
+		}
+		r.re = regexp.MustCompile("(?i)" + r.Match)
+		if r.Tags, err = parseTags(r.Tags); err == nil {
+			if r.Priority, err = parsePriority(r.Priority); err == nil {
+				r.Effort, err = parseEffort(r.Effort)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf(T("rule %s: %v"), r.Name, err)
+		}
+		r.Context = normalizeContext(r.Context)
+	}
+	return rules, nil
+})
+
+// Applies the rules whose pattern matches the task's title, returning the
+// task and the rules that fired. Values already set on the task win
+func applyRules(task Task, rules []rule) (Task, []rule) {
+	var fired []rule
+	for _, r := range rules {
+		if !r.re.MatchString(task.Title) {
+			continue
+		}
+		fired = append(fired, r)
+		task.Tags = union(task.Tags, r.Tags)
+		if task.Priority == "" {
+			task.Priority = r.Priority
+		}
+		if task.Context == "" {
+			task.Context = r.Context
+		}
+		if task.Effort == "" {
+			task.Effort = r.Effort
+		}
+	}
+	return task, fired
+}
+
+// Runs a new task through the rules. A broken rules file is reported but
+// doesn't stop tasks from being added
+func withRules(task Task) Task {
+	rules, err := loadRules()
+	if err != nil {
+		slog.Warn("rules not applied", "err", err)
+		return task
+	}
+	task, fired := applyRules(task, rules)
+	for _, r := range fired {
+		slog.Info("rule fired", "rule", r.Name, "task", task.Title)
+	}
+	return task
+}
+
+// What a rule does to a task, e.g. "#finance, high priority"
+func describeRule(r rule) string {
+	var effects []string
+	if len(r.Tags) > 0 {
+		effects = append(effects, formatTags(r.Tags))
+	}
+	if r.Priority != "" {
+		effects = append(effects, fmt.Sprintf(T("%s priority"), r.Priority))
+	}
+	if r.Context != "" {
+		effects = append(effects, r.Context)
+	}
+	if r.Effort != "" {
+		effects = append(effects, fmt.Sprintf(T("%s effort"), r.Effort))
+	}
+	return strings.Join(effects, ", ")
+}
+
+// Handles `todo rules [list]` and `todo rules test "<title>"`
+func rulesCommand(args []string) error {
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	switch sub {
+	case "list":
+		if len(rules) == 0 {
+			fmt.Printf(T("No rules in %s\n"), rulesFile())
+			return nil
+		}
+		for _, r := range rules {
+			fmt.Printf(T("%s: /%s/ → %s\n"), r.Name, r.Match, describeRule(r))
+		}
+		return nil
+
+	case "test":
+		title := normalizeTitle(strings.Join(args, " "))
+		if title == "" {
+			return errors.New(T("usage: todo rules test \"<title>\""))
+		}
+		_, fired := applyRules(Task{Title: title}, rules)
+		if len(fired) == 0 {
+			fmt.Printf(T("No rules fire for %q\n"), title)
+			return nil
+		}
+		for _, r := range fired {
+			fmt.Printf(T("%s fires: %s\n"), r.Name, describeRule(r))
+		}
+		return nil
+	}
+	return fmt.Errorf(T("unknown rules command %q"), sub)
+}
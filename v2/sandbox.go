@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Handles `todo sandbox [--hooks]`: copies the store with its sidecar
+// files to a temporary directory and runs commands typed at a prompt
+// against the copy. `commit` writes the copy back, `discard` or end of
+// input drops it. Hooks stay off unless --hooks is given, so experiments
+// don't notify anyone
+func sandboxCommand(filename string, args []string) error {
+	sandboxCmd := flag.NewFlagSet("sandbox", flag.ExitOnError)
+	hooks := sandboxCmd.Bool("hooks", false, "run hooks for changes made in the sandbox")
+	sandboxCmd.Parse(args)
+	if os.Getenv("TODO_SANDBOX") != "" {
+		return errors.New(T("already in a sandbox"))
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "todo-sandbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	copyPath := filepath.Join(dir, filepath.Base(filename))
+	if err := copyStoreFiles(filename, copyPath); err != nil {
+		return err
+	}
+	original, err := snapshotStore(filename)
+	if err != nil {
+		return err
+	}
+
+	global := []string{"--file", copyPath, "--backend", backend, "--format", outputFormat}
+	if accessible {
+		global = append(global, "--accessible")
+	}
+	env := append(os.Environ(), "TODO_SANDBOX=1")
+	if !*hooks {
+		env = append(env, "TODO_HOOKS_DIR="+filepath.Join(dir, "no-hooks"))
+	}
+	fmt.Println(T("Sandbox: commands run against a copy of the store. Type commit to keep the changes, discard to drop them, diff to review them."))
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("sandbox> ")
+		if !in.Scan() {
+			fmt.Println()
+			fmt.Println(T("Discarded the sandbox"))
+			return in.Err()
+		}
+		words, err := splitCommandLine(in.Text())
+		if err != nil {
+			fmt.Printf(T("Error: %v\n"), err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "commit":
+			force := len(words) > 1 && words[1] == "--force"
+			if err := commitSandbox(filename, copyPath, original, force); err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+				continue
+			}
+			fmt.Println(T("Committed the sandbox to the store"))
+			return nil
+		case "discard", "exit", "quit":
+			fmt.Println(T("Discarded the sandbox"))
+			return nil
+		case "diff":
+			if err := printStoreDiff(filename, copyPath); err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+			}
+			continue
+		case "sandbox":
+			fmt.Printf(T("Error: %v\n"), T("already in a sandbox"))
+			continue
+		}
+		cmd := exec.Command(self, append(global, words...)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Env = env
+		// A failing command only ends its own run, as in a shell
+		cmd.Run()
+	}
+}
+
+// The store file and the files next to it that share its name, such as
+// the undo journal, inbox and attachments. The lock and the usage stats
+// are left out; they belong to whoever is running commands
+func storeFiles(filename string) ([]string, error) {
+	stem := strings.TrimSuffix(filename, ".json")
+	siblings, err := filepath.Glob(stem + ".*")
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(siblings, filename) {
+		siblings = append(siblings, filename)
+	}
+	var files []string
+	for _, path := range siblings {
+		if _, err := os.Stat(path); err == nil && path != lockPath(filename) && path != statsPath(filename) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// Copies the store's files to sit next to dst under the same names
+func copyStoreFiles(src, dst string) error {
+	files, err := storeFiles(src)
+	if err != nil {
+		return err
+	}
+	srcStem, dstStem := strings.TrimSuffix(src, ".json"), strings.TrimSuffix(dst, ".json")
+	for _, path := range files {
+		target := dstStem + strings.TrimPrefix(path, srcStem)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			os.RemoveAll(target)
+			err = os.CopyFS(target, os.DirFS(path))
+		} else {
+			err = copyFile(path, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0600)
+}
+
+// The contents of the store's files, to notice changes made outside the
+// sandbox while it was open
+func snapshotStore(filename string) (map[string][]byte, error) {
+	files, err := storeFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string][]byte{}
+	for _, path := range files {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		if snapshot[path], err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// Writes the sandbox back over the store. It refuses if the store changed
+// since the sandbox opened, unless forced, as those changes would be lost
+func commitSandbox(filename, copyPath string, original map[string][]byte, force bool) error {
+	unlock, err := lockStore(filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !force {
+		current, err := snapshotStore(filename)
+		if err != nil {
+			return err
+		}
+		for path, data := range current {
+			if !bytes.Equal(data, original[path]) {
+				return fmt.Errorf(T("%s changed since the sandbox opened; commit --force overwrites those changes"), path)
+			}
+		}
+	}
+	// Files the sandbox got rid of, such as a merged inbox, go here too
+	files, err := storeFiles(filename)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		copied := strings.TrimSuffix(copyPath, ".json") + strings.TrimPrefix(path, strings.TrimSuffix(filename, ".json"))
+		if _, err := os.Stat(copied); os.IsNotExist(err) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+	}
+	return copyStoreFiles(copyPath, filename)
+}
+
+// Lists the tasks the sandbox added, deleted and changed
+func printStoreDiff(filename, copyPath string) error {
+	before, after := &TodoList{}, &TodoList{}
+	if err := before.LoadFromFile(filename); err != nil {
+		return err
+	}
+	if err := after.LoadFromFile(copyPath); err != nil {
+		return err
+	}
+	byUUID := map[string]Task{}
+	for _, task := range before.Tasks {
+		byUUID[task.UUID] = task
+	}
+	changes := 0
+	for _, task := range after.Tasks {
+		old, ok := byUUID[task.UUID]
+		delete(byUUID, task.UUID)
+		switch {
+		case !ok:
+			fmt.Printf(T("  added    %d %s\n"), task.ID, task.Title)
+		case !sameTask(old, task) || old.ID != task.ID:
+			fmt.Printf(T("  changed  %d %s\n"), task.ID, task.Title)
+		default:
+			continue
+		}
+		changes++
+	}
+	for _, task := range before.Tasks {
+		if _, ok := byUUID[task.UUID]; ok {
+			fmt.Printf(T("  deleted  %d %s\n"), task.ID, task.Title)
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Println(T("  no changes"))
+	}
+	return nil
+}
+
+// Splits a typed command line into words like a shell: quotes group
+// words, and a backslash escapes the next character
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New(T("unfinished quote or escape"))
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A TODO(owner): text comment after // or #. The owner may be empty
+var todoCommentRe = regexp.MustCompile(`(?://|#)\s*TODO\(([^)]*)\):\s*(.*\S)`)
+
+// Files larger than this are skipped, as generated or data files
+const maxScanSize = 1 << 20
+
+// A TODO comment found by scan
+type codeComment struct {
+	file  string // slash-separated, relative to the repo root
+	line  int
+	owner string
+	text  string
+}
+
+func (c codeComment) ref() string {
+	return c.file + ":" + strconv.Itoa(c.line)
+}
+
+// Handles `todo scan [path]`: keeps a task for each TODO(owner): comment
+// under path. New comments become tasks, moved or reworded ones update
+// theirs, and tasks whose comment is gone are completed. Tasks carry the
+// comment's place in code_ref and the repo and commit it was seen at
+func scanCommand(filename string, args []string) error {
+	scanCmd := flag.NewFlagSet("scan", flag.ExitOnError)
+	rest := parseInterspersed(scanCmd, args)
+	if len(rest) > 1 {
+		return errors.New(T("usage: todo scan [path]"))
+	}
+	dir := "."
+	if len(rest) == 1 {
+		dir = rest[0]
+	}
+	// Resolved like git resolves the work tree, so paths relate to its root
+	dir, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		dir, err = filepath.Abs(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf(T("%s is not a directory"), dir)
+	}
+	root, repo, commit := gitInfo(dir)
+	scope, err := filepath.Rel(root, dir)
+	if err != nil {
+		return err
+	}
+	scope = filepath.ToSlash(scope)
+	files, err := sourceFiles(root, dir)
+	if err != nil {
+		return err
+	}
+	var comments []codeComment
+	for _, file := range files {
+		found, err := scanFile(root, file)
+		if err != nil {
+			return err
+		}
+		comments = append(comments, found...)
+	}
+
+	tl := loadTodoList(filename)
+	added, updated, done := 0, 0, 0
+	err = tl.Transaction(filename, func() error {
+		// Tasks of earlier scans that this one covers
+		tracked := tl.Filter(func(task Task) bool {
+			file, _, ok := strings.Cut(task.Fields["code_ref"], ":")
+			return ok && task.Fields["repo"] == repo && (scope == "." || file == scope || strings.HasPrefix(file, scope+"/"))
+		})
+		used := map[int]bool{}
+		for _, c := range comments {
+			task, ok := matchComment(tracked, used, c)
+			if !ok {
+				fields := map[string]string{"code_ref": c.ref(), "repo": repo}
+				if commit != "" {
+					fields["commit"] = commit
+				}
+				if c.owner != "" {
+					fields["code_owner"] = c.owner
+				}
+				if err := tl.AddTaskFrom(Task{Title: c.text, Fields: fields}); err != nil {
+					return err
+				}
+				added++
+				continue
+			}
+			used[task.ID] = true
+			before := task
+			task.Title = normalizeTitle(c.text)
+			task.Fields = maps.Clone(task.Fields)
+			task.Fields = setField(task.Fields, "code_ref", c.ref())
+			task.Fields = setField(task.Fields, "code_owner", c.owner)
+			if task.Completed {
+				// The comment came back, so the work isn't done after all
+				task.Completed, task.CompletedAt = false, ""
+			}
+			// The commit only moves along with a change, so it tells when
+			// the comment last did
+			if sameTask(before, task) {
+				continue
+			}
+			task.Fields = setField(task.Fields, "commit", commit)
+			if err := tl.Update(task); err != nil {
+				return err
+			}
+			if before.Completed {
+				fmt.Printf(T("Reopened task %d: %s (%s)\n"), task.ID, task.Title, c.ref())
+			} else {
+				fmt.Printf(T("Updated task %d: %s (%s)\n"), task.ID, task.Title, c.ref())
+			}
+			updated++
+		}
+		for _, task := range tracked {
+			if used[task.ID] || task.Completed {
+				continue
+			}
+			if err := tl.CompleteTask(task.ID); err != nil {
+				return err
+			}
+			done++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf(T("Scanned %d files, found %d TODO comments: %d added, %d updated, %d completed\n"), len(files), len(comments), added, updated, done)
+	return nil
+}
+
+// The tracked task for a comment: first one with the same file and text,
+// closest to the line as repeated comments keep their order, then one at
+// the same place whose text was reworded
+func matchComment(tracked []Task, used map[int]bool, c codeComment) (Task, bool) {
+	best, bestDistance := -1, 0
+	for i, task := range tracked {
+		file, line, _ := strings.Cut(task.Fields["code_ref"], ":")
+		if used[task.ID] || file != c.file || task.Title != normalizeTitle(c.text) {
+			continue
+		}
+		n, _ := strconv.Atoi(line)
+		if distance := max(n-c.line, c.line-n); best < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	if best >= 0 {
+		return tracked[best], true
+	}
+	for _, task := range tracked {
+		if !used[task.ID] && task.Fields["code_ref"] == c.ref() {
+			return task, true
+		}
+	}
+	return Task{}, false
+}
+
+// Sets a custom field, or removes it for an empty value
+func setField(fields map[string]string, key, value string) map[string]string {
+	if value == "" {
+		delete(fields, key)
+		return fields
+	}
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	fields[key] = value
+	return fields
+}
+
+// The root of the git work tree holding dir, the repo's origin URL (or the
+// root's path without one) and the checked-out commit. Outside git, dir is
+// the root and there is no commit
+func gitInfo(dir string) (root, repo, commit string) {
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+	root = git("rev-parse", "--show-toplevel")
+	if root == "" {
+		return dir, filepath.ToSlash(dir), ""
+	}
+	repo = git("remote", "get-url", "origin")
+	if repo == "" {
+		repo = filepath.ToSlash(root)
+	}
+	return root, repo, git("rev-parse", "HEAD")
+}
+
+// The files under dir to scan. In a git work tree these are the tracked
+// files and untracked ones not ignored; elsewhere every file, skipping
+// hidden directories and vendored dependencies
+func sourceFiles(root, dir string) ([]string, error) {
+	out, err := exec.Command("git", "-C", dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard", "--full-name").Output()
+	if err == nil {
+		var files []string
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name != "" {
+				files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+			}
+		}
+		return files, nil
+	}
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// The TODO comments in one file; large and binary files have none
+func scanFile(root, path string) ([]codeComment, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		// Deleted but not yet staged in git
+		return nil, nil
+	}
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxScanSize {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	var comments []codeComment
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(nil, maxScanSize)
+	for n := 1; lines.Scan(); n++ {
+		m := todoCommentRe.FindStringSubmatch(lines.Text())
+		if m == nil {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(m[2], "*/"), "-->"))
+		if text == "" {
+			continue
+		}
+		comments = append(comments, codeComment{filepath.ToSlash(rel), n, strings.TrimSpace(m[1]), text})
+	}
+	return comments, lines.Err()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Scripts run by `todo run <file.star> [args...]` are Starlark (a Python
+// dialect) and see these predeclared names:
+//
+//	args              tuple of the extra command-line arguments
+//	tasks()           list of all tasks, in list order
+//	add(title)        adds a task and returns it
+//	complete(ref)     marks a task done; ref is an ID, UUID or hash prefix
+//	delete(ref)       deletes a task
+//
+// Tasks are read-only structs with the same fields as the JSON store
+// (t.id, t.uuid, t.hash, t.title, t.completed, ...). Changes made by a
+// script are saved once when it finishes, and discarded if it fails.
+func runScript(tl *TodoList, filename, script string, args []string) error {
+	changed := false
+	backup := tl.List.Clone()
+
+	resolve := func(b *starlark.Builtin, ref starlark.Value) (int, error) {
+		switch ref := ref.(type) {
+		case starlark.Int:
+			id, ok := ref.Int64()
+			if !ok {
+				return 0, fmt.Errorf("%s: ID out of range", b.Name())
+			}
+			return tl.Resolve(strconv.FormatInt(id, 10))
+		case starlark.String:
+			return tl.Resolve(string(ref))
+		}
+		return 0, fmt.Errorf("%s: want an ID or ref, got %s", b.Name(), ref.Type())
+	}
+
+	predeclared := starlark.StringDict{
+		"args": argsTuple(args),
+		"tasks": starlark.NewBuiltin("tasks", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+				return nil, err
+			}
+			list := make([]starlark.Value, len(tl.Tasks))
+			for i, task := range tl.Tasks {
+				list[i] = taskValue(task)
+			}
+			return starlark.NewList(list), nil
+		}),
+		"add": starlark.NewBuiltin("add", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var title string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "title", &title); err != nil {
+				return nil, err
+			}
+			if err := tl.AddTask(title); err != nil {
+				return nil, err
+			}
+			changed = true
+			return taskValue(tl.Tasks[len(tl.Tasks)-1]), nil
+		}),
+		"complete": starlark.NewBuiltin("complete", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var ref starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "ref", &ref); err != nil {
+				return nil, err
+			}
+			id, err := resolve(b, ref)
+			if err == nil {
+				err = tl.CompleteTask(id)
+			}
+			if err != nil {
+				return nil, err
+			}
+			changed = true
+			return starlark.None, nil
+		}),
+		"delete": starlark.NewBuiltin("delete", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var ref starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "ref", &ref); err != nil {
+				return nil, err
+			}
+			id, err := resolve(b, ref)
+			if err == nil {
+				err = tl.DeleteTask(id)
+			}
+			if err != nil {
+				return nil, err
+			}
+			changed = true
+			return starlark.None, nil
+		}),
+	}
+
+	thread := &starlark.Thread{
+		Name:  script,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+	}
+	// Scripts are small reports, so allow plain top-level loops and ifs
+	opts := &syntax.FileOptions{TopLevelControl: true, GlobalReassign: true, While: true, Set: true}
+	_, err := starlark.ExecFileOptions(opts, thread, script, nil, predeclared)
+	if err != nil {
+		tl.List = *backup
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return fmt.Errorf("%s", evalErr.Backtrace())
+		}
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	return tl.Transaction(filename, func() error { return nil })
+}
+
+func argsTuple(args []string) starlark.Tuple {
+	tuple := make(starlark.Tuple, len(args))
+	for i, arg := range args {
+		tuple[i] = starlark.String(arg)
+	}
+	return tuple
+}
+
+// Exposes a task to scripts with the same field names as the JSON store,
+// so new fields show up in scripts without extra wiring
+func taskValue(task Task) starlark.Value {
+	data, _ := json.Marshal(task)
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+	dict := make(starlark.StringDict, len(fields))
+	for name, value := range fields {
+		dict[name] = jsonValue(value)
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, dict)
+}
+
+func jsonValue(v any) starlark.Value {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i, item := range v {
+			list[i] = jsonValue(item)
+		}
+		return starlark.NewList(list)
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for key, item := range v {
+			dict.SetKey(starlark.String(key), jsonValue(item))
+		}
+		return dict
+	}
+	return starlark.None
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Returns a title matcher for `todo search`: a case-insensitive substring
+// match, or a case-insensitive regular expression with regex set
+func titleMatcher(query string, regex bool) (func(title string) bool, error) {
+	if regex {
+		// Check the query as typed, so errors don't quote the added flag
+		if _, err := regexp.Compile(query); err != nil {
+			return nil, fmt.Errorf(T("invalid regular expression: %v"), err)
+		}
+		re := regexp.MustCompile("(?i)" + query)
+		return func(title string) bool {
+			return re.MatchString(normalizeTitle(title))
+		}, nil
+	}
+	query = strings.ToLower(normalizeTitle(query))
+	return func(title string) bool {
+		return strings.Contains(strings.ToLower(normalizeTitle(title)), query)
+	}, nil
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// REST API over the store, for web frontends and phone shortcuts:
+//
+//	GET    /tasks          list tasks; ?filter= takes the set --filter terms
+//	POST   /tasks          add a task
+//	GET    /tasks/{ref}    one task, by ID, ref or UUID
+//	PATCH  /tasks/{ref}    change a task; only the fields given change
+//	DELETE /tasks/{ref}    delete a task; its subtasks move up a level
+//	GET    /feed.ics       due tasks as an iCalendar feed to subscribe to
+//	GET    /sync           every task, whole, for todo sync on another machine
+//	POST   /sync           apply the adds and updates a todo sync sends
+//
+// Changes go through the same hooks, rules and undo journal as the CLI
+type taskServer struct {
+	filename string
+	token    string
+	// Whether requests need no token: only until a token is first created.
+	// Revoking the last one locks the server rather than opening it
+	open atomic.Bool
+	// Requests run one at a time, like separate CLI invocations
+	mu sync.Mutex
+}
+
+// Body of POST and PATCH. Fields left out stay as they are; "" clears a
+// field, and a null custom field deletes it
+type taskRequest struct {
+	Title     *string            `json:"title"`
+	Notes     *string            `json:"notes"`
+	Completed *bool              `json:"completed"`
+	Priority  *string            `json:"priority"`
+	Due       *string            `json:"due"`
+	Context   *string            `json:"context"`
+	Effort    *string            `json:"effort"`
+	Repeat    *string            `json:"repeat"`
+	Tags      *[]string          `json:"tags"`
+	Fields    map[string]*string `json:"fields"`
+}
+
+// An error with the HTTP status it is answered with
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return apiError{http.StatusBadRequest, err}
+}
+
+// Handles `todo serve [--addr 127.0.0.1] [--port 8080]`. Requests need
+// "Authorization: Bearer <token>", or ?token= on /feed.ics for calendar
+// apps, once TODO_SERVE_TOKEN is set or `todo token create` has made a
+// token; one of them is needed to listen on anything but the loopback
+// address. Bodies must be JSON, and requests from other sites' pages are
+// turned away
+func serveCommand(filename string, args []string) error {
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveCmd.String("addr", "127.0.0.1", "address to listen on")
+	port := serveCmd.Int("port", 8080, "port to listen on")
+	serveCmd.Parse(args)
+	s := &taskServer{filename: filename, token: os.Getenv("TODO_SERVE_TOKEN")}
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	s.open.Store(s.token == "" && !tokensSetUp())
+	if s.token == "" && !s.open.Load() && len(tokens) == 0 {
+		return errors.New(T("every token has been revoked; run todo token create or set TODO_SERVE_TOKEN"))
+	}
+	if ip := net.ParseIP(*addr); s.open.Load() && (ip == nil || !ip.IsLoopback()) && *addr != "localhost" {
+		return fmt.Errorf(T("refusing to serve on %s without a token; set TODO_SERVE_TOKEN or run todo token create"), *addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", s.handle(s.listTasks))
+	mux.HandleFunc("POST /tasks", s.handle(s.createTask))
+	mux.HandleFunc("GET /tasks/{ref}", s.handle(s.getTask))
+	mux.HandleFunc("PATCH /tasks/{ref}", s.handle(s.updateTask))
+	mux.HandleFunc("DELETE /tasks/{ref}", s.handle(s.deleteTask))
+	mux.HandleFunc("GET /feed.ics", s.handle(s.feed))
+	mux.HandleFunc("GET /sync", s.handle(s.syncPull))
+	mux.HandleFunc("POST /sync", s.handle(s.syncPush))
+	server := &http.Server{
+		Addr:              net.JoinHostPort(*addr, strconv.Itoa(*port)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	fmt.Printf(T("Serving %s on http://%s; Ctrl-C to stop\n"), filename, server.Addr)
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdown)
+	}
+}
+
+// Wraps an endpoint with the origin and token checks, serialization and
+// JSON responses; the endpoint returns the status and value to send.
+// Tasks answered to a scoped token are cut down to the fields it may read
+func (s *taskServer) handle(fn func(r *http.Request) (int, any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var status int
+		var body any
+		var access *accessToken
+		err := checkOrigin(r)
+		if err == nil {
+			access, err = s.authorize(r)
+		}
+		if err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), accessKey{}, access))
+			s.mu.Lock()
+			status, body, err = fn(r)
+			s.mu.Unlock()
+		}
+		if err != nil {
+			var apiErr apiError
+			status = http.StatusInternalServerError
+			if errors.As(err, &apiErr) {
+				status = apiErr.status
+			}
+			body = map[string]string{"error": err.Error()}
+		}
+		slog.Info("api request", "method", r.Method, "path", r.URL.Path, "status", status, "took", time.Since(start))
+		if access != nil && len(access.Fields) > 0 {
+			switch tasks := body.(type) {
+			case Task:
+				body = access.project([]Task{tasks})[0]
+			case []Task:
+				body = access.project(tasks)
+			}
+		}
+		switch body := body.(type) {
+		case nil:
+			w.WriteHeader(status)
+		case calendarFeed:
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write(body)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(body)
+		}
+	}
+}
+
+type accessKey struct{}
+
+// Turns away requests a web page on another site sends, so a browser
+// can't be made to change tasks on a server that takes no token
+func checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return apiError{http.StatusForbidden, fmt.Errorf(T("requests from %s are not allowed"), origin)}
+}
+
+// Checks that a request body is JSON. A browser only sends other types
+// across sites without asking first
+func requireJSON(r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return apiError{http.StatusUnsupportedMediaType, errors.New(T("request body must be application/json"))}
+	}
+	return nil
+}
+
+// The scoped token a request came with; nil for full access
+func requestAccess(r *http.Request) *accessToken {
+	access, _ := r.Context().Value(accessKey{}).(*accessToken)
+	return access
+}
+
+// Checks the request's token. TODO_SERVE_TOKEN gives full access; a
+// stored token must cover this list, and allow writing unless the request
+// only reads. Tokens are reread each time, so a revoke applies at once
+func (s *taskServer) authorize(r *http.Request) (*accessToken, error) {
+	unauthorized := apiError{http.StatusUnauthorized, errors.New(T("missing, wrong or expired token"))}
+	if s.open.Load() {
+		if !tokensSetUp() {
+			return nil, nil
+		}
+		s.open.Store(false)
+	}
+	tokens, err := loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && r.URL.Path == "/feed.ics" {
+		presented = r.URL.Query().Get("token")
+	}
+	if presented == "" {
+		return nil, unauthorized
+	}
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(s.token)) == 1 {
+		return nil, nil
+	}
+	token, ok := findToken(tokens, presented, time.Now())
+	if !ok {
+		return nil, unauthorized
+	}
+	if !token.covers(listName(s.filename)) {
+		return nil, apiError{http.StatusForbidden, fmt.Errorf(T("token %s does not cover list %q"), token.ID, listName(s.filename))}
+	}
+	if r.Method != http.MethodGet && !token.Write {
+		return nil, apiError{http.StatusForbidden, fmt.Errorf(T("token %s is read-only"), token.ID)}
+	}
+	return &token, nil
+}
+
+func (s *taskServer) listTasks(r *http.Request) (int, any, error) {
+	var filter taskFilter
+	if err := parseFilterExpr(r.URL.Query().Get("filter"), &filter); err != nil {
+		return 0, nil, badRequest(err)
+	}
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	tasks := tl.Filter(filter.keep)
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	return http.StatusOK, tasks, nil
+}
+
+// Finds the task named in the path
+func (s *taskServer) find(tl *TodoList, r *http.Request) (Task, error) {
+	id, err := tl.Resolve(r.PathValue("ref"))
+	if err != nil {
+		return Task{}, apiError{http.StatusNotFound, err}
+	}
+	task, _ := tl.Find(id)
+	return task, nil
+}
+
+func (s *taskServer) getTask(r *http.Request) (int, any, error) {
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	task, err := s.find(tl, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, task, nil
+}
+
+func (s *taskServer) createTask(r *http.Request) (int, any, error) {
+	req, err := decodeTaskRequest(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var task Task
+	if err := req.apply(&task, time.Now()); err != nil {
+		return 0, nil, err
+	}
+	if task.Title == "" {
+		return 0, nil, badRequest(errors.New(T("title is required")))
+	}
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	var added Task
+	err = tl.Transaction(s.filename, func() error {
+		var err error
+		added, err = tl.add(withRules(task))
+		return err
+	})
+	if err != nil {
+		return 0, nil, apiError{http.StatusUnprocessableEntity, err}
+	}
+	return http.StatusCreated, added, nil
+}
+
+func (s *taskServer) updateTask(r *http.Request) (int, any, error) {
+	req, err := decodeTaskRequest(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	task, err := s.find(tl, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Completing goes through complete, for its hooks, subtask check and
+	// next occurrence
+	complete := req.Completed != nil && *req.Completed && !task.Completed
+	if complete {
+		req.Completed = nil
+	}
+	if err := req.apply(&task, time.Now()); err != nil {
+		return 0, nil, err
+	}
+	if task.Title == "" {
+		return 0, nil, badRequest(errors.New(T("title is required")))
+	}
+	err = tl.Transaction(s.filename, func() error {
+		if err := tl.Update(task); err != nil {
+			return err
+		}
+		if complete {
+			_, _, err := tl.complete(task.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, apiError{http.StatusUnprocessableEntity, err}
+	}
+	task, _ = tl.Find(task.ID)
+	return http.StatusOK, task, nil
+}
+
+func (s *taskServer) deleteTask(r *http.Request) (int, any, error) {
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	task, err := s.find(tl, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = tl.Transaction(s.filename, func() error {
+		if _, err := runTaskHook("on-delete", task); err != nil {
+			return err
+		}
+		if _, err := tl.List.DeleteTask(task.ID); err != nil {
+			return err
+		}
+		tl.adoptChildren(task)
+		return nil
+	})
+	if err != nil {
+		return 0, nil, apiError{http.StatusUnprocessableEntity, err}
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// Sync sees every field of every task, so tokens cut down to some fields
+// can't be used for it
+func syncAccess(r *http.Request) error {
+	if access := requestAccess(r); access != nil && len(access.Fields) > 0 {
+		return apiError{http.StatusForbidden, fmt.Errorf(T("token %s is limited to some fields and can't sync"), access.ID)}
+	}
+	return nil
+}
+
+func (s *taskServer) syncPull(r *http.Request) (int, any, error) {
+	if err := syncAccess(r); err != nil {
+		return 0, nil, err
+	}
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, slices.Clone(tl.Tasks), nil
+}
+
+func (s *taskServer) syncPush(r *http.Request) (int, any, error) {
+	if err := syncAccess(r); err != nil {
+		return 0, nil, err
+	}
+	if err := requireJSON(r); err != nil {
+		return 0, nil, err
+	}
+	var changes []syncChange
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 32<<20))
+	if err := dec.Decode(&changes); err != nil {
+		return 0, nil, badRequest(fmt.Errorf(T("invalid request body: %v"), err))
+	}
+	for _, change := range changes {
+		if !slices.Contains([]string{"add", "update", "delete"}, change.Op) || change.Task.UUID == "" {
+			return 0, nil, badRequest(errors.New(T("each change needs op add, update or delete and a task with a uuid")))
+		}
+	}
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := tl.Transaction(s.filename, func() error { return tl.applySync(changes) }); err != nil {
+		return 0, nil, apiError{http.StatusUnprocessableEntity, err}
+	}
+	return http.StatusOK, map[string]int{"applied": len(changes)}, nil
+}
+
+func decodeTaskRequest(r *http.Request) (taskRequest, error) {
+	var req taskRequest
+	if err := requireJSON(r); err != nil {
+		return req, err
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return req, badRequest(fmt.Errorf(T("invalid request body: %v"), err))
+	}
+	return req, nil
+}
+
+// Checks the given fields like the matching add flags and sets them
+func (req taskRequest) apply(task *Task, now time.Time) error {
+	var err error
+	if req.Title != nil {
+		task.Title = normalizeTitle(*req.Title)
+	}
+	if req.Notes != nil {
+		task.Notes = strings.TrimSpace(*req.Notes)
+	}
+	if req.Completed != nil && *req.Completed != task.Completed {
+		task.Completed = *req.Completed
+		task.CompletedAt = ""
+		if task.Completed {
+			task.CompletedAt = now.Format(time.RFC3339)
+		}
+	}
+	if req.Priority != nil {
+		if task.Priority, err = parsePriority(*req.Priority); err != nil {
+			return badRequest(err)
+		}
+	}
+	if req.Due != nil {
+		task.Due = ""
+		if *req.Due != "" {
+			if task.Due, err = parseDate(*req.Due, now); err != nil {
+				return badRequest(err)
+			}
+		}
+	}
+	if req.Context != nil {
+		task.Context = normalizeContext(*req.Context)
+	}
+	if req.Effort != nil {
+		if task.Effort, err = parseEffort(*req.Effort); err != nil {
+			return badRequest(err)
+		}
+	}
+	if req.Repeat != nil {
+		if task.Repeat, err = parseRepeat(*req.Repeat); err != nil {
+			return badRequest(err)
+		}
+	}
+	if req.Tags != nil {
+		if task.Tags, err = parseTags(*req.Tags); err != nil {
+			return badRequest(err)
+		}
+	}
+	for name, value := range req.Fields {
+		if !fieldNameRe.MatchString(name) {
+			return badRequest(fmt.Errorf(T("invalid field name %q"), name))
+		}
+		if value == nil || *value == "" {
+			delete(task.Fields, name)
+			continue
+		}
+		if task.Fields == nil {
+			task.Fields = map[string]string{}
+		}
+		task.Fields[name] = *value
+	}
+	if len(task.Fields) == 0 {
+		task.Fields = nil
+	}
+	return nil
+}
+
+// An iCalendar document, sent as is rather than as JSON
+type calendarFeed []byte
+
+// Open tasks with a due date as all-day events. A token limited to fields
+// without the title shows them as "Task"
+func (s *taskServer) feed(r *http.Request) (int, any, error) {
+	tl, err := openTodoList(s.filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	access := requestAccess(r)
+	if access != nil && len(access.Fields) > 0 && !slices.Contains(access.Fields, "due") {
+		return 0, nil, apiError{http.StatusForbidden, fmt.Errorf(T("token %s may not read due dates"), access.ID)}
+	}
+	showTitle := access == nil || len(access.Fields) == 0 || slices.Contains(access.Fields, "title")
+	var b strings.Builder
+	now := time.Now()
+	writeICSHeader(&b, "todo serve", listName(s.filename))
+	for _, task := range tl.Filter(func(t Task) bool { return !t.Completed && t.Due != "" }) {
+		due, err := time.Parse(dateLayout, task.Due)
+		if err != nil {
+			continue
+		}
+		summary := T("Task")
+		if showTitle {
+			summary = task.Title
+		}
+		writeICSEvent(&b, task, summary, due, now)
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return http.StatusOK, calendarFeed(b.String()), nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// One change from the set command: name=value sets a field ("" clears
+// it), tag+=x and tag-=x add and remove tags. Built-in fields are checked
+// like the matching add flags, and those set can't change are refused;
+// any other name is a custom field
+type mutation struct {
+	field, op, value string
+}
+
+func parseMutations(args []string, now time.Time) ([]mutation, error) {
+	var mutations []mutation
+	for _, arg := range args {
+		var m mutation
+		var ok bool
+		if m.field, m.value, ok = strings.Cut(arg, "+="); ok {
+			m.op = "+="
+		} else if m.field, m.value, ok = strings.Cut(arg, "-="); ok {
+			m.op = "-="
+		} else if m.field, m.value, ok = strings.Cut(arg, "="); ok {
+			m.op = "="
+		}
+		if !ok || !fieldNameRe.MatchString(m.field) {
+			return nil, fmt.Errorf(T("invalid change %q (want name=value, tag+=x or tag-=x)"), arg)
+		}
+		if (m.op != "=") != (m.field == "tag") {
+			return nil, fmt.Errorf(T("invalid change %q (tags take tag+=x or tag-=x)"), arg)
+		}
+		m.value = strings.TrimSpace(m.value)
+		var err error
+		switch m.field {
+		case "title":
+			if m.value == "" {
+				return nil, errors.New(T("title is required"))
+			}
+			m.value = normalizeTitle(m.value)
+		case "notes":
+		case "tag":
+			m.value, err = parseTag(m.value)
+		case "priority":
+			m.value, err = parsePriority(m.value)
+		case "effort":
+			m.value, err = parseEffort(m.value)
+		case "repeat":
+			m.value, err = parseRepeat(m.value)
+		case "context":
+			m.value = normalizeContext(m.value)
+		case "due":
+			if m.value != "" {
+				m.value, err = parseDate(m.value, now)
+			}
+		default:
+			// status is what list and filters call completed
+			if _, builtin := taskFieldIndex(m.field); builtin || m.field == "status" {
+				err = fmt.Errorf(T("set can't change %s; use the command for it, such as edit or done"), m.field)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, m)
+	}
+	return mutations, nil
+}
+
+func (m mutation) apply(task *Task) {
+	switch m.field {
+	case "tag":
+		if m.op == "+=" {
+			task.Tags = union(task.Tags, []string{m.value})
+		} else {
+			task.Tags = slices.DeleteFunc(task.Tags, func(tag string) bool { return tag == m.value })
+		}
+		if len(task.Tags) == 0 {
+			task.Tags = nil
+		}
+	case "title":
+		task.Title = m.value
+	case "notes":
+		task.Notes = m.value
+	case "priority":
+		task.Priority = m.value
+	case "due":
+		task.Due = m.value
+	case "context":
+		task.Context = m.value
+	case "effort":
+		task.Effort = m.value
+	case "repeat":
+		task.Repeat = m.value
+	default:
+		if m.value == "" {
+			delete(task.Fields, m.field)
+		} else {
+			if task.Fields == nil {
+				task.Fields = map[string]string{}
+			}
+			task.Fields[m.field] = m.value
+		}
+		if len(task.Fields) == 0 {
+			task.Fields = nil
+		}
+	}
+}
+
+func formatMutations(mutations []mutation) string {
+	parts := make([]string, len(mutations))
+	for i, m := range mutations {
+		parts[i] = m.field + m.op + m.value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Reads a --filter expression such as "tag:oldproj status:open
+// client=ACME" into the filter; terms are tag:, context:, status:, due<
+// with a day or span (due<1w) and name=value, all of which must match
+func parseFilterExpr(expr string, f *taskFilter) error {
+	for _, word := range strings.Fields(expr) {
+		var err error
+		if value, ok := strings.CutPrefix(word, "due<"); ok {
+			f.dueBefore, err = parseDate(value, time.Now())
+		} else if kind, value, ok := strings.Cut(word, ":"); ok {
+			switch kind {
+			case "tag":
+				var tag string
+				tag, err = parseTag(value)
+				f.tags = append(f.tags, tag)
+			case "context":
+				f.context = value
+			case "status":
+				if value != "open" && value != "done" {
+					err = fmt.Errorf(T("invalid status %q (want open or done)"), value)
+				}
+				f.status = value
+			default:
+				err = fmt.Errorf(T("invalid filter term %q (use tag:, context:, status:, due< or name=value)"), word)
+			}
+		} else {
+			var clause whereClause
+			clause, err = parseWhere(word)
+			f.where = append(f.where, clause)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handles `todo set <task> changes...` and, with a filter, the same changes
+// to every matching task after showing which ones they are
+func setCommand(filename string, args []string) error {
+	setCmd := flag.NewFlagSet("set", flag.ExitOnError)
+	var filter taskFilter
+	filter.register(setCmd)
+	expr := setCmd.String("filter", "", `tasks to change, e.g. "tag:oldproj status:open"`)
+	dryRun := setCmd.Bool("dry-run", false, "only show the tasks that would change")
+	yes := setCmd.Bool("yes", false, "change them without asking")
+	rest := parseInterspersed(setCmd, args)
+	if err := parseFilterExpr(*expr, &filter); err != nil {
+		return err
+	}
+	bulk := false
+	setCmd.Visit(func(f *flag.Flag) {
+		bulk = bulk || (f.Name != "dry-run" && f.Name != "yes")
+	})
+
+	tl := loadTodoList(filename)
+	var tasks []Task
+	if !bulk {
+		if len(rest) < 2 {
+			return errors.New(T("usage: todo set <task-id|ref> name=value... or todo set --filter <terms> name=value..."))
+		}
+		id, err := tl.Resolve(rest[0])
+		if err != nil {
+			return err
+		}
+		task, _ := tl.Find(id)
+		tasks, rest = []Task{task}, rest[1:]
+	} else {
+		tasks = tl.Filter(filter.keep)
+	}
+	if len(rest) == 0 {
+		return errors.New(T("nothing to change; give name=value, tag+=x or tag-=x"))
+	}
+	mutations, err := parseMutations(rest, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return errors.New(T("no tasks match the filter"))
+	}
+
+	if bulk {
+		fmt.Printf(T("%s on %d tasks:\n"), formatMutations(mutations), len(tasks))
+		tl.PrintTasks(tasks)
+		if *dryRun {
+			return nil
+		}
+		if !*yes {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				return errors.New(T("not changing tasks without confirmation; pass --yes"))
+			}
+			if !confirm(T("Apply?")) {
+				return nil
+			}
+		}
+	}
+	err = tl.Transaction(filename, func() error {
+		for _, task := range tasks {
+			for _, m := range mutations {
+				m.apply(&task)
+			}
+			if err := tl.Update(task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if bulk {
+		fmt.Printf(T("Updated %d tasks\n"), len(tasks))
+	} else {
+		fmt.Printf(T("Updated task %d: %s\n"), tasks[0].ID, formatMutations(mutations))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetMutations(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+	tests := []struct {
+		change  string
+		check   func(Task) bool
+		wantErr bool
+	}{
+		{change: "title=Call Ann", check: func(t Task) bool { return t.Title == "Call Ann" && t.Fields == nil }},
+		{change: "notes=bring the forms", check: func(t Task) bool { return t.Notes == "bring the forms" && t.Fields == nil }},
+		{change: "due=tomorrow", check: func(t Task) bool { return t.Due == "2026-10-15" }},
+		{change: "client=ACME", check: func(t Task) bool { return t.Fields["client"] == "ACME" }},
+		{change: "title=", wantErr: true},
+		{change: "status=done", wantErr: true},
+		{change: "parent=abc", wantErr: true},
+		{change: "completed=true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.change, func(t *testing.T) {
+			mutations, err := parseMutations([]string{tt.change}, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMutations(%q) error = %v, want error %v", tt.change, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			task := Task{Title: "Old"}
+			mutations[0].apply(&task)
+			if !tt.check(task) {
+				t.Errorf("%q gave %+v", tt.change, task)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// Prints every detail of one task of the store in filename as labeled
+// lines
+func (tl *TodoList) ShowTask(id int, filename string) error {
+	for _, task := range tl.Tasks {
+		if task.ID != id {
+			continue
+		}
+		status := T("open")
+		if task.Completed {
+			status = T("done")
+		} else if task.Waiting {
+			status = T("waiting")
+		} else if task.Someday {
+			status = T("someday")
+		}
+		rows := [][2]string{
+			{T("ID"), fmt.Sprint(task.ID)},
+			{T("Ref"), tl.ShortRefs()[task.ID]},
+			{T("UUID"), task.UUID},
+			{T("Title"), task.Title},
+			{T("Status"), status},
+		}
+		if task.CreatedAt != "" {
+			rows = append(rows, [2]string{T("Created"), formatStamp(task.CreatedAt)})
+		}
+		if task.CompletedAt != "" {
+			rows = append(rows, [2]string{T("Completed"), formatStamp(task.CompletedAt)})
+		}
+		if task.Priority != "" {
+			rows = append(rows, [2]string{T("Priority"), task.Priority})
+		}
+		if len(task.Tags) > 0 {
+			rows = append(rows, [2]string{T("Tags"), formatTags(task.Tags)})
+		}
+		if task.Due != "" {
+			rows = append(rows, [2]string{T("Due"), task.Due})
+		}
+		if task.Repeat != "" {
+			rows = append(rows, [2]string{T("Repeats"), task.Repeat})
+		}
+		if task.Context != "" {
+			rows = append(rows, [2]string{T("Context"), task.Context})
+		}
+		if task.Effort != "" {
+			rows = append(rows, [2]string{T("Effort"), task.Effort})
+		}
+		if task.WaitingOn != "" {
+			rows = append(rows, [2]string{T("Waiting on"), task.WaitingOn})
+		}
+		if task.FollowUp != "" {
+			rows = append(rows, [2]string{T("Follow up"), task.FollowUp})
+		}
+		if parent, ok := tl.parentOf(task); ok {
+			rows = append(rows, [2]string{T("Subtask of"), fmt.Sprintf("%d %s", parent.ID, parent.Title)})
+		}
+		if children := tl.children(task); len(children) > 0 {
+			done := 0
+			for _, child := range children {
+				if child.Completed {
+					done++
+				}
+			}
+			rows = append(rows, [2]string{T("Subtasks"), fmt.Sprintf(T("%d of %d done"), done, len(children))})
+		}
+		if goal, ok := tl.findGoal(task.Goal); ok {
+			rows = append(rows, [2]string{T("Goal"), fmt.Sprintf("%d %s", goal.ID, goal.Title)})
+		}
+		for _, link := range task.Attachments {
+			rows = append(rows, [2]string{T("Attachment"), describeAttachment(blobDir(filename), link)})
+		}
+		for _, linked := range tl.linkedTasks(filename, task) {
+			rows = append(rows, [2]string{T("Links to"), fmt.Sprintf("%d %s", linked.ID, linked.Title)})
+		}
+		rows = append(rows, [2]string{T("Link"), taskURI(filename, task)})
+		for _, key := range sortedKeys(task.Fields) {
+			rows = append(rows, [2]string{key, task.Fields[key]})
+		}
+
+		width := 0
+		for _, row := range rows {
+			width = max(width, displayWidth(row[0]))
+		}
+		for _, row := range rows {
+			fmt.Printf("%s  %s\n", padRight(row[0]+":", width+1), strings.TrimSpace(row[1]))
+		}
+		if task.Notes != "" {
+			fmt.Printf("\n%s\n", T("Notes:"))
+			for _, line := range strings.Split(task.Notes, "\n") {
+				fmt.Println(strings.TrimRight("  "+line, " "))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// A stored RFC 3339 time in local time, with how long ago it was
+func formatStamp(stamp string) string {
+	at, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return stamp
+	}
+	return fmt.Sprintf(T("%s (%s ago)"), at.Local().Format("2006-01-02 15:04"), formatAge(stamp, time.Now()))
+}
+
+// Writes one task as a JSON object, for scripts, or in any other format
+// as a list of one
+func writeTask(w io.Writer, l *todo.List, task Task, format string) error {
+	if format != "json" {
+		r, err := todo.LookupRenderer(format)
+		if err != nil {
+			return err
+		}
+		return r.Render(w, l, []Task{task})
+	}
+	data, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
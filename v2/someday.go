@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How often the someday/maybe list should be looked at again
+const somedayReviewInterval = 7 * 24 * time.Hour
+
+// Moves a task to or from the someday/maybe backlog
+func (tl *TodoList) SetSomeday(id int, someday bool) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Someday = someday
+			if someday {
+				fmt.Printf(T("Moved task %d to someday/maybe: %s\n"), id, tl.Tasks[i].Title)
+			} else {
+				fmt.Printf(T("Promoted task %d to active: %s\n"), id, tl.Tasks[i].Title)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Open tasks parked in the someday/maybe backlog
+func (tl *TodoList) SomedayTasks() []Task {
+	return tl.Filter(func(task Task) bool { return task.Someday && !task.Completed })
+}
+
+// Reminds about the backlog when it hasn't been reviewed for a while
+func (tl *TodoList) PrintSomedayReminder(state sessionState, now time.Time) {
+	count := len(tl.SomedayTasks())
+	if count == 0 || state.SomedayReviewed == "" {
+		return
+	}
+	reviewed, err := time.ParseInLocation(dateLayout, state.SomedayReviewed, time.Local)
+	if err != nil || now.Sub(reviewed) < somedayReviewInterval {
+		return
+	}
+	fmt.Printf(T("%d someday/maybe tasks not reviewed since %s; run `todo review`\n"), count, state.SomedayReviewed)
+}
+
+// Handles `todo someday [<task-id|ref>] [--clear]`. Without a task it
+// lists the backlog
+func somedayCommand(filename string, args []string) error {
+	var ref string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		ref, args = args[0], args[1:]
+	}
+	somedayCmd := flag.NewFlagSet("someday", flag.ExitOnError)
+	clear := somedayCmd.Bool("clear", false, "promote the task back to the active list")
+	somedayCmd.Parse(args)
+	if ref == "" && somedayCmd.NArg() > 0 {
+		ref = somedayCmd.Arg(0)
+	}
+
+	tl := loadTodoList(filename)
+	if ref == "" {
+		tl.PrintTasks(tl.SomedayTasks())
+		return nil
+	}
+	id, err := tl.Resolve(ref)
+	if err != nil {
+		return err
+	}
+	if err := tl.Transaction(filename, func() error {
+		return tl.SetSomeday(id, !*clear)
+	}); err != nil {
+		return err
+	}
+
+	// Start the review clock with the first parked task, so the reminder
+	// doesn't fire straight away
+	state, err := loadState(filename)
+	if err != nil || state.SomedayReviewed != "" {
+		return err
+	}
+	state.SomedayReviewed = time.Now().Format(dateLayout)
+	return saveState(filename, state)
+}
+
+// Walks through the someday/maybe backlog asking whether to promote,
+// drop or keep each task
+func reviewSomeday(tl *TodoList, filename string) error {
+	tasks := tl.SomedayTasks()
+	if len(tasks) == 0 {
+		fmt.Println(T("Nothing in someday/maybe."))
+		return nil
+	}
+	err := tl.Transaction(filename, func() error {
+	review:
+		for _, task := range tasks {
+			answer := ask(fmt.Sprintf(T("%d %s: [p]romote, [d]rop, [k]eep or [q]uit?"), task.ID, task.Title))
+			var err error
+			switch answer {
+			case "p":
+				err = tl.SetSomeday(task.ID, false)
+			case "d":
+				err = tl.DeleteTask(task.ID)
+			case "q":
+				break review
+			}
+			// A vetoing hook only keeps this task; the rest of the review
+			// still counts
+			if err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+	state.SomedayReviewed = time.Now().Format(dateLayout)
+	return saveState(filename, state)
+}
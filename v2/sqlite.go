@@ -0,0 +1,279 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id        INTEGER PRIMARY KEY,
+	uuid      TEXT NOT NULL,
+	title     TEXT NOT NULL,
+	completed INTEGER NOT NULL DEFAULT 0,
+	due       TEXT NOT NULL DEFAULT '',
+	data      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tasks_uuid ON tasks (uuid);
+CREATE TABLE IF NOT EXISTS goals (
+	id   INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+// sqliteStore keeps tasks in a SQLite database, one row per task. The full
+// task is stored as JSON so new fields need no migration; id, uuid, title,
+// completed and due are copied into columns for querying. A save writes
+// only the tasks that changed since the load, in one SQL transaction, so
+// it stays fast for big lists and a crash can't leave a half-written store
+type sqliteStore struct {
+	db *sql.DB
+	// Task JSON as last loaded or saved, by ID, to spot what changed
+	saved map[int]string
+	goals string
+	// List settings as last loaded or saved
+	meta map[string]string
+}
+
+var _ todo.Store = (*sqliteStore)(nil)
+
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Several invocations (or the bot) may share the file
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", sqliteSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf(T("opening %s: %v"), path, err)
+		}
+	}
+	return &sqliteStore{db: db, saved: map[int]string{}}, nil
+}
+
+func (s *sqliteStore) Load() (*todo.List, error) {
+	list := &todo.List{Tasks: []Task{}}
+	saved := map[int]string{}
+	rows, err := s.db.Query("SELECT id, data FROM tasks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, fmt.Errorf(T("task %d in the database is corrupt: %v"), id, err)
+		}
+		list.Tasks = append(list.Tasks, task)
+		saved[id] = data
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	goalRows, err := s.db.Query("SELECT data FROM goals ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer goalRows.Close()
+	for goalRows.Next() {
+		var data string
+		var goal Goal
+		if err := goalRows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &goal); err != nil {
+			return nil, err
+		}
+		list.Goals = append(list.Goals, goal)
+	}
+	if err := goalRows.Err(); err != nil {
+		return nil, err
+	}
+
+	meta := map[string]string{}
+	metaRows, err := s.db.Query("SELECT key, value FROM meta")
+	if err != nil {
+		return nil, err
+	}
+	defer metaRows.Close()
+	for metaRows.Next() {
+		var key, value string
+		if err := metaRows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		meta[key] = value
+	}
+	if err := metaRows.Err(); err != nil {
+		return nil, err
+	}
+	list.IDStrategy = meta["id_strategy"]
+	list.LastID, _ = strconv.Atoi(meta["last_id"])
+
+	goals, err := json.Marshal(list.Goals)
+	if err != nil {
+		return nil, err
+	}
+	s.saved, s.goals, s.meta = saved, string(goals), listMeta(list)
+	return list, nil
+}
+
+func (s *sqliteStore) Save(l *todo.List) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	saved := make(map[int]string, len(l.Tasks))
+	for _, task := range l.Tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		saved[task.ID] = string(data)
+		if s.saved[task.ID] == string(data) {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO tasks (id, uuid, title, completed, due, data) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET uuid = excluded.uuid, title = excluded.title,
+				completed = excluded.completed, due = excluded.due, data = excluded.data`,
+			task.ID, task.UUID, task.Title, task.Completed, task.Due, string(data))
+		if err != nil {
+			return err
+		}
+	}
+	// Only delete what this process saw and removed; rows another process
+	// added since the load are left alone
+	for id := range s.saved {
+		if _, ok := saved[id]; !ok {
+			if _, err := tx.Exec("DELETE FROM tasks WHERE id = ?", id); err != nil {
+				return err
+			}
+		}
+	}
+
+	goals, err := json.Marshal(l.Goals)
+	if err != nil {
+		return err
+	}
+	if string(goals) != s.goals {
+		if _, err := tx.Exec("DELETE FROM goals"); err != nil {
+			return err
+		}
+		for _, goal := range l.Goals {
+			data, err := json.Marshal(goal)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO goals (id, data) VALUES (?, ?)", goal.ID, string(data)); err != nil {
+				return err
+			}
+		}
+	}
+
+	meta := listMeta(l)
+	for key, value := range meta {
+		if s.meta[key] == value {
+			continue
+		}
+		_, err := tx.Exec("INSERT INTO meta (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value", key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.saved, s.goals, s.meta = saved, string(goals), meta
+	return nil
+}
+
+// The list settings kept in the meta table
+func listMeta(l *todo.List) map[string]string {
+	return map[string]string{
+		"id_strategy": l.IDStrategy,
+		"last_id":     strconv.Itoa(l.LastID),
+	}
+}
+
+// The database lives next to where todo.json would be
+func sqlitePath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".db"
+}
+
+// An encrypted store stays on the json backend, as the database can't be
+// encrypted
+type encryptedMigrationError struct{}
+
+func (encryptedMigrationError) Error() string {
+	return T("the store is encrypted and the sqlite backend can't keep it so; run todo decrypt first or stay on the json backend")
+}
+
+// Creates the database from an existing JSON store the first time the
+// SQLite backend is used. The JSON file is renamed rather than deleted,
+// so nothing is lost and it can't be picked up again by mistake. The
+// database is built under a temporary name and only put in place once
+// every task is in it, so a failed migration leaves the JSON store in use
+func migrateToSQLite(filename string) error {
+	if _, err := os.Stat(filename); err != nil {
+		return nil
+	}
+	if fileEncrypted(filename) {
+		return encryptedMigrationError{}
+	}
+	list, err := todo.FileStore{Path: filename}.Load()
+	if err != nil {
+		return err
+	}
+	path, tmp := sqlitePath(filename), sqlitePath(filename)+".migrating"
+	removeSQLiteFiles(tmp)
+	err = func() error {
+		s, err := openSQLiteStore(tmp)
+		if err != nil {
+			return err
+		}
+		defer s.db.Close()
+		return s.Save(list)
+	}()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		removeSQLiteFiles(tmp)
+		return err
+	}
+	if err := os.Rename(filename, filename+".migrated"); err != nil {
+		removeSQLiteFiles(path)
+		return err
+	}
+	slog.Info("migrated store to SQLite", "from", filename, "to", path, "tasks", len(list.Tasks))
+	fmt.Fprintf(os.Stderr, T("Moved %d tasks from %s into %s (the old file is kept as %s)\n"),
+		len(list.Tasks), filename, path, filename+".migrated")
+	return nil
+}
+
+// Removes a database and the files SQLite keeps next to it
+func removeSQLiteFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Start day of a --since span. Spans such as 3d or 2w count back from
+// today, other phrases are read like due dates
+func parseSince(s string, now time.Time) (string, error) {
+	since, err := parseDate(s, now)
+	if err != nil {
+		return "", err
+	}
+	day, _ := time.ParseInLocation(dateLayout, since, time.Local)
+	today, _ := time.ParseInLocation(dateLayout, now.Format(dateLayout), time.Local)
+	if day.After(today) {
+		since = today.Add(-day.Sub(today)).Format(dateLayout)
+	}
+	return since, nil
+}
+
+// Tasks completed on or after the since day, oldest first
+func (tl *TodoList) completedSince(since string) []Task {
+	tasks := tl.Filter(func(task Task) bool {
+		return task.Completed && len(task.CompletedAt) >= len(dateLayout) && task.CompletedAt[:len(dateLayout)] >= since
+	})
+	slices.SortStableFunc(tasks, func(a, b Task) int {
+		return strings.Compare(a.CompletedAt, b.CompletedAt)
+	})
+	return tasks
+}
+
+// The project a task belongs to in reports: its goal, else the top of its
+// subtask tree; "" for standalone tasks
+func (tl *TodoList) project(task Task) string {
+	if goal, ok := tl.findGoal(task.Goal); ok {
+		return goal.Title
+	}
+	root, seen := task, map[string]bool{}
+	for !seen[root.UUID] {
+		seen[root.UUID] = true
+		parent, ok := tl.parentOf(root)
+		if !ok {
+			break
+		}
+		root = parent
+	}
+	if root.UUID == task.UUID {
+		return ""
+	}
+	return root.Title
+}
+
+// Writes a standup update: what was done, grouped by project, and what is
+// planned today (open tasks that are due or overdue), as bullets that
+// paste cleanly into chat threads
+func (tl *TodoList) writeStandup(w io.Writer, done []Task, now time.Time) {
+	fmt.Fprintf(w, "*%s*\n", T("Done"))
+	if len(done) == 0 {
+		fmt.Fprintf(w, "• %s\n", T("Nothing completed"))
+	}
+	var projects []string
+	byProject := map[string][]Task{}
+	for _, task := range done {
+		name := tl.project(task)
+		if _, ok := byProject[name]; !ok {
+			projects = append(projects, name)
+		}
+		byProject[name] = append(byProject[name], task)
+	}
+	// Standalone tasks come last, under their own heading when there are
+	// projects to set them apart from
+	if i := slices.Index(projects, ""); i >= 0 {
+		projects = append(slices.Delete(projects, i, i+1), "")
+	}
+	for _, name := range projects {
+		indent := ""
+		if len(projects) > 1 || name != "" {
+			fmt.Fprintf(w, "• %s\n", cmp.Or(name, T("Other")))
+			indent = "  "
+		}
+		for _, task := range byProject[name] {
+			fmt.Fprintf(w, "%s• %s\n", indent, task.Title)
+		}
+	}
+
+	fmt.Fprintf(w, "\n*%s*\n", T("Today"))
+	planned := tl.Filter(func(task Task) bool {
+		return !task.Completed && !task.Someday && !task.Waiting && dateReached(task.Due, now)
+	})
+	sortTasks(planned, "priority", false)
+	if len(planned) == 0 {
+		fmt.Fprintf(w, "• %s\n", T("Nothing scheduled"))
+	}
+	for _, task := range planned {
+		note := T("due today")
+		if overdue(task, now) {
+			note = fmt.Sprintf(T("overdue since %s"), task.Due)
+		}
+		fmt.Fprintf(w, "• %s (%s)\n", task.Title, note)
+	}
+}
+
+// Handles `todo done [--since yesterday] [--format list|standup]`
+func doneCommand(filename string, args []string) error {
+	doneCmd := flag.NewFlagSet("done", flag.ExitOnError)
+	sinceFlag := doneCmd.String("since", "today", "first day to report: yesterday, monday, 3d, YYYY-MM-DD")
+	format := doneCmd.String("format", "list", "list, or standup for a bullet list to paste into chat")
+	doneCmd.Parse(args)
+	now := time.Now()
+	since, err := parseSince(*sinceFlag, now)
+	if err != nil {
+		return err
+	}
+	tl := loadTodoList(filename)
+	done := tl.completedSince(since)
+
+	switch *format {
+	case "standup":
+		tl.writeStandup(os.Stdout, done, now)
+	case "list":
+		if outputFormat != "table" {
+			return renderTasks(tl, done)
+		}
+		fmt.Printf(T("Completed since %s:\n"), since)
+		tl.PrintTasks(done)
+	default:
+		return fmt.Errorf(T("unknown report format %q (use list or standup)"), *format)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// The file the backend keeps the tasks in
+func backendPath(filename string) string {
+	switch backend {
+	case "sqlite":
+		return sqlitePath(filename)
+	case "todotxt":
+		return todoTxtPath(filename)
+	}
+	return filename
+}
+
+// Where the offline copy of a store is cached: one file per store in the
+// user's cache directory, named after the store's absolute path
+func storeCachePath(filename string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(backendPath(filename))
+	if err != nil {
+		abs = filename
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "todo", hex.EncodeToString(sum[:8])+".json")
+}
+
+// Brings the offline copy up to date after a load or save, when the config
+// file asks for one. It is only rewritten when the store is newer, and
+// sealed when the store is encrypted
+func refreshStoreCache(filename string, l *todo.List) {
+	path := storeCachePath(filename)
+	if !cfg.OfflineCache || path == "" {
+		return
+	}
+	if cached, err := os.Stat(path); err == nil {
+		if store, err := os.Stat(backendPath(filename)); err == nil && !store.ModTime().After(cached.ModTime()) {
+			return
+		}
+	}
+	err := func() error {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if data, err = sealSidecar(data, storeEncrypted(filename)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0600)
+	}()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, T("Warning: couldn't update the offline copy: %v\n"), err)
+	}
+}
+
+// Loads the offline copy in place of a store that can't be read. The list
+// is read-only: saving it would overwrite changes made to the store since
+func cachedTodoList(filename string, loadErr error) (*TodoList, bool) {
+	if !cfg.OfflineCache || errors.Is(loadErr, todo.ErrPassphrase) {
+		return nil, false
+	}
+	path := storeCachePath(filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	data, err := readSidecar(path)
+	if err != nil {
+		return nil, false
+	}
+	tl := &TodoList{readOnly: true}
+	if err := json.Unmarshal(data, &tl.List); err != nil {
+		return nil, false
+	}
+	fmt.Fprintf(os.Stderr, T("Warning: can't read the store (%v); showing the offline copy from %s ago, read-only\n"), loadErr, formatAge(info.ModTime().Format(time.RFC3339), time.Now()))
+	return tl, true
+}
+
+// Handles `todo store status [<sync-target>...]`: how the configured
+// backend and sync targets answer. Without targets the ones synced with
+// before are checked
+func storeCommand(filename string, args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return errors.New(T("usage: todo store status [<sync-target>...]"))
+	}
+	targets := args[1:]
+
+	fmt.Printf(T("Backend:    %s\n"), backend)
+	fmt.Printf(T("Location:   %s\n"), backendPath(filename))
+	tl := &TodoList{}
+	start := time.Now()
+	loadErr := tl.LoadFromFile(filename)
+	took := time.Since(start)
+	if loadErr != nil {
+		fmt.Printf(T("Status:     unavailable: %v\n"), loadErr)
+	} else {
+		fmt.Printf(T("Status:     available, read %d tasks in %s\n"), len(tl.Tasks), took.Round(time.Microsecond))
+	}
+	if info, err := os.Stat(backendPath(filename)); err == nil {
+		fmt.Printf(T("Size:       %s, changed %s ago\n"), formatBytes(info.Size()), formatAge(info.ModTime().Format(time.RFC3339), time.Now()))
+	}
+	if err := checkWritable(filepath.Dir(backendPath(filename))); err != nil {
+		fmt.Printf(T("Writable:   no: %v\n"), err)
+	} else {
+		fmt.Println(T("Writable:   yes"))
+	}
+	if backend == "json" && loadErr == nil {
+		encrypted := T("no")
+		if fileEncrypted(filename) {
+			encrypted = T("yes")
+		}
+		fmt.Printf(T("Encrypted:  %s\n"), encrypted)
+		if _, err := os.Stat(filename + ".bak"); err == nil {
+			fmt.Printf(T("Backup:     %s\n"), filename+".bak")
+		}
+	}
+	switch info, err := os.Stat(storeCachePath(filename)); {
+	case !cfg.OfflineCache:
+		fmt.Println(T("Offline:    off (set offline_cache: true in the config file)"))
+	case err != nil:
+		fmt.Println(T("Offline:    no copy yet"))
+	default:
+		fmt.Printf(T("Offline:    %s, from %s ago\n"), storeCachePath(filename), formatAge(info.ModTime().Format(time.RFC3339), time.Now()))
+	}
+
+	if len(targets) == 0 {
+		var err error
+		if targets, err = knownSyncTargets(filename); err != nil {
+			return err
+		}
+	}
+	if len(targets) > 0 {
+		fmt.Println(T("Sync targets:"))
+	}
+	failed := loadErr != nil
+	for _, target := range targets {
+		start := time.Now()
+		count, err := pingSyncTarget(tl, filename, target)
+		if err != nil {
+			failed = true
+			fmt.Printf(T("  %s  unavailable: %v\n"), target, err)
+			continue
+		}
+		fmt.Printf(T("  %s  available, %d tasks in %s\n"), target, count, time.Since(start).Round(time.Millisecond))
+	}
+	if failed {
+		return errors.New(T("not everything is available"))
+	}
+	return nil
+}
+
+// Reads a sync target's tasks, without syncing
+func pingSyncTarget(tl *TodoList, filename, target string) (int, error) {
+	provider, err := openSyncProvider(syncTarget(target))
+	if err != nil {
+		return 0, err
+	}
+	if p, ok := provider.(storeProvider); ok {
+		p.bindStore(tl, filename)
+	}
+	tasks, err := provider.Pull()
+	return len(tasks), err
+}
+
+// Tries creating a file in dir
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".todo-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The task a subtask belongs to. Parents are stored by UUID so the link
+// survives sync and import, where IDs get renumbered
+func (tl *TodoList) parentOf(task Task) (Task, bool) {
+	if task.Parent == "" {
+		return Task{}, false
+	}
+	for _, parent := range tl.Tasks {
+		if parent.UUID == task.Parent {
+			return parent, true
+		}
+	}
+	return Task{}, false
+}
+
+// Direct subtasks of a task, in list order
+func (tl *TodoList) children(task Task) []Task {
+	return tl.Filter(func(child Task) bool { return child.Parent == task.UUID })
+}
+
+// Every task below a task, children before their own subtasks
+func (tl *TodoList) descendants(task Task) []Task {
+	var all []Task
+	for _, child := range tl.children(task) {
+		all = append(all, child)
+		all = append(all, tl.descendants(child)...)
+	}
+	return all
+}
+
+// Makes a task a subtask of parentID, or a top-level task with 0
+func (tl *TodoList) SetParent(id, parentID int) error {
+	task, ok := tl.Find(id)
+	if !ok {
+		return fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	task.Parent = ""
+	if parentID != 0 {
+		parent, ok := tl.Find(parentID)
+		if !ok {
+			return fmt.Errorf(T("task with ID %d not found"), parentID)
+		}
+		// Walk up from the new parent; meeting the task means a cycle
+		for p, ok := parent, true; ok; p, ok = tl.parentOf(p) {
+			if p.ID == id {
+				return errors.New(T("a task can't be a subtask of itself or its subtasks"))
+			}
+		}
+		task.Parent = parent.UUID
+	}
+	return tl.Update(task)
+}
+
+// Completes a task after its open subtasks, deepest first
+func (tl *TodoList) CompleteTree(id int) error {
+	task, ok := tl.Find(id)
+	if !ok {
+		return fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	for _, child := range tl.children(task) {
+		if !child.Completed {
+			if err := tl.CompleteTree(child.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return tl.CompleteTask(id)
+}
+
+// Refuses to complete a task while subtasks are open, so a parent isn't
+// ticked off with work still hanging under it
+func (tl *TodoList) checkSubtasksDone(task Task) error {
+	open := 0
+	for _, child := range tl.descendants(task) {
+		if !child.Completed {
+			open++
+		}
+	}
+	if open > 0 {
+		return fmt.Errorf(T("task %d has %d open subtasks; complete them first or use --cascade"), task.ID, open)
+	}
+	return nil
+}
+
+// Deletes a task with all its subtasks
+func (tl *TodoList) DeleteTree(id int) error {
+	task, ok := tl.Find(id)
+	if !ok {
+		return fmt.Errorf(T("task with ID %d not found"), id)
+	}
+	// Deepest first, so no subtask is left behind to move up a level
+	for _, child := range slices.Backward(tl.descendants(task)) {
+		if err := tl.DeleteTask(child.ID); err != nil {
+			return err
+		}
+	}
+	return tl.DeleteTask(id)
+}
+
+// Hands a deleted task's subtasks to its own parent, so deleting a task
+// in the middle of a tree doesn't leave orphans pointing nowhere. Returns
+// how many moved
+func (tl *TodoList) adoptChildren(task Task) int {
+	moved := 0
+	for i := range tl.Tasks {
+		if tl.Tasks[i].Parent == task.UUID {
+			tl.Tasks[i].Parent = task.Parent
+			moved++
+		}
+	}
+	return moved
+}
+
+// Orders tasks as a tree: each task is followed by its subtasks, indented
+// one level deeper. Tasks whose parent isn't among them are roots, so a
+// filtered list still shows everything it matched. Siblings keep their
+// order, so sorting first sorts each level
+func treeOrder(tasks []Task) ([]Task, map[int]int) {
+	shown := map[string]bool{}
+	for _, task := range tasks {
+		shown[task.UUID] = true
+	}
+	ordered := make([]Task, 0, len(tasks))
+	depth := map[int]int{}
+	var walk func(task Task, level int)
+	walk = func(task Task, level int) {
+		if _, done := depth[task.ID]; done {
+			return
+		}
+		ordered = append(ordered, task)
+		depth[task.ID] = level
+		for _, child := range tasks {
+			if child.Parent != "" && child.Parent == task.UUID {
+				walk(child, level+1)
+			}
+		}
+	}
+	for _, task := range tasks {
+		if !shown[task.Parent] {
+			walk(task, 0)
+		}
+	}
+	// Only a parent cycle in a hand-edited store leaves tasks unplaced
+	for _, task := range tasks {
+		walk(task, 0)
+	}
+	return ordered, depth
+}
+
+// Tree branch drawn before an indented title
+func treeIndent(level int) string {
+	if level == 0 {
+		return ""
+	}
+	branch := "└─ "
+	if !utf8Terminal() {
+		branch = "`- "
+	}
+	return strings.Repeat("   ", level-1) + branch
+}
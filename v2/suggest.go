@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// What a context provider thinks of right now, e.g. a weather script
+// answering {"defer": ["outdoor"], "note": "rain until 4pm"}. Tasks with a
+// boosted tag move up the `next` list, tasks with a deferred one move down
+type suggestion struct {
+	provider string
+	Boost    []string `json:"boost"`
+	Defer    []string `json:"defer"`
+	Note     string   `json:"note"`
+	// Effort to favour; only the built-in calendar provider sets it
+	effort string
+}
+
+// Asks the context providers about the candidate tasks. Providers are
+// executables in the hooks directory named "suggest" or "suggest.<name>"
+// that get {"tasks": [...]} on stdin and print a suggestion object. A
+// provider that fails or times out is skipped, so a flaky weather API
+// never stops `next` from working
+func contextSuggestions(tasks []Task) []suggestion {
+	hooks := findHooks("suggest")
+	if len(hooks) == 0 {
+		return nil
+	}
+	input, err := json.Marshal(map[string][]Task{"tasks": tasks})
+	if err != nil {
+		return nil
+	}
+	var suggestions []suggestion
+	for _, hook := range hooks {
+		output, err := runHook(hook, "suggest", input)
+		if err != nil || output == nil {
+			slog.Warn("context provider gave no suggestion", "provider", hook, "err", err)
+			continue
+		}
+		var s suggestion
+		if err := json.Unmarshal(output, &s); err != nil {
+			slog.Warn("context provider printed invalid JSON", "provider", hook, "err", err)
+			continue
+		}
+		s.provider = strings.TrimPrefix(strings.TrimPrefix(filepath.Base(hook), "suggest"), ".")
+		if s.provider == "" {
+			s.provider = "suggest"
+		}
+		s.Boost, s.Defer = normalizeTags(s.Boost), normalizeTags(s.Defer)
+		suggestions = append(suggestions, s)
+	}
+	return suggestions
+}
+
+// Like parseTags, but drops invalid tags instead of failing
+func normalizeTags(tags []string) []string {
+	var valid []string
+	for _, tag := range tags {
+		if tag, err := parseTag(tag); err == nil {
+			valid = append(valid, tag)
+		}
+	}
+	return valid
+}
+
+// Orders tasks by how many boosted tags (and efforts) they carry minus
+// deferred ones; the sort is stable so equal tasks keep list order
+func rankBySuggestions(tasks []Task, suggestions []suggestion) {
+	score := func(task Task) int {
+		n := 0
+		for _, s := range suggestions {
+			if s.effort != "" && task.Effort == s.effort {
+				n++
+			}
+			for _, tag := range task.Tags {
+				if slices.Contains(s.Boost, tag) {
+					n++
+				}
+				if slices.Contains(s.Defer, tag) {
+					n--
+				}
+			}
+		}
+		return n
+	}
+	slices.SortStableFunc(tasks, func(a, b Task) int { return score(b) - score(a) })
+}
+
+// Shows why the list was reordered
+func printSuggestions(suggestions []suggestion) {
+	for _, s := range suggestions {
+		var parts []string
+		if s.Note != "" {
+			parts = append(parts, s.Note)
+		}
+		if len(s.Boost) > 0 {
+			parts = append(parts, fmt.Sprintf(T("favouring %s"), formatTags(s.Boost)))
+		}
+		if len(s.Defer) > 0 {
+			parts = append(parts, fmt.Sprintf(T("deferring %s"), formatTags(s.Defer)))
+		}
+		if s.effort != "" {
+			parts = append(parts, fmt.Sprintf(T("favouring %s tasks"), s.effort))
+		}
+		if len(parts) > 0 {
+			fmt.Printf("%s: %s\n", s.provider, strings.Join(parts, "; "))
+		}
+	}
+}
@@ -0,0 +1,724 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// SyncProvider is the other side of a sync. Tasks are matched by UUID;
+// each provider decides how to reconcile a task that differs on both sides
+type SyncProvider interface {
+	// Name identifies the provider in messages, e.g. "file /mnt/todo.json"
+	Name() string
+	// Pull returns every task the other side has
+	Pull() ([]Task, error)
+	// Push applies the given changes on the other side
+	Push(changes []syncChange) error
+	// Resolve returns the version of a task both sides should end up with
+	Resolve(local, remote Task) Task
+}
+
+// A task to add on one side, or to replace or delete the copy with the
+// same UUID
+type syncChange struct {
+	Op   string `json:"op"` // "add", "update" or "delete"
+	Task Task   `json:"task"`
+}
+
+// Providers by the scheme of the sync target; a target without a scheme
+// is a path to another store. http and https are a todo serve, dav and
+// davs a todo.json on a WebDAV server, s3 an object in a bucket
+var syncProviders = map[string]func(target string) (SyncProvider, error){
+	"file":  newFileProvider,
+	"http":  func(rest string) (SyncProvider, error) { return newServerProvider("http://" + rest) },
+	"https": func(rest string) (SyncProvider, error) { return newServerProvider("https://" + rest) },
+	"dav":   func(rest string) (SyncProvider, error) { return newDAVProvider("http://" + rest) },
+	"davs":  func(rest string) (SyncProvider, error) { return newDAVProvider("https://" + rest) },
+	"s3":    newS3Provider,
+	// todoist:// or just todoist, an account on Todoist
+	"todoist": newTodoistProvider,
+}
+
+func openSyncProvider(target string) (SyncProvider, error) {
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok {
+		scheme, rest = "file", target
+	}
+	open, ok := syncProviders[scheme]
+	if !ok {
+		return nil, fmt.Errorf(T("unknown sync provider %q (available: %s)"), scheme, formatNames(syncProviders))
+	}
+	return open(rest)
+}
+
+// Works out what each side is missing, against what both had at the last
+// sync
+func planSync(local, remote []Task, base map[string]Task, provider SyncProvider, binding syncBinding, chosen map[string]Task) (pull, push []syncChange) {
+	remoteByUUID := make(map[string]Task, len(remote))
+	for _, task := range remote {
+		remoteByUUID[task.UUID] = task
+	}
+	remoteUnchanged := sameTask
+	if p, ok := provider.(partialProvider); ok {
+		remoteUnchanged = p.unchanged
+	}
+	localUUIDs := make(map[string]bool, len(local))
+	for _, task := range local {
+		localUUIDs[task.UUID] = true
+		other, ok := remoteByUUID[task.UUID]
+		if !ok {
+			// Gone from the other side since the last sync: deleted there,
+			// unless changed here since, as a change wins over a deletion
+			if synced, ok := base[task.UUID]; ok && sameTask(task, synced) {
+				pull = append(pull, syncChange{Op: "delete", Task: task})
+			} else {
+				push = append(push, syncChange{Op: "add", Task: task})
+			}
+			continue
+		}
+		if sameTask(task, other) {
+			continue
+		}
+		resolved, ok := chosen[task.UUID]
+		if !ok {
+			resolved = binding.resolve(provider, task, other)
+		}
+		if !sameTask(resolved, task) && !sameTask(resolved, other) {
+			// A merge is a change of its own, newer than either copy
+			resolved.Rev = max(task.Rev, other.Rev) + 1
+			resolved.UpdatedAt = time.Now().Format(time.RFC3339)
+		}
+		if !sameTask(resolved, task) {
+			pull = append(pull, syncChange{Op: "update", Task: resolved})
+		}
+		if !sameTask(resolved, other) {
+			push = append(push, syncChange{Op: "update", Task: resolved})
+		}
+	}
+	for _, task := range remote {
+		if localUUIDs[task.UUID] {
+			continue
+		}
+		if synced, ok := base[task.UUID]; ok && remoteUnchanged(task, synced) {
+			push = append(push, syncChange{Op: "delete", Task: task})
+		} else {
+			pull = append(pull, syncChange{Op: "add", Task: task})
+		}
+	}
+	return pull, push
+}
+
+// The provider's resolution, with the fields each side owns put back
+func (b syncBinding) resolve(provider SyncProvider, local, remote Task) Task {
+	resolved := provider.Resolve(local, remote)
+	resolved = takeFields(resolved, remote, b.RemoteOwns)
+	return takeFields(resolved, local, b.LocalOwns)
+}
+
+// A task changed on both sides, with the fields set to different values on
+// each. Resolved is what the sync would make of it unasked
+type syncConflict struct {
+	Local, Remote, Resolved Task
+	Fields                  []string
+}
+
+// Finds tasks with fields changed differently on the two sides since the
+// last sync, clearing a field included. Without a synced copy any field
+// that differs is a conflict. Fields a side owns never are
+func findConflicts(local, remote []Task, base map[string]Task, provider SyncProvider, binding syncBinding) []syncConflict {
+	remoteByUUID := make(map[string]Task, len(remote))
+	for _, task := range remote {
+		remoteByUUID[task.UUID] = task
+	}
+	var conflicts []syncConflict
+	for _, task := range local {
+		other, ok := remoteByUUID[task.UUID]
+		if !ok || sameTask(task, other) {
+			continue
+		}
+		synced, hasBase := base[task.UUID]
+		var fields []string
+		for _, name := range taskFieldNames(task, other) {
+			if slices.Contains(binding.RemoteOwns, name) || slices.Contains(binding.LocalOwns, name) {
+				continue
+			}
+			a, b := fieldValue(task, name), fieldValue(other, name)
+			if a == b {
+				continue
+			}
+			if was := fieldValue(synced, name); !hasBase || (a != was && b != was) {
+				fields = append(fields, name)
+			}
+		}
+		if len(fields) > 0 {
+			conflicts = append(conflicts, syncConflict{task, other, binding.resolve(provider, task, other), fields})
+		}
+	}
+	return conflicts
+}
+
+// JSON names of the fields worth comparing, then either task's custom ones
+func taskFieldNames(a, b Task) []string {
+	var names []string
+	taskType := reflect.TypeFor[Task]()
+	for i := range taskType.NumField() {
+		name, _, _ := strings.Cut(taskType.Field(i).Tag.Get("json"), ",")
+		if name != "" && !slices.Contains([]string{"id", "uuid", "hash", "rev", "updated_at", "fields"}, name) {
+			names = append(names, name)
+		}
+	}
+	custom := maps.Clone(a.Fields)
+	if custom == nil {
+		custom = map[string]string{}
+	}
+	maps.Copy(custom, b.Fields)
+	return append(names, sortedKeys(custom)...)
+}
+
+// A task field as text, by JSON or custom field name; "" when unset
+func fieldValue(task Task, name string) string {
+	i, ok := taskFieldIndex(name)
+	if !ok {
+		return task.Fields[name]
+	}
+	v := reflect.ValueOf(task).Field(i)
+	if v.IsZero() {
+		return ""
+	}
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for j := range parts {
+			parts[j] = fmt.Sprint(v.Index(j).Interface())
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// IDs are local to each store and revisions are bookkeeping, so neither
+// makes two copies different
+func sameTask(a, b Task) bool {
+	return todo.SameContent(a, b)
+}
+
+// Settles a task changed on both sides: the copy with the higher revision
+// wins, or at the same revision the later change. Copies saved before
+// tasks had revisions are merged instead
+func lastWriterWins(local, remote Task) Task {
+	if local.Rev == 0 && remote.Rev == 0 {
+		return mergeTask(local, remote)
+	}
+	if local.Rev != remote.Rev {
+		if remote.Rev > local.Rev {
+			return remote
+		}
+		return local
+	}
+	l, _ := time.Parse(time.RFC3339, local.UpdatedAt)
+	r, _ := time.Parse(time.RFC3339, remote.UpdatedAt)
+	if r.After(l) {
+		return remote
+	}
+	return local
+}
+
+// Applies pulled changes to the local list
+func (tl *TodoList) applySync(changes []syncChange) error {
+	for _, change := range changes {
+		switch change.Op {
+		case "add":
+			if _, err := tl.add(change.Task); err != nil {
+				return err
+			}
+			continue
+		case "delete":
+			if err := tl.deleteSynced(change.Task.UUID); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range tl.Tasks {
+			if tl.Tasks[i].UUID == change.Task.UUID {
+				change.Task.ID = tl.Tasks[i].ID
+				tl.Tasks[i] = change.Task
+			}
+		}
+	}
+	return nil
+}
+
+// Removes a task deleted on the other side, moving its subtasks up a level
+// as a delete here does
+func (tl *TodoList) deleteSynced(uuid string) error {
+	i := slices.IndexFunc(tl.Tasks, func(t Task) bool { return t.UUID == uuid })
+	if i < 0 {
+		return nil
+	}
+	task := tl.Tasks[i]
+	if _, err := runTaskHook("on-delete", task); err != nil {
+		return err
+	}
+	tl.Tasks = slices.Delete(tl.Tasks, i, i+1)
+	tl.adoptChildren(task)
+	return nil
+}
+
+func printSyncChanges(w io.Writer, header string, changes []syncChange) {
+	fmt.Fprintln(w, header)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, T("  nothing"))
+	}
+	for _, change := range changes {
+		switch change.Op {
+		case "add":
+			fmt.Fprintf(w, T("  add     %s\n"), change.Task.Title)
+		case "delete":
+			fmt.Fprintf(w, T("  delete  %s\n"), change.Task.Title)
+		default:
+			fmt.Fprintf(w, T("  update  %s\n"), change.Task.Title)
+		}
+	}
+}
+
+// Syncs the local list with a target in the binding's directions,
+// reporting to w. With dryRun the changes are only printed. Tasks changed
+// differently on both sides are handed to choose, when given, to settle
+// field by field; otherwise the provider resolves them and they are only
+// counted
+func syncTasks(w io.Writer, tl *TodoList, filename, target string, binding syncBinding, dryRun bool, choose func([]syncConflict) (map[string]Task, error)) error {
+	provider, err := openSyncProvider(target)
+	if err != nil {
+		return err
+	}
+	if p, ok := provider.(storeProvider); ok {
+		p.bindStore(tl, filename)
+	}
+	remote, err := provider.Pull()
+	if err != nil {
+		return err
+	}
+	base, err := loadSyncBase(filename, syncTargetKey(target))
+	if err != nil {
+		return err
+	}
+	var chosen map[string]Task
+	if conflicts := findConflicts(tl.Tasks, remote, base, provider, binding); len(conflicts) > 0 {
+		if choose == nil || dryRun {
+			fmt.Fprintf(w, T("%d tasks were changed differently on both sides; the latest change wins, or use --resolve to choose field by field\n"), len(conflicts))
+		} else if chosen, err = choose(conflicts); err != nil {
+			return err
+		}
+	}
+	pull, push := planSync(tl.Tasks, remote, base, provider, binding, chosen)
+	if desc := binding.describe(); desc != "" {
+		fmt.Fprintln(w, desc)
+	}
+	if binding.pulls() {
+		printSyncChanges(w, fmt.Sprintf(T("From %s:"), provider.Name()), pull)
+	} else {
+		pull = nil
+	}
+	if binding.pushes() {
+		printSyncChanges(w, fmt.Sprintf(T("To %s:"), provider.Name()), push)
+	} else {
+		push = nil
+	}
+	if dryRun {
+		fmt.Fprintln(w, T("Dry run; nothing was changed."))
+		return nil
+	}
+
+	if len(pull) > 0 {
+		if err := tl.Transaction(filename, func() error { return tl.applySync(pull) }); err != nil {
+			return err
+		}
+	}
+	if len(push) > 0 {
+		if err := provider.Push(push); err != nil {
+			return err
+		}
+	}
+	if p, ok := provider.(statefulProvider); ok {
+		if err := p.saveState(); err != nil {
+			return err
+		}
+	}
+	if p, ok := provider.(blobProvider); ok {
+		// Attached files follow the tasks that refer to them, including
+		// ones an earlier sync couldn't find yet
+		if binding.pulls() {
+			if _, err := copyMissingBlobs(p.BlobDir(), blobDir(filename), remote); err != nil {
+				return err
+			}
+		}
+		if binding.pushes() {
+			if _, err := copyMissingBlobs(blobDir(filename), p.BlobDir(), tl.Tasks); err != nil {
+				return err
+			}
+		}
+	}
+	// What both sides now have: the target's tasks when only pulling, as
+	// local changes stay here, and otherwise ours
+	agreed := tl.Tasks
+	if !binding.pushes() {
+		agreed = remote
+	}
+	if err := saveSyncBase(filename, syncTargetKey(target), agreed); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, T("Synced with %s: %d pulled, %d pushed\n"), provider.Name(), len(pull), len(push))
+	return nil
+}
+
+// What the store and each sync target last agreed on, by target and UUID,
+// so a task missing on one side can be told deleted from one never synced
+func syncBasePath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".sync.json"
+}
+
+func loadSyncBases(filename string) (map[string]map[string]Task, error) {
+	bases := map[string]map[string]Task{}
+	data, err := readSidecar(syncBasePath(filename))
+	if os.IsNotExist(err) {
+		return bases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &bases); err != nil {
+		return nil, fmt.Errorf("%s: %w", syncBasePath(filename), err)
+	}
+	return bases, nil
+}
+
+func loadSyncBase(filename, key string) (map[string]Task, error) {
+	bases, err := loadSyncBases(filename)
+	return bases[key], err
+}
+
+func saveSyncBase(filename, key string, tasks []Task) error {
+	bases, err := loadSyncBases(filename)
+	if err != nil {
+		return err
+	}
+	base := make(map[string]Task, len(tasks))
+	for _, task := range tasks {
+		task.ID = 0
+		base[task.UUID] = task
+	}
+	bases[key] = base
+	data, err := json.Marshal(bases)
+	if err != nil {
+		return err
+	}
+	if data, err = sealSidecar(data, storeEncrypted(filename)); err != nil {
+		return err
+	}
+	return os.WriteFile(syncBasePath(filename), data, 0644)
+}
+
+// Implemented by providers that keep state of their own next to the store
+type storeProvider interface {
+	bindStore(tl *TodoList, filename string)
+}
+
+// Implemented by providers with state of their own to save once a sync
+// has gone through, and not on a dry run
+type statefulProvider interface {
+	saveState() error
+}
+
+// Implemented by providers that keep only some of a task's fields, to tell
+// whether their copy changed since the last sync
+type partialProvider interface {
+	unchanged(task, synced Task) bool
+}
+
+// Implemented by providers with an attachment blob store of their own
+type blobProvider interface {
+	BlobDir() string
+}
+
+// How a sync target is used, remembered per target in the state file
+type syncBinding struct {
+	Direction string `json:"direction,omitempty"` // "" (both), "pull" or "push"
+	// Fields whose value from that side always wins
+	RemoteOwns []string `json:"remote_owns,omitempty"`
+	LocalOwns  []string `json:"local_owns,omitempty"`
+}
+
+var syncDirections = []string{"both", "pull", "push"}
+
+func (b syncBinding) pulls() bool  { return b.Direction != "push" }
+func (b syncBinding) pushes() bool { return b.Direction != "pull" }
+
+// Summarises a binding that isn't plain two-way sync, or returns ""
+func (b syncBinding) describe() string {
+	var parts []string
+	switch b.Direction {
+	case "pull":
+		parts = append(parts, T("pull only"))
+	case "push":
+		parts = append(parts, T("push only"))
+	}
+	if len(b.RemoteOwns) > 0 {
+		parts = append(parts, fmt.Sprintf(T("remote owns %s"), strings.Join(b.RemoteOwns, ", ")))
+	}
+	if len(b.LocalOwns) > 0 {
+		parts = append(parts, fmt.Sprintf(T("local owns %s"), strings.Join(b.LocalOwns, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(T("Sync settings: %s"), strings.Join(parts, "; "))
+}
+
+// Parses a --remote-owns/--local-owns list of task fields by their JSON
+// names (due, priority, tags, ...) or custom field names
+func parseOwnedFields(list string) ([]string, error) {
+	fields := splitFields(list)
+	for _, name := range fields {
+		if !fieldNameRe.MatchString(name) || slices.Contains([]string{"id", "uuid", "hash", "fields"}, name) {
+			return nil, fmt.Errorf(T("field %q can't be owned by one side"), name)
+		}
+	}
+	return fields, nil
+}
+
+// Copies the named fields from src onto task
+func takeFields(task, src Task, names []string) Task {
+	v, from := reflect.ValueOf(&task).Elem(), reflect.ValueOf(src)
+	for _, name := range names {
+		if i, ok := taskFieldIndex(name); ok {
+			v.Field(i).Set(from.Field(i))
+			continue
+		}
+		fields := maps.Clone(task.Fields)
+		if value, ok := src.Fields[name]; ok {
+			if fields == nil {
+				fields = map[string]string{}
+			}
+			fields[name] = value
+		} else {
+			delete(fields, name)
+		}
+		task.Fields = fields
+	}
+	return task
+}
+
+// Index of the built-in task field with this JSON name
+func taskFieldIndex(name string) (int, bool) {
+	taskType := reflect.TypeFor[Task]()
+	for i := range taskType.NumField() {
+		if tag, _, _ := strings.Cut(taskType.Field(i).Tag.Get("json"), ","); tag == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// A target as given on the command line; plain todoist is the account
+func syncTarget(target string) string {
+	if target == "todoist" {
+		return "todoist://"
+	}
+	return target
+}
+
+// Targets synced with before, by their keys
+func knownSyncTargets(filename string) ([]string, error) {
+	state, err := loadState(filename)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := loadSyncBases(filename)
+	if err != nil {
+		return nil, err
+	}
+	targets := slices.Collect(maps.Keys(bases))
+	for target := range state.Sync {
+		if _, ok := bases[target]; !ok {
+			targets = append(targets, target)
+		}
+	}
+	slices.Sort(targets)
+	return targets, nil
+}
+
+// Bindings are keyed by target; paths are made absolute so the same store
+// matches from any directory. A user and password in a URL are left out,
+// so they are never written to the state and sync files
+func syncTargetKey(target string) string {
+	if strings.Contains(target, "://") {
+		if u, err := url.Parse(target); err == nil && u.User != nil {
+			u.User = nil
+			return u.String()
+		}
+		return target
+	}
+	if abs, err := filepath.Abs(target); err == nil {
+		return abs
+	}
+	return target
+}
+
+// Handles `todo sync [--dry-run] [--resolve] [--direction d] [--remote-owns
+// f,...] [--local-owns f,...] <target>`. Binding flags are saved for the
+// target, so they only need giving once
+func syncCommand(filename string, args []string) error {
+	syncCmd := flag.NewFlagSet("sync", flag.ExitOnError)
+	dryRun := syncCmd.Bool("dry-run", false, "only print the changes each direction would make")
+	resolve := syncCmd.Bool("resolve", false, "settle tasks changed on both sides field by field, on a full screen")
+	direction := syncCmd.String("direction", "", "both, pull (only take changes) or push (only send them)")
+	remoteOwns := syncCmd.String("remote-owns", "", "comma-separated fields the target's value always wins for")
+	localOwns := syncCmd.String("local-owns", "", "comma-separated fields the local value always wins for")
+	syncCmd.Parse(args)
+	if syncCmd.NArg() != 1 {
+		return errors.New(T("sync target required, e.g. a path to another todo.json"))
+	}
+	target := syncTarget(syncCmd.Arg(0))
+
+	state, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+	key := syncTargetKey(target)
+	binding := state.Sync[key]
+	changed := false
+	var flagErr error
+	syncCmd.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "direction":
+			if !slices.Contains(syncDirections, *direction) {
+				flagErr = fmt.Errorf(T("unknown sync direction %q (use %s)"), *direction, strings.Join(syncDirections, ", "))
+			}
+			binding.Direction, changed = *direction, true
+			if *direction == "both" {
+				binding.Direction = ""
+			}
+		case "remote-owns":
+			binding.RemoteOwns, flagErr = parseOwnedFields(*remoteOwns)
+			changed = true
+		case "local-owns":
+			binding.LocalOwns, flagErr = parseOwnedFields(*localOwns)
+			changed = true
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+	for _, name := range binding.RemoteOwns {
+		if slices.Contains(binding.LocalOwns, name) {
+			return fmt.Errorf(T("field %q can't be owned by both sides"), name)
+		}
+	}
+	if changed && !*dryRun {
+		if state.Sync == nil {
+			state.Sync = map[string]syncBinding{}
+		}
+		if reflect.DeepEqual(binding, syncBinding{}) {
+			delete(state.Sync, key)
+		} else {
+			state.Sync[key] = binding
+		}
+		if err := saveState(filename, state); err != nil {
+			return err
+		}
+	}
+	var choose func([]syncConflict) (map[string]Task, error)
+	if *resolve {
+		choose = resolveConflicts
+	}
+	return syncTasks(os.Stdout, loadTodoList(filename), filename, target, binding, *dryRun, choose)
+}
+
+// Syncs with another todo store, e.g. one on a shared or cloud drive
+type fileProvider struct {
+	path  string
+	store *todo.List
+	// Whether our store is encrypted, so the other one is written sealed
+	encrypt bool
+}
+
+func newFileProvider(path string) (SyncProvider, error) {
+	if path == "" {
+		return nil, errors.New(T("sync target path required"))
+	}
+	return &fileProvider{path: path}, nil
+}
+
+func (p *fileProvider) Name() string {
+	return "file " + p.path
+}
+
+// The other store's blobs sit next to it, as ours do
+func (p *fileProvider) BlobDir() string {
+	return strings.TrimSuffix(p.path, ".json") + ".attachments"
+}
+
+func (p *fileProvider) bindStore(tl *TodoList, filename string) {
+	p.encrypt = storeEncrypted(filename)
+}
+
+// The other store, opened with our passphrase if it is encrypted
+func (p *fileProvider) fileStore() todo.FileStore {
+	store := todo.FileStore{Path: p.path}
+	if p.encrypt || fileEncrypted(p.path) {
+		store.Passphrase = func() (string, error) { return readPassphrase(false) }
+	}
+	return store
+}
+
+func (p *fileProvider) Pull() ([]Task, error) {
+	store, err := p.fileStore().Load()
+	if err != nil {
+		return nil, err
+	}
+	p.store = store
+	return store.Tasks, nil
+}
+
+// Writes straight to the other store; its hooks belong to whoever uses
+// it, so none run here. It is sealed when either store is encrypted
+func (p *fileProvider) Push(changes []syncChange) error {
+	applyChanges(p.store, changes)
+	return p.fileStore().Save(p.store)
+}
+
+// Applies changes to a list directly, keeping its IDs
+func applyChanges(l *todo.List, changes []syncChange) {
+	for _, change := range changes {
+		switch change.Op {
+		case "add":
+			l.Add(change.Task)
+			continue
+		case "delete":
+			l.Tasks = slices.DeleteFunc(l.Tasks, func(t Task) bool { return t.UUID == change.Task.UUID })
+			continue
+		}
+		for i := range l.Tasks {
+			if l.Tasks[i].UUID == change.Task.UUID {
+				change.Task.ID = l.Tasks[i].ID
+				l.Tasks[i] = change.Task
+			}
+		}
+	}
+}
+
+func (p *fileProvider) Resolve(local, remote Task) Task {
+	return lastWriterWins(local, remote)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Tags are single words so they survive org heading tags and todo.txt
+var tagRe = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// Lowercases a tag and drops a leading #, so "#Finance" and "finance" are
+// the same tag
+func parseTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if !tagRe.MatchString(tag) {
+		return "", fmt.Errorf(T("invalid tag %q (use letters, digits, - and _)"), tag)
+	}
+	return tag, nil
+}
+
+func parseTags(tags []string) ([]string, error) {
+	var parsed []string
+	for _, tag := range tags {
+		tag, err := parseTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(parsed, tag) {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed, nil
+}
+
+// Adds tags to a task, keeping the ones it has
+func (tl *TodoList) TagTask(id int, tags []string) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			for _, tag := range tags {
+				if !slices.Contains(tl.Tasks[i].Tags, tag) {
+					tl.Tasks[i].Tags = append(tl.Tasks[i].Tags, tag)
+				}
+			}
+			fmt.Printf(T("Task %d tags: %s\n"), id, formatTags(tl.Tasks[i].Tags))
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Removes tags from a task
+func (tl *TodoList) UntagTask(id int, tags []string) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Tags = slices.DeleteFunc(tl.Tasks[i].Tags, func(tag string) bool {
+				return slices.Contains(tags, tag)
+			})
+			if len(tl.Tasks[i].Tags) == 0 {
+				tl.Tasks[i].Tags = nil
+			}
+			fmt.Printf(T("Task %d tags: %s\n"), id, formatTags(tl.Tasks[i].Tags))
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Formats tags as "#a #b", or "none"
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return T("none")
+	}
+	return "#" + strings.Join(tags, " #")
+}
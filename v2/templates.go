@@ -0,0 +1,280 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+	"golang.org/x/term"
+)
+
+// A set of tasks kept as <name>.json in the templates directory. Text
+// fields may use parameters such as {{.ClientName}}; a due date is
+// expanded first and then read like --due, so "in {{.DueOffset}} days"
+// computes the date when the template is applied
+type taskTemplate struct {
+	Params []templateParam `json:"params"`
+	Tasks  []templateTask  `json:"tasks"`
+}
+
+type templateParam struct {
+	Name    string `json:"name"`
+	Prompt  string `json:"prompt,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+type templateTask struct {
+	Title    string         `json:"title"`
+	Due      string         `json:"due,omitempty"`
+	Priority string         `json:"priority,omitempty"`
+	Context  string         `json:"context,omitempty"`
+	Effort   string         `json:"effort,omitempty"`
+	Repeat   string         `json:"repeat,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`
+	Subtasks []templateTask `json:"subtasks,omitempty"`
+}
+
+var (
+	templateNameRe  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	templateParamRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Where templates are kept: $TODO_TEMPLATES_DIR or the user's config
+// directory
+func templatesDir() string {
+	if dir := cmp.Or(os.Getenv("TODO_TEMPLATES_DIR"), cfg.TemplatesDir); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "templates")
+}
+
+func loadTemplate(name string) (taskTemplate, error) {
+	var tmpl taskTemplate
+	if !templateNameRe.MatchString(name) {
+		return tmpl, fmt.Errorf(T("invalid template name %q"), name)
+	}
+	data, err := os.ReadFile(filepath.Join(templatesDir(), name+".json"))
+	if os.IsNotExist(err) {
+		return tmpl, fmt.Errorf(T("template %q not found in %s"), name, templatesDir())
+	}
+	if err != nil {
+		return tmpl, err
+	}
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return tmpl, fmt.Errorf(T("template %s: %v"), name, err)
+	}
+	for _, param := range tmpl.Params {
+		if !templateParamRe.MatchString(param.Name) {
+			return tmpl, fmt.Errorf(T("template %s: invalid parameter name %q"), name, param.Name)
+		}
+	}
+	return tmpl, nil
+}
+
+// Fills in every parameter: from --set, else by asking on a terminal,
+// else from its default
+func templateValues(tmpl taskTemplate, set map[string]string) (map[string]string, error) {
+	values := map[string]string{}
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	for _, param := range tmpl.Params {
+		value, ok := set[param.Name]
+		if !ok && interactive {
+			question := param.Prompt
+			if question == "" {
+				question = param.Name
+			}
+			if param.Default != "" {
+				question += " [" + param.Default + "]"
+			}
+			fmt.Printf("%s: ", question)
+			answer, _ := stdinReader.ReadString('\n')
+			value, ok = strings.TrimSpace(answer), true
+		}
+		if value == "" {
+			value = param.Default
+		}
+		if value == "" {
+			return nil, fmt.Errorf(T("no value for parameter %s (use --set %s=...)"), param.Name, param.Name)
+		}
+		values[param.Name] = value
+	}
+	for name := range set {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf(T("the template has no parameter %q"), name)
+		}
+	}
+	return values, nil
+}
+
+// Expands the parameters in one template field
+func expand(text string, values map[string]string) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, values); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Turns a template task into a task, checking its fields like add does
+func (tt templateTask) build(values map[string]string, now time.Time) (Task, error) {
+	var task Task
+	fields := []struct {
+		in  string
+		out *string
+	}{
+		{tt.Title, &task.Title},
+		{tt.Due, &task.Due},
+		{tt.Priority, &task.Priority},
+		{tt.Context, &task.Context},
+		{tt.Effort, &task.Effort},
+		{tt.Repeat, &task.Repeat},
+	}
+	for _, field := range fields {
+		value, err := expand(field.in, values)
+		if err != nil {
+			return task, err
+		}
+		*field.out = strings.TrimSpace(value)
+	}
+	for _, tag := range tt.Tags {
+		value, err := expand(tag, values)
+		if err != nil {
+			return task, err
+		}
+		task.Tags = append(task.Tags, value)
+	}
+	if task.Title = normalizeTitle(task.Title); task.Title == "" {
+		return task, errors.New(T("template task without a title"))
+	}
+	var err error
+	if task.Effort, err = parseEffort(task.Effort); err != nil {
+		return task, err
+	}
+	if task.Priority, err = parsePriority(task.Priority); err != nil {
+		return task, err
+	}
+	if task.Tags, err = parseTags(task.Tags); err != nil {
+		return task, err
+	}
+	if task.Repeat, err = parseRepeat(task.Repeat); err != nil {
+		return task, err
+	}
+	if task.Due != "" {
+		if task.Due, err = parseDate(task.Due, now); err != nil {
+			return task, err
+		}
+	}
+	task.Context = normalizeContext(task.Context)
+	return task, nil
+}
+
+// Adds the tasks of a template, subtasks under their parent
+func (tl *TodoList) applyTemplate(tasks []templateTask, parent string, values map[string]string, now time.Time) error {
+	for _, tt := range tasks {
+		task, err := tt.build(values, now)
+		if err != nil {
+			return err
+		}
+		task.UUID, task.Hash, task.Parent = todo.NewUUID(), todo.NewHash(), parent
+		if task, err = tl.add(withRules(task)); err != nil {
+			return err
+		}
+		fmt.Printf(T("Added task: %s (ID: %d)\n"), task.Title, task.ID)
+		if err := tl.applyTemplate(tt.Subtasks, task.UUID, values, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handles `todo template list` and `todo template apply <name> [--set k=v]`
+func templateCommand(filename string, args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	switch sub {
+	case "list":
+		entries, err := os.ReadDir(templatesDir())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		found := false
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || !templateNameRe.MatchString(name) {
+				continue
+			}
+			found = true
+			tmpl, err := loadTemplate(name)
+			if err != nil {
+				fmt.Printf(T("Error: %v\n"), err)
+				continue
+			}
+			var params []string
+			for _, param := range tmpl.Params {
+				params = append(params, param.Name)
+			}
+			fmt.Printf(T("%s: %d tasks"), name, len(tmpl.Tasks))
+			if len(params) > 0 {
+				fmt.Printf(T(", parameters %s"), strings.Join(params, ", "))
+			}
+			fmt.Println()
+		}
+		if !found {
+			fmt.Printf(T("No templates in %s\n"), templatesDir())
+		}
+		return nil
+
+	case "apply":
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New(T("usage: todo template apply <name> [--set Param=value ...]"))
+		}
+		name := args[0]
+		set := map[string]string{}
+		applyCmd := flag.NewFlagSet("template apply", flag.ExitOnError)
+		applyCmd.Func("set", "a parameter value, Param=value (repeatable)", func(arg string) error {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return errors.New(T("expected Param=value"))
+			}
+			set[strings.TrimSpace(key)] = value
+			return nil
+		})
+		applyCmd.Parse(args[1:])
+		if applyCmd.NArg() > 0 {
+			return errors.New(T("usage: todo template apply <name> [--set Param=value ...]"))
+		}
+		tmpl, err := loadTemplate(name)
+		if err != nil {
+			return err
+		}
+		values, err := templateValues(tmpl, set)
+		if err != nil {
+			return err
+		}
+		tl := loadTodoList(filename)
+		return tl.Transaction(filename, func() error {
+			return tl.applyTemplate(tmpl.Tasks, "", values, time.Now())
+		})
+	}
+	return fmt.Errorf(T("unknown template command %q"), sub)
+}
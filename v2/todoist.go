@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// Todoist's API; TODO_TODOIST_URL points elsewhere, e.g. at a test server
+const todoistAPI = "https://api.todoist.com/api/v1"
+
+// A task as Todoist's API has it
+type todoistTask struct {
+	ID        string   `json:"id"`
+	Content   string   `json:"content"`
+	ProjectID string   `json:"project_id"`
+	Priority  int      `json:"priority"` // 4 is the most urgent, 1 none
+	Labels    []string `json:"labels"`
+	Due       *struct {
+		Date string `json:"date"` // YYYY-MM-DD, or with a time after it
+	} `json:"due"`
+	Checked     bool   `json:"checked"`
+	IsDeleted   bool   `json:"is_deleted"`
+	AddedAt     string `json:"added_at"`
+	UpdatedAt   string `json:"updated_at"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type todoistProject struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Inbox bool   `json:"inbox_project"`
+}
+
+// The part of a task both sides keep. Projects are the task's project
+// field; tasks in the Todoist inbox have none
+type todoistFields struct {
+	Title    string   `json:"title"`
+	Project  string   `json:"project,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	Due      string   `json:"due,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Done     bool     `json:"done,omitempty"`
+}
+
+func todoistView(task Task) todoistFields {
+	return todoistFields{
+		Title:    task.Title,
+		Project:  task.Fields["project"],
+		Priority: task.Priority,
+		Due:      task.Due,
+		Tags:     slices.Clip(slices.Clone(task.Tags)),
+		Done:     task.Completed,
+	}
+}
+
+func (f todoistFields) equal(g todoistFields) bool {
+	return f.Title == g.Title && f.Project == g.Project && f.Priority == g.Priority &&
+		f.Due == g.Due && slices.Equal(f.Tags, g.Tags) && f.Done == g.Done
+}
+
+// Sets the shared fields on a task, leaving the rest of it alone
+func (f todoistFields) apply(task Task) Task {
+	task.Title, task.Priority, task.Due, task.Tags = f.Title, f.Priority, f.Due, slices.Clone(f.Tags)
+	fields := maps.Clone(task.Fields)
+	if f.Project != "" {
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		fields["project"] = f.Project
+	} else {
+		delete(fields, "project")
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	task.Fields = fields
+	if f.Done != task.Completed {
+		task.Completed = f.Done
+		task.CompletedAt = ""
+		if f.Done {
+			task.CompletedAt = time.Now().Format(time.RFC3339)
+		}
+	}
+	return task
+}
+
+// Todoist's four priorities; the lowest is no priority
+var todoistPriorities = map[int]string{4: "high", 3: "medium", 2: "low"}
+
+func todoistPriority(priority string) int {
+	for level, name := range todoistPriorities {
+		if name == priority {
+			return level
+		}
+	}
+	return 1
+}
+
+// How a local task and a Todoist task are linked, kept in
+// todo.todoist.json by local UUID. Synced is what both sides last agreed
+// on, so a field changed on one side only is told apart from one changed
+// on both
+type todoistLink struct {
+	ID      string        `json:"id"`
+	Synced  todoistFields `json:"synced"`
+	Deleted bool          `json:"deleted,omitempty"`
+}
+
+func todoistLinksPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".todoist.json"
+}
+
+func loadTodoistLinks(filename string) (map[string]*todoistLink, error) {
+	links := map[string]*todoistLink{}
+	data, err := readSidecar(todoistLinksPath(filename))
+	if os.IsNotExist(err) {
+		return links, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("%s: %w", todoistLinksPath(filename), err)
+	}
+	return links, nil
+}
+
+func saveTodoistLinks(filename string, links map[string]*todoistLink) error {
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = sealSidecar(data, storeEncrypted(filename)); err != nil {
+		return err
+	}
+	return os.WriteFile(todoistLinksPath(filename), data, 0644)
+}
+
+// Syncs with a Todoist account, `todo sync todoist`. Tasks are linked
+// through the table in todo.todoist.json rather than by UUID, which
+// Todoist doesn't keep, so a task is never created twice. Title, project,
+// priority, due date, labels and completion go both ways; everything else
+// stays local
+type todoistProvider struct {
+	api, token string
+	filename   string
+	local      map[string]Task
+	links      map[string]*todoistLink
+	// Project names by ID and the inbox's ID
+	projects map[string]string
+	inbox    string
+	// What Todoist has, by local UUID, as of the pull
+	remote map[string]todoistFields
+}
+
+func newTodoistProvider(string) (SyncProvider, error) {
+	token := os.Getenv("TODO_TODOIST_TOKEN")
+	if token == "" {
+		token = cfg.TodoistToken
+	}
+	if token == "" {
+		var err error
+		if token, err = keychainSecret("todo-cli", "todoist"); err != nil || token == "" {
+			return nil, errors.New(T("no Todoist API token: set todoist_token in the config file or TODO_TODOIST_TOKEN, or store it in the keychain (service todo-cli, account todoist)"))
+		}
+	}
+	api := strings.TrimSuffix(os.Getenv("TODO_TODOIST_URL"), "/")
+	if api == "" {
+		api = todoistAPI
+	}
+	return &todoistProvider{api: api, token: token}, nil
+}
+
+func (p *todoistProvider) Name() string {
+	return "Todoist"
+}
+
+// The table lives next to the store
+func (p *todoistProvider) bindStore(tl *TodoList, filename string) {
+	p.filename = filename
+	p.local = make(map[string]Task, len(tl.Tasks))
+	for _, task := range tl.Tasks {
+		p.local[task.UUID] = task
+	}
+}
+
+// Returns Todoist's tasks as local tasks: a linked task is its local copy
+// with Todoist's values for the shared fields. Done tasks Todoist never
+// had and tasks deleted there come back as they are here, so they aren't
+// sent again. New links are only kept in memory until saveState
+func (p *todoistProvider) Pull() ([]Task, error) {
+	if p.local == nil {
+		return nil, errors.New(T("todoist sync needs a local store"))
+	}
+	var err error
+	if p.links, err = loadTodoistLinks(p.filename); err != nil {
+		return nil, err
+	}
+	projects, err := todoistPages[todoistProject](p, "/projects")
+	if err != nil {
+		return nil, err
+	}
+	p.projects = map[string]string{}
+	for _, project := range projects {
+		if project.Inbox {
+			p.inbox = project.ID
+			continue
+		}
+		p.projects[project.ID] = project.Name
+	}
+	active, err := todoistPages[todoistTask](p, "/tasks")
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := make(map[string]string, len(p.links))
+	for uuid, link := range p.links {
+		uuids[link.ID] = uuid
+	}
+	p.remote = map[string]todoistFields{}
+	var tasks []Task
+	for _, t := range active {
+		uuid, ok := uuids[t.ID]
+		if !ok {
+			uuid = todo.NewUUID()
+			p.links[uuid] = &todoistLink{ID: t.ID}
+		}
+		tasks = append(tasks, p.remoteTask(uuid, t))
+	}
+	for uuid, link := range p.links {
+		local, ok := p.local[uuid]
+		if _, seen := p.remote[uuid]; seen || !ok {
+			continue
+		}
+		if link.Deleted {
+			tasks = append(tasks, local)
+			continue
+		}
+		// Not among the open tasks: done or deleted in Todoist. Tasks
+		// known to be done don't need asking about again
+		if link.Synced.Done {
+			p.remote[uuid] = link.Synced
+			tasks = append(tasks, link.Synced.apply(local))
+			continue
+		}
+		var t todoistTask
+		err := p.call(http.MethodGet, "/tasks/"+url.PathEscape(link.ID), nil, &t)
+		var status todoistStatus
+		if (errors.As(err, &status) && status == http.StatusNotFound) || (err == nil && t.IsDeleted) {
+			link.Deleted = true
+			tasks = append(tasks, local)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, p.remoteTask(uuid, t))
+	}
+	for uuid, task := range p.local {
+		if _, linked := p.links[uuid]; !linked && task.Completed {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// Saves the link table once the sync has gone through
+func (p *todoistProvider) saveState() error {
+	return saveTodoistLinks(p.filename, p.links)
+}
+
+// A Todoist task as a local one
+func (p *todoistProvider) remoteTask(uuid string, t todoistTask) Task {
+	view := todoistFields{Title: t.Content, Project: p.projects[t.ProjectID], Priority: todoistPriorities[t.Priority], Tags: slices.Clip(t.Labels), Done: t.Checked}
+	if len(view.Tags) == 0 {
+		view.Tags = nil
+	}
+	if t.Due != nil && len(t.Due.Date) >= len(dateLayout) {
+		view.Due = t.Due.Date[:len(dateLayout)]
+	}
+	p.remote[uuid] = view
+	local, ok := p.local[uuid]
+	if !ok {
+		task := view.apply(Task{UUID: uuid, Hash: todo.NewHash(), CreatedAt: todoistTime(t.AddedAt)})
+		if t.Checked {
+			task.CompletedAt = todoistTime(t.CompletedAt)
+		}
+		return task
+	}
+	// Both sides agree: that is the base for the next sync
+	if todoistView(local).equal(view) {
+		p.links[uuid].Synced = view
+	}
+	// Dated by Todoist's last change, for Resolve to weigh against ours
+	task := view.apply(local)
+	task.UpdatedAt = cmp.Or(todoistTime(t.UpdatedAt), task.UpdatedAt)
+	return task
+}
+
+// Todoist's timestamps carry fractions of a second; ours don't
+func todoistTime(s string) string {
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return ""
+	}
+	return at.UTC().Format(time.RFC3339)
+}
+
+// Field by field: a value changed on one side since the last sync wins,
+// and one changed on both takes the later change
+func (p *todoistProvider) Resolve(local, remote Task) Task {
+	var base todoistFields
+	if link, ok := p.links[local.UUID]; ok {
+		base = link.Synced
+	}
+	l, r := todoistView(local), todoistView(remote)
+	lt, _ := time.Parse(time.RFC3339, local.UpdatedAt)
+	rt, _ := time.Parse(time.RFC3339, remote.UpdatedAt)
+	localNewer := lt.After(rt)
+	merged := r
+	lv, bv, mv := reflect.ValueOf(l), reflect.ValueOf(base), reflect.ValueOf(&merged).Elem()
+	for i := range mv.NumField() {
+		ours, theirs, was := lv.Field(i).Interface(), mv.Field(i).Interface(), bv.Field(i).Interface()
+		if reflect.DeepEqual(theirs, was) || (!reflect.DeepEqual(ours, was) && localNewer) {
+			mv.Field(i).Set(lv.Field(i))
+		}
+	}
+	return merged.apply(local)
+}
+
+// Todoist keeps only the shared fields, so only those can have changed
+func (p *todoistProvider) unchanged(task, synced Task) bool {
+	return todoistView(task).equal(todoistView(synced))
+}
+
+// Creates, updates, moves, completes, reopens and deletes tasks in
+// Todoist. The link table is saved even when a change fails, so tasks
+// created before the failure are linked
+func (p *todoistProvider) Push(changes []syncChange) (err error) {
+	defer func() {
+		err = errors.Join(err, saveTodoistLinks(p.filename, p.links))
+	}()
+	for _, change := range changes {
+		view := todoistView(change.Task)
+		link, linked := p.links[change.Task.UUID]
+		if change.Op == "delete" {
+			if linked && !link.Deleted {
+				err := p.call(http.MethodDelete, "/tasks/"+url.PathEscape(link.ID), nil, nil)
+				var status todoistStatus
+				if err != nil && !(errors.As(err, &status) && status == http.StatusNotFound) {
+					return err
+				}
+			}
+			delete(p.links, change.Task.UUID)
+			continue
+		}
+		if change.Op == "add" || !linked {
+			if view.Done {
+				continue
+			}
+			var created todoistTask
+			if err := p.call(http.MethodPost, "/tasks", p.taskBody(view, true), &created); err != nil {
+				return err
+			}
+			p.links[change.Task.UUID] = &todoistLink{ID: created.ID, Synced: view}
+			continue
+		}
+		old := p.remote[change.Task.UUID]
+		if link.Deleted || view.equal(old) {
+			link.Synced = view
+			continue
+		}
+		path := "/tasks/" + url.PathEscape(link.ID)
+		if view.Project != old.Project {
+			id, err := p.projectID(view.Project)
+			if err != nil {
+				return err
+			}
+			if err := p.call(http.MethodPost, path+"/move", map[string]string{"project_id": id}, nil); err != nil {
+				return err
+			}
+		}
+		if view.Title != old.Title || view.Priority != old.Priority || view.Due != old.Due || !slices.Equal(view.Tags, old.Tags) {
+			if err := p.call(http.MethodPost, path, p.taskBody(view, false), nil); err != nil {
+				return err
+			}
+		}
+		if view.Done != old.Done {
+			action := "/reopen"
+			if view.Done {
+				action = "/close"
+			}
+			if err := p.call(http.MethodPost, path+action, nil, nil); err != nil {
+				return err
+			}
+		}
+		link.Synced = view
+	}
+	return nil
+}
+
+// The body of a create or update request
+func (p *todoistProvider) taskBody(view todoistFields, create bool) map[string]any {
+	body := map[string]any{
+		"content":  view.Title,
+		"priority": todoistPriority(view.Priority),
+		"labels":   todoistLabels(view.Tags),
+	}
+	if view.Due != "" {
+		body["due_date"] = view.Due
+	} else if !create {
+		body["due_string"] = "no date"
+	}
+	if create && view.Project != "" {
+		// Looked up, or created, on the way; the inbox on failure
+		if id, err := p.projectID(view.Project); err == nil {
+			body["project_id"] = id
+		} else {
+			slog.Info("todoist project not set", "project", view.Project, "err", err)
+		}
+	}
+	return body
+}
+
+// An empty list rather than null, so Todoist clears the labels
+func todoistLabels(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// The ID of the project with this name, creating it if there is none;
+// "" is the inbox
+func (p *todoistProvider) projectID(name string) (string, error) {
+	if name == "" {
+		return p.inbox, nil
+	}
+	for id, project := range p.projects {
+		if project == name {
+			return id, nil
+		}
+	}
+	var created todoistProject
+	if err := p.call(http.MethodPost, "/projects", map[string]string{"name": name}, &created); err != nil {
+		return "", err
+	}
+	p.projects[created.ID] = name
+	return created.ID, nil
+}
+
+// An HTTP status Todoist answered with
+type todoistStatus int
+
+func (s todoistStatus) Error() string {
+	return fmt.Sprintf(T("Todoist answered %d %s"), int(s), http.StatusText(int(s)))
+}
+
+// Sends a request to the API, decoding the answer into out
+func (p *todoistProvider) call(method, path string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, p.api+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := syncClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		slog.Debug("todoist error", "path", path, "body", string(detail))
+		return todoistStatus(resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Fetches every page of a list endpoint
+func todoistPages[E any](p *todoistProvider, path string) ([]E, error) {
+	var all []E
+	cursor := ""
+	for {
+		query := url.Values{"limit": {"200"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page struct {
+			Results    []E    `json:"results"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := p.call(http.MethodGet, path+"?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Results...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// The todo.txt format (github.com/todotxt/todo.txt), one task per line:
+//
+//	x 2026-10-17 2026-10-01 Call the bank +finance @phone due:2026-10-20
+var (
+	todoTxtDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtPriRe  = regexp.MustCompile(`^\(([A-Z])\)$`)
+)
+
+// Keys read into built-in fields rather than custom ones; custom fields
+// under these names aren't written
+var todoTxtKeys = map[string]bool{
+	"created": true, "due": true, "rec": true, "effort": true, "pri": true, "parent": true, "uuid": true,
+	"notes": true, "pinned": true, "waiting": true, "waiting_on": true, "follow_up": true, "someday": true,
+	"goal": true, "att": true, "rev": true, "updated": true,
+}
+
+// Values are escaped so spaces, line breaks and colons, as in notes or
+// rec:every:3d, keep a key:value pair one word. A leading slash is
+// escaped too, since pairs whose value starts with one read as URLs
+var (
+	todoTxtEscaper   = strings.NewReplacer("%", "%25", " ", "%20", "\t", "%09", "\n", "%0A", "\r", "%0D", ":", "%3A")
+	todoTxtUnescaper = strings.NewReplacer("%25", "%", "%20", " ", "%09", "\t", "%0A", "\n", "%0D", "\r", "%3A", ":", "%2F", "/")
+)
+
+func escapeTodoTxt(value string) string {
+	value = todoTxtEscaper.Replace(value)
+	if rest, ok := strings.CutPrefix(value, "/"); ok {
+		value = "%2F" + rest
+	}
+	return value
+}
+
+// Letters for each priority, the reverse of orgPriorities; (D) and below
+// read as low
+var todoTxtLetters = map[string]string{"high": "A", "medium": "B", "low": "C"}
+
+// Writes tasks as todo.txt lines. The layout is fixed, so --fields doesn't
+// apply
+func exportTodoTxt(w io.Writer, tasks []Task, fields []string) error {
+	if fields != nil {
+		return errors.New(T("the todotxt format doesn't support --fields; use csv or json"))
+	}
+	bw := bufio.NewWriter(w)
+	for _, task := range tasks {
+		fmt.Fprintln(bw, todoTxtLine(task))
+	}
+	return bw.Flush()
+}
+
+func todoTxtLine(task Task) string {
+	var words []string
+	if task.Completed {
+		words = append(words, "x")
+		if len(task.CompletedAt) >= len(dateLayout) {
+			words = append(words, task.CompletedAt[:len(dateLayout)])
+		}
+	} else if letter, ok := todoTxtLetters[task.Priority]; ok {
+		words = append(words, "("+letter+")")
+	}
+	// The creation date only counts after a completion date on done tasks.
+	// Tasks imported before created_at existed keep it as a field
+	created := cmp.Or(task.CreatedAt, task.Fields["created"])
+	if len(created) >= len(dateLayout) {
+		created = created[:len(dateLayout)]
+	}
+	if todoTxtDateRe.MatchString(created) && (!task.Completed || len(words) > 1) {
+		words = append(words, created)
+	}
+	words = append(words, task.Title)
+	for _, tag := range task.Tags {
+		words = append(words, "+"+tag)
+	}
+	if task.Context != "" {
+		words = append(words, task.Context)
+	}
+	pairs := [][2]string{{"due", task.Due}, {"rec", task.Repeat}, {"effort", task.Effort}}
+	// Done tasks lose their (A) marker, as in todo.sh; keep it as pri:
+	if task.Completed {
+		pairs = append(pairs, [2]string{"pri", todoTxtLetters[task.Priority]})
+	}
+	flag := func(set bool) string {
+		if set {
+			return "1"
+		}
+		return ""
+	}
+	number := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	pairs = append(pairs,
+		[2]string{"pinned", flag(task.Pinned)},
+		[2]string{"someday", flag(task.Someday)},
+		[2]string{"waiting", flag(task.Waiting)},
+		[2]string{"waiting_on", task.WaitingOn},
+		[2]string{"follow_up", task.FollowUp},
+		[2]string{"goal", number(task.Goal)})
+	for _, attachment := range task.Attachments {
+		pairs = append(pairs, [2]string{"att", attachment})
+	}
+	for _, key := range sortedKeys(task.Fields) {
+		if !todoTxtKeys[key] {
+			pairs = append(pairs, [2]string{key, task.Fields[key]})
+		}
+	}
+	pairs = append(pairs,
+		[2]string{"notes", task.Notes},
+		[2]string{"parent", task.Parent},
+		[2]string{"uuid", task.UUID},
+		[2]string{"rev", number(task.Rev)},
+		[2]string{"updated", task.UpdatedAt})
+	for _, pair := range pairs {
+		if pair[1] != "" {
+			words = append(words, pair[0]+":"+escapeTodoTxt(pair[1]))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Reads a todo.txt line into a task; ok is false for blank lines
+func parseTodoTxtLine(line string) (task Task, ok bool) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return Task{}, false
+	}
+	if words[0] == "x" {
+		task.Completed = true
+		words = words[1:]
+		if len(words) > 0 && todoTxtDateRe.MatchString(words[0]) {
+			if done, err := time.ParseInLocation(dateLayout, words[0], time.Local); err == nil {
+				task.CompletedAt = done.Format(time.RFC3339)
+			}
+			words = words[1:]
+		}
+	}
+	if len(words) > 0 {
+		if m := todoTxtPriRe.FindStringSubmatch(words[0]); m != nil {
+			task.Priority = cmp.Or(orgPriorities[m[1]], "low")
+			words = words[1:]
+		}
+	}
+	if len(words) > 0 && todoTxtDateRe.MatchString(words[0]) {
+		if created, err := time.ParseInLocation(dateLayout, words[0], time.Local); err == nil {
+			task.CreatedAt = created.Format(time.RFC3339)
+		}
+		words = words[1:]
+	}
+
+	contextAt := -1
+	for i, word := range words {
+		if strings.HasPrefix(word, "@") && len(word) > 1 {
+			contextAt = i
+		}
+	}
+	var title []string
+	for i, word := range words {
+		if tag, found := strings.CutPrefix(word, "+"); found {
+			if tag, err := parseTag(tag); err == nil {
+				if !slices.Contains(task.Tags, tag) {
+					task.Tags = append(task.Tags, tag)
+				}
+				continue
+			}
+		}
+		if i == contextAt {
+			task.Context = word
+			continue
+		}
+		if todoTxtPair(&task, word) {
+			continue
+		}
+		title = append(title, word)
+	}
+	task.Title = strings.Join(title, " ")
+	return task, true
+}
+
+// Reads a key:value word into the task. Words that only look like pairs,
+// such as URLs and times of day, are left in the title
+func todoTxtPair(task *Task, word string) bool {
+	key, value, found := strings.Cut(word, ":")
+	if !found || value == "" || strings.Contains(value, ":") || strings.HasPrefix(value, "/") || !fieldNameRe.MatchString(key) {
+		return false
+	}
+	value = todoTxtUnescaper.Replace(value)
+	switch key {
+	case "due":
+		if !todoTxtDateRe.MatchString(value) {
+			return false
+		}
+		task.Due = value
+	case "rec":
+		repeat, err := parseRepeat(value)
+		if err != nil {
+			return false
+		}
+		task.Repeat = repeat
+	case "effort":
+		effort, err := parseEffort(value)
+		if err != nil {
+			return false
+		}
+		task.Effort = effort
+	case "pri":
+		task.Priority = cmp.Or(orgPriorities[value], "low")
+	case "parent":
+		task.Parent = strings.ToLower(value)
+	case "uuid":
+		task.UUID = strings.ToLower(value)
+	case "notes":
+		task.Notes = value
+	case "pinned":
+		task.Pinned = value == "1"
+	case "someday":
+		task.Someday = value == "1"
+	case "waiting":
+		task.Waiting = value == "1"
+	case "waiting_on":
+		task.WaitingOn = value
+	case "follow_up":
+		if !todoTxtDateRe.MatchString(value) {
+			return false
+		}
+		task.FollowUp = value
+	case "att":
+		task.Attachments = append(task.Attachments, value)
+	case "goal", "rev":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return false
+		}
+		if key == "goal" {
+			task.Goal = n
+		} else {
+			task.Rev = n
+		}
+	case "updated":
+		task.UpdatedAt = value
+	default:
+		if task.Fields == nil {
+			task.Fields = map[string]string{}
+		}
+		task.Fields[key] = value
+	}
+	return true
+}
+
+func importTodoTxt(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if task, ok := parseTodoTxtLine(scanner.Text()); ok && task.Title != "" {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, scanner.Err()
+}
+
+// The todo.txt file lives next to where todo.json would be
+func todoTxtPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".txt"
+}
+
+// Keeps the list in a todo.txt file (--backend todotxt), so other todo.txt
+// apps can share it. As in todo.sh, a task's ID is its line number; saving
+// leaves blank lines for deleted tasks so the others keep their IDs
+type todoTxtStore struct {
+	path string
+}
+
+var _ todo.Store = todoTxtStore{}
+
+func (s todoTxtStore) Load() (*todo.List, error) {
+	list := &todo.List{Tasks: []Task{}}
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		task, ok := parseTodoTxtLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		task.ID = line
+		todo.FillIdentity(&task)
+		// The file has no hashes; derive them from the UUID, which is saved,
+		// so refs hold even when the title is edited
+		sum := sha1.Sum([]byte(task.UUID))
+		task.Hash = hex.EncodeToString(sum[:10])
+		list.Tasks = append(list.Tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return list, nil
+}
+
+// Writes each task on the line of its ID, through a temp file renamed over
+// the old one
+func (s todoTxtStore) Save(list *todo.List) error {
+	lines := map[int]string{}
+	last := 0
+	for _, task := range list.Tasks {
+		lines[task.ID] = todoTxtLine(task)
+		last = max(last, task.ID)
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	for id := 1; id <= last; id++ {
+		fmt.Fprintln(bw, lines[id])
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(s.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	day := func(d int) string {
+		return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local).Format(time.RFC3339)
+	}
+	tasks := []Task{
+		{
+			ID: 1, UUID: "0b0e9a2c-7f5d-4e21-9d43-5a1b2c3d4e5f", Title: "Call the bank",
+			CreatedAt: day(1), Priority: "high", Due: "2026-10-20", Tags: []string{"finance"}, Context: "@phone",
+			Repeat: "every:3d", Effort: "quick", Notes: "Ask about: fees\n50% off /promo",
+			Pinned: true, Waiting: true, WaitingOn: "Ann Smith", FollowUp: "2026-10-22", Goal: 2,
+			Rev: 3, UpdatedAt: "2026-10-02T10:30:00Z",
+			Attachments: []string{"https://example.com/a b", "/home/me/statement.pdf"},
+			Fields:      map[string]string{"client": "ACME Corp", "url": "https://acme.example"},
+		},
+		{
+			ID: 3, UUID: "1c1f8b3d-6e4c-4d10-8c32-4b0a1b2c3d4e", Title: "File taxes", Completed: true,
+			CreatedAt: day(2), CompletedAt: day(17), Priority: "medium", Someday: true,
+			Parent: "0b0e9a2c-7f5d-4e21-9d43-5a1b2c3d4e5f", Rev: 1,
+		},
+	}
+	store := todoTxtStore{path: filepath.Join(t.TempDir(), "todo.txt")}
+	if err := store.Save(&todo.List{Tasks: tasks}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Tasks) != len(tasks) {
+		t.Fatalf("loaded %d tasks, want %d", len(list.Tasks), len(tasks))
+	}
+	for i, got := range list.Tasks {
+		got.Hash = ""
+		if !reflect.DeepEqual(got, tasks[i]) {
+			t.Errorf("task %d read back as\n%+v\nwant\n%+v", tasks[i].ID, got, tasks[i])
+		}
+	}
+}
+
+func TestTodoTxtLine(t *testing.T) {
+	tests := []struct {
+		task Task
+		want string
+	}{
+		{Task{Title: "Water plants", Repeat: "every:3d"}, "Water plants rec:every%3A3d"},
+		{Task{Title: "Pay rent", Repeat: "monthly", Due: "2026-11-01"}, "Pay rent due:2026-11-01 rec:monthly"},
+		{Task{Title: "Read", Notes: "chapter 2"}, "Read notes:chapter%202"},
+		{Task{Title: "Plan", Fields: map[string]string{"where": "/srv"}}, "Plan where:%2Fsrv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.task.Title, func(t *testing.T) {
+			if got := todoTxtLine(tt.task); got != tt.want {
+				t.Errorf("todoTxtLine = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
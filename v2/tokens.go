@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Access tokens for `todo serve` and its calendar feed. Each is scoped to
+// some lists, read or write access and optionally a set of task fields,
+// and can expire. Only a SHA-256 hash of the secret is kept; the token
+// itself is shown once, when it is created
+type accessToken struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Hash    string    `json:"hash"`
+	Lists   []string  `json:"lists,omitempty"`
+	Write   bool      `json:"write,omitempty"`
+	Fields  []string  `json:"fields,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Where tokens are kept: $TODO_TOKENS_FILE, or tokens.json in the user's
+// config directory, so one token can cover several lists
+func tokensPath() string {
+	if path := os.Getenv("TODO_TOKENS_FILE"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "todo.tokens.json"
+	}
+	return filepath.Join(dir, "todo", "tokens.json")
+}
+
+func loadTokens() ([]accessToken, error) {
+	var tokens []accessToken
+	data, err := os.ReadFile(tokensPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, json.Unmarshal(data, &tokens)
+}
+
+// Reports whether a token was ever created. The file stays behind when
+// the last one is revoked, so that doesn't open up todo serve again
+func tokensSetUp() bool {
+	_, err := os.Stat(tokensPath())
+	return !os.IsNotExist(err)
+}
+
+func saveTokens(tokens []accessToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(tokensPath()), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(tokensPath(), data, 0600)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Finds the token a client presented, todo_<id>_<secret>; false if it is
+// unknown, wrong or expired
+func findToken(tokens []accessToken, presented string, now time.Time) (accessToken, bool) {
+	rest, ok := strings.CutPrefix(presented, "todo_")
+	id, secret, ok2 := strings.Cut(rest, "_")
+	if !ok || !ok2 {
+		return accessToken{}, false
+	}
+	for _, token := range tokens {
+		if token.ID != id {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.Hash)) != 1 {
+			return accessToken{}, false
+		}
+		if !token.Expires.IsZero() && now.After(token.Expires) {
+			return accessToken{}, false
+		}
+		return token, true
+	}
+	return accessToken{}, false
+}
+
+// Reports whether the token may be used on the list
+func (t accessToken) covers(list string) bool {
+	return len(t.Lists) == 0 || slices.ContainsFunc(t.Lists, func(l string) bool { return strings.EqualFold(l, list) })
+}
+
+// Cuts tasks down to the fields the token may read. id and uuid always
+// stay, so clients can refer back to the task
+func (t accessToken) project(tasks []Task) []map[string]any {
+	out := make([]map[string]any, len(tasks))
+	for i, task := range tasks {
+		columns := taskColumns(task)
+		for name := range columns {
+			if name != "id" && name != "uuid" && !slices.Contains(t.Fields, name) {
+				delete(columns, name)
+			}
+		}
+		out[i] = columns
+	}
+	return out
+}
+
+func (t accessToken) describe() (lists, access, fields, expires string) {
+	lists, access, fields, expires = T("all"), T("read"), T("all"), T("never")
+	if len(t.Lists) > 0 {
+		lists = strings.Join(t.Lists, ",")
+	}
+	if t.Write {
+		access = T("read, write")
+	}
+	if len(t.Fields) > 0 {
+		fields = strings.Join(t.Fields, ",")
+	}
+	if !t.Expires.IsZero() {
+		expires = t.Expires.Local().Format(dateLayout)
+	}
+	return lists, access, fields, expires
+}
+
+// Handles `todo token create|list|revoke`
+func tokenCommand(filename string, args []string) error {
+	usage := errors.New(T("usage: todo token create [--name n] [--list l,...] [--write] [--fields f,...] [--expires 30d] | list | revoke <id>"))
+	if len(args) == 0 {
+		return usage
+	}
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	switch args[0] {
+	case "create":
+		createCmd := flag.NewFlagSet("token create", flag.ExitOnError)
+		name := createCmd.String("name", "", "what the token is for")
+		lists := createCmd.String("list", listName(filename), "lists it may use, comma-separated; \"\" for all")
+		write := createCmd.Bool("write", false, "allow changes, not only reading")
+		fields := createCmd.String("fields", "", "task fields it may read, comma-separated; all by default")
+		expires := createCmd.String("expires", "", "last day it works: YYYY-MM-DD, 30d, next month")
+		createCmd.Parse(args[1:])
+		token := accessToken{ID: randomHex(4), Name: *name, Lists: splitFields(*lists), Write: *write, Fields: splitFields(*fields), Created: now}
+		for _, field := range token.Fields {
+			if !fieldNameRe.MatchString(field) {
+				return fmt.Errorf(T("invalid field name %q"), field)
+			}
+		}
+		if *expires != "" {
+			day, err := parseDate(*expires, now)
+			if err != nil {
+				return err
+			}
+			last, _ := time.ParseInLocation(dateLayout, day, time.Local)
+			token.Expires = last.AddDate(0, 0, 1)
+			if !token.Expires.After(now) {
+				return fmt.Errorf(T("expiry %s is in the past"), day)
+			}
+		}
+		secret := randomHex(16)
+		token.Hash = hashSecret(secret)
+		if err := saveTokens(append(tokens, token)); err != nil {
+			return err
+		}
+		fmt.Printf(T("Created token %s. It is only shown now:\n"), token.ID)
+		fmt.Printf("todo_%s_%s\n", token.ID, secret)
+		return nil
+
+	case "list":
+		if len(tokens) == 0 {
+			fmt.Println(T("No tokens."))
+			return nil
+		}
+		for _, token := range tokens {
+			lists, access, fields, expires := token.describe()
+			status := ""
+			if !token.Expires.IsZero() && now.After(token.Expires) {
+				status = "  " + T("(expired)")
+			}
+			fmt.Printf(T("%s  %s  lists: %s  access: %s  fields: %s  expires: %s%s\n"), token.ID, token.Name, lists, access, fields, expires, status)
+		}
+		return nil
+
+	case "revoke":
+		if len(args) != 2 {
+			return usage
+		}
+		i := slices.IndexFunc(tokens, func(t accessToken) bool { return t.ID == args[1] })
+		if i < 0 {
+			return fmt.Errorf(T("no token with ID %q"), args[1])
+		}
+		if err := saveTokens(slices.Delete(tokens, i, i+1)); err != nil {
+			return err
+		}
+		fmt.Printf(T("Revoked token %s\n"), args[1])
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// State of the interactive task list
+type tuiState struct {
+	tl       *TodoList
+	filename string
+	cursor   int    // index into the visible tasks
+	offset   int    // first visible task on screen, for scrolling
+	filter   string // title filter, "" for all
+	hideDone bool
+	// Session filter such as "tag:work due<1w", sticky until cleared
+	query   string
+	session taskFilter
+	// Line being typed for add, edit, a filter or a delete confirmation;
+	// mode is "" when no input is open
+	mode    string
+	input   []rune
+	message string
+}
+
+// Full-screen interactive list, starting on task focus if it is given
+func runTUI(tl *TodoList, filename string, focus int) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New(T("tui needs an interactive terminal"))
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	// Alternate screen, so the shell's scrollback comes back on exit
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	s := &tuiState{tl: tl, filename: filename}
+	tasks, _ := s.visible()
+	for i, task := range tasks {
+		if task.ID == focus {
+			s.cursor = i
+		}
+	}
+	buf := make([]byte, 64)
+	for {
+		s.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			return nil
+		}
+		if quit := s.handle(string(buf[:n])); quit {
+			return nil
+		}
+	}
+}
+
+// Tasks on screen, as a tree, with their depth
+func (s *tuiState) visible() ([]Task, map[int]int) {
+	matches := func(string) bool { return true }
+	if s.filter != "" {
+		matches, _ = titleMatcher(s.filter, false)
+	}
+	return treeOrder(s.tl.Filter(func(task Task) bool {
+		return matches(task.Title) && s.session.keep(task) && !(s.hideDone && task.Completed)
+	}))
+}
+
+// Applies one chunk of input; terminals send a whole escape sequence such
+// as an arrow key in one read. Returns true to quit
+func (s *tuiState) handle(in string) bool {
+	if s.mode != "" {
+		s.handleInput(in)
+		return false
+	}
+	tasks, _ := s.visible()
+	s.message = ""
+	var current Task
+	if s.cursor < len(tasks) {
+		current = tasks[s.cursor]
+	}
+	switch in {
+	case "q", "\x03", "\x1b":
+		return true
+	case "j", "\x1b[B", "\x1bOB":
+		s.cursor = min(s.cursor+1, max(len(tasks)-1, 0))
+	case "k", "\x1b[A", "\x1bOA":
+		s.cursor = max(s.cursor-1, 0)
+	case "g", "\x1b[H":
+		s.cursor = 0
+	case "G", "\x1b[F":
+		s.cursor = max(len(tasks)-1, 0)
+	case " ", "x":
+		if current.ID != 0 {
+			s.toggle(current)
+		}
+	case "a":
+		s.mode, s.input = "add", nil
+	case "e":
+		if current.ID != 0 {
+			s.mode, s.input = "edit", []rune(current.Title)
+		}
+	case "d":
+		if current.ID != 0 {
+			s.mode, s.input = "delete", nil
+		}
+	case "/":
+		s.mode, s.input = "filter", []rune(s.filter)
+	case "f":
+		s.mode, s.input = "session", []rune(s.query)
+	case "h":
+		s.hideDone = !s.hideDone
+	case "s":
+		targets, err := knownSyncTargets(s.filename)
+		if err != nil {
+			s.message = err.Error()
+			break
+		}
+		s.mode, s.input = "sync", nil
+		if len(targets) > 0 {
+			s.input = []rune(targets[0])
+		}
+	}
+	return false
+}
+
+// Edits the open input line; Enter applies it and Esc cancels
+func (s *tuiState) handleInput(in string) {
+	switch in {
+	case "\x1b", "\x03":
+		s.mode, s.input = "", nil
+		return
+	case "\r", "\n":
+		s.apply()
+		s.mode, s.input = "", nil
+		return
+	default:
+		if s.mode == "delete" {
+			if in == "y" || in == "Y" {
+				s.apply()
+			}
+			s.mode = ""
+			return
+		}
+		// Ignore other control keys and escape sequences
+		if strings.HasPrefix(in, "\x1b") || !utf8.ValidString(in) {
+			return
+		}
+		// Key repeat can deliver several backspaces in one read
+		for _, r := range in {
+			switch {
+			case r == '\x7f' || r == '\b':
+				if len(s.input) > 0 {
+					s.input = s.input[:len(s.input)-1]
+				}
+			case r >= ' ':
+				s.input = append(s.input, r)
+			}
+		}
+	}
+	if s.mode == "filter" {
+		s.filter, s.cursor, s.offset = string(s.input), 0, 0
+	}
+}
+
+// Carries out the add, edit or delete the input line was opened for
+func (s *tuiState) apply() {
+	tasks, _ := s.visible()
+	text := strings.TrimSpace(string(s.input))
+	var err error
+	switch s.mode {
+	case "add":
+		if text == "" {
+			return
+		}
+		var added Task
+		err = s.tl.Transaction(s.filename, func() error {
+			var err error
+			added, err = s.tl.add(withRules(Task{Title: normalizeTitle(text)}))
+			return err
+		})
+		if err == nil {
+			s.message = fmt.Sprintf(T("Added task %d"), added.ID)
+		}
+	case "edit":
+		if text == "" || s.cursor >= len(tasks) {
+			return
+		}
+		id, title := tasks[s.cursor].ID, normalizeTitle(text)
+		err = s.tl.Transaction(s.filename, func() error {
+			task, ok := s.tl.Find(id)
+			if !ok {
+				return fmt.Errorf(T("task with ID %d not found"), id)
+			}
+			task.Title = title
+			return s.tl.Update(task)
+		})
+	case "delete":
+		if s.cursor >= len(tasks) {
+			return
+		}
+		task := tasks[s.cursor]
+		err = s.tl.Transaction(s.filename, func() error {
+			if _, err := runTaskHook("on-delete", task); err != nil {
+				return err
+			}
+			_, err := s.tl.List.DeleteTask(task.ID)
+			if err == nil {
+				s.tl.adoptChildren(task)
+			}
+			return err
+		})
+		if err == nil {
+			s.message = fmt.Sprintf(T("Deleted %q"), task.Title)
+		}
+	case "filter":
+		s.filter = text
+	case "sync":
+		if text != "" {
+			err = s.sync(text)
+		}
+	case "session":
+		// A bad expression keeps the filter there was
+		var session taskFilter
+		if err = parseFilterExpr(text, &session); err == nil {
+			s.query, s.session, s.cursor, s.offset = text, session, 0, 0
+		}
+	}
+	if err != nil {
+		s.message = err.Error()
+	}
+}
+
+// Syncs with a target in its saved settings, like todo sync, opening the
+// conflict screen for tasks changed differently on both sides
+func (s *tuiState) sync(target string) error {
+	target = syncTarget(target)
+	state, err := loadState(s.filename)
+	if err != nil {
+		return err
+	}
+	var report strings.Builder
+	if err := syncTasks(&report, s.tl, s.filename, target, state.Sync[syncTargetKey(target)], false, runConflictScreen); err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	s.message = lines[len(lines)-1]
+	return nil
+}
+
+// Completes an open task or reopens a done one
+func (s *tuiState) toggle(task Task) {
+	err := s.tl.Transaction(s.filename, func() error {
+		if task.Completed {
+			_, err := s.tl.reopen(task.ID)
+			return err
+		}
+		_, next, err := s.tl.complete(task.ID)
+		if err == nil && next.ID != 0 {
+			s.message = fmt.Sprintf(T("Next occurrence: task %d, due %s"), next.ID, next.Due)
+		}
+		return err
+	})
+	if err != nil {
+		s.message = err.Error()
+	}
+}
+
+// Redraws the whole screen. The terminal is in raw mode, so lines end in
+// \r\n explicitly
+func (s *tuiState) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	tasks, depth := s.visible()
+	s.cursor = max(min(s.cursor, len(tasks)-1), 0)
+	// Header, blank line, and three lines of help, input and messages
+	rows := max(height-5, 1)
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.cursor >= s.offset+rows {
+		s.offset = s.cursor - rows + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	open := 0
+	for _, task := range s.tl.Tasks {
+		if !task.Completed {
+			open++
+		}
+	}
+	header := fmt.Sprintf(T("todo: %d open, %d shown"), open, len(tasks))
+	if s.query != "" {
+		header += "  " + fmt.Sprintf(T("session filter: %s"), s.query)
+	}
+	if s.filter != "" {
+		header += "  " + fmt.Sprintf(T("filter: %s"), s.filter)
+	}
+	fmt.Fprintf(&b, "%s\r\n\r\n", header)
+	if len(tasks) == 0 {
+		b.WriteString("  " + T("No tasks found.") + "\r\n")
+	}
+	mark := doneMark()
+	for i := s.offset; i < len(tasks) && i < s.offset+rows; i++ {
+		task := tasks[i]
+		line := truncateWidth(taskCell(task, depth[task.ID], mark, time.Now()), width-3)
+		if i == s.cursor {
+			fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&b, "  %s\r\n", line)
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H", height-2)
+	switch s.mode {
+	case "add":
+		fmt.Fprintf(&b, "%s %s_", T("New task:"), string(s.input))
+	case "edit":
+		fmt.Fprintf(&b, "%s %s_", T("Title:"), string(s.input))
+	case "filter":
+		fmt.Fprintf(&b, "/%s_", string(s.input))
+	case "session":
+		fmt.Fprintf(&b, "%s %s_", T("Session filter (empty clears):"), string(s.input))
+	case "sync":
+		fmt.Fprintf(&b, "%s %s_", T("Sync with:"), string(s.input))
+	case "delete":
+		b.WriteString(T("Delete this task? (y/n)"))
+	default:
+		b.WriteString(s.message)
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H", height)
+	b.WriteString(truncateWidth(T("[x] done  [a]dd  [e]dit  [d]elete  [/] find  [f]ilter  [h]ide  [s]ync  [q]uit"), width-1))
+	fmt.Print(b.String())
+}
+
+// One task as a line of the interactive list: done box, indented title
+// and the table's marks
+func taskCell(task Task, depth int, mark string, now time.Time) string {
+	status := " "
+	if task.Completed {
+		status = mark
+	}
+	line := fmt.Sprintf("[%s] %s%s%s", status, treeIndent(depth), pinPrefix(task), task.Title)
+	if marks, _ := taskDetails(task, now); len(marks) > 0 {
+		line += "  " + strings.Join(marks, "  ")
+	}
+	return line
+}
+
+// Cuts s to at most w terminal cells
+func truncateWidth(s string, w int) string {
+	if displayWidth(s) <= w {
+		return s
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		rw := displayWidth(string(r))
+		if used+rw > w-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += rw
+	}
+	return b.String() + "…"
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+// How many operations undo can go back
+const journalLimit = 50
+
+// One task before and after an operation; nil means it didn't exist
+type taskChange struct {
+	ID     int   `json:"id"`
+	Before *Task `json:"before,omitempty"`
+	After  *Task `json:"after,omitempty"`
+}
+
+// The goal list before and after an operation that changed it
+type goalsChange struct {
+	Before []Goal `json:"before"`
+	After  []Goal `json:"after"`
+}
+
+// What one saved transaction changed
+type journalEntry struct {
+	Tasks []taskChange `json:"tasks,omitempty"`
+	Goals *goalsChange `json:"goals,omitempty"`
+}
+
+// Operations that can be undone, and undone ones that can be redone, most
+// recent last. Kept next to the store so undo works across invocations
+type journal struct {
+	Undo []journalEntry `json:"undo"`
+	Redo []journalEntry `json:"redo"`
+}
+
+func journalPath(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".undo.json"
+}
+
+func loadJournal(filename string) (journal, error) {
+	var j journal
+	data, err := readSidecar(journalPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return j, err
+	}
+	return j, json.Unmarshal(data, &j)
+}
+
+func saveJournal(filename string, j journal) error {
+	return writeJournal(filename, j, storeEncrypted(filename))
+}
+
+// Writes the journal, encrypted or not; it holds copies of tasks, so it is
+// encrypted along with the store
+func writeJournal(filename string, j journal, encrypt bool) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = sealSidecar(data, encrypt); err != nil {
+		return err
+	}
+	return os.WriteFile(journalPath(filename), data, 0644)
+}
+
+// Works out what changed between two versions of the list
+func diffLists(before, after *todo.List) journalEntry {
+	var entry journalEntry
+	old := map[int]*Task{}
+	for i := range before.Tasks {
+		old[before.Tasks[i].ID] = &before.Tasks[i]
+	}
+	for i := range after.Tasks {
+		task := &after.Tasks[i]
+		prev := old[task.ID]
+		delete(old, task.ID)
+		if prev == nil || !reflect.DeepEqual(*prev, *task) {
+			entry.Tasks = append(entry.Tasks, taskChange{ID: task.ID, Before: prev, After: task})
+		}
+	}
+	for i := range before.Tasks {
+		if task := &before.Tasks[i]; old[task.ID] != nil {
+			entry.Tasks = append(entry.Tasks, taskChange{ID: task.ID, Before: task})
+		}
+	}
+	if !reflect.DeepEqual(before.Goals, after.Goals) {
+		entry.Goals = &goalsChange{Before: before.Goals, After: after.Goals}
+	}
+	return entry
+}
+
+// Journals a saved transaction, making it the one undo reverses. A journal
+// that can't be written only costs the ability to undo, so it's logged
+func recordUndo(filename string, before, after *todo.List) {
+	entry := diffLists(before, after)
+	if len(entry.Tasks) == 0 && entry.Goals == nil {
+		return
+	}
+	j, err := loadJournal(filename)
+	if err != nil {
+		slog.Warn("undo journal unreadable, starting a new one", "err", err)
+	}
+	j.Undo = append(j.Undo, entry)
+	if len(j.Undo) > journalLimit {
+		j.Undo = j.Undo[len(j.Undo)-journalLimit:]
+	}
+	// A new change forks history; what was undone can't be redone onto it
+	j.Redo = nil
+	if err := saveJournal(filename, j); err != nil {
+		slog.Warn("could not write undo journal", "file", journalPath(filename), "err", err)
+	}
+}
+
+// Sets the list to one side of an entry: the before side to undo it, the
+// after side to redo it. Refuses if anything was changed since outside the
+// journal, rather than overwrite those changes
+func (tl *TodoList) applyEntry(entry journalEntry, undo bool) error {
+	for _, change := range entry.Tasks {
+		expect := change.After
+		if !undo {
+			expect = change.Before
+		}
+		current, ok := tl.Find(change.ID)
+		// Revisions move on with every save, undo and redo included
+		if ok != (expect != nil) || (ok && !todo.SameContent(current, *expect)) {
+			return fmt.Errorf(T("task %d has changed since; not touching it"), change.ID)
+		}
+	}
+	if entry.Goals != nil {
+		expect := entry.Goals.After
+		if !undo {
+			expect = entry.Goals.Before
+		}
+		if !reflect.DeepEqual(tl.Goals, expect) {
+			return errors.New(T("goals have changed since; not touching them"))
+		}
+	}
+
+	for _, change := range entry.Tasks {
+		want := change.Before
+		if !undo {
+			want = change.After
+		}
+		i := slices.IndexFunc(tl.Tasks, func(task Task) bool { return task.ID == change.ID })
+		switch {
+		case want == nil:
+			tl.Tasks = slices.Delete(tl.Tasks, i, i+1)
+		case i >= 0:
+			tl.Tasks[i] = *want
+		default:
+			// Put restored tasks back in ID order
+			at, _ := slices.BinarySearchFunc(tl.Tasks, want.ID, func(task Task, id int) int { return task.ID - id })
+			tl.Tasks = slices.Insert(tl.Tasks, at, *want)
+		}
+	}
+	if entry.Goals != nil {
+		tl.Goals = entry.Goals.Before
+		if !undo {
+			tl.Goals = entry.Goals.After
+		}
+	}
+	return nil
+}
+
+// Short description of an entry for undo/redo output
+func describeEntry(entry journalEntry) string {
+	switch {
+	case len(entry.Tasks) == 1:
+		change := entry.Tasks[0]
+		switch {
+		case change.Before == nil:
+			return fmt.Sprintf(T("add of task %d: %s"), change.ID, change.After.Title)
+		case change.After == nil:
+			return fmt.Sprintf(T("deletion of task %d: %s"), change.ID, change.Before.Title)
+		default:
+			return fmt.Sprintf(T("change to task %d: %s"), change.ID, change.After.Title)
+		}
+	case len(entry.Tasks) > 1:
+		return fmt.Sprintf(T("changes to %d tasks"), len(entry.Tasks))
+	default:
+		return T("changes to goals")
+	}
+}
+
+// Reverses the last journaled operation, or with redo reapplies the last
+// undone one
+func undoCommand(filename string, redo bool) error {
+	j, err := loadJournal(filename)
+	if err != nil {
+		return err
+	}
+	from, to := &j.Undo, &j.Redo
+	if redo {
+		from, to = &j.Redo, &j.Undo
+	}
+	if len(*from) == 0 {
+		if redo {
+			return errors.New(T("nothing to redo"))
+		}
+		return errors.New(T("nothing to undo"))
+	}
+	entry := (*from)[len(*from)-1]
+
+	// Saved as a change of its own, so the restored tasks get new
+	// revisions and win the next sync, but without a journal entry
+	tl := loadTodoList(filename)
+	if _, err := tl.commit(filename, func() error { return tl.applyEntry(entry, !redo) }); err != nil {
+		return err
+	}
+	*from, *to = (*from)[:len(*from)-1], append(*to, entry)
+	if err := saveJournal(filename, j); err != nil {
+		return err
+	}
+	if redo {
+		fmt.Printf(T("Redid %s\n"), describeEntry(entry))
+	} else {
+		fmt.Printf(T("Undid %s\n"), describeEntry(entry))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ikamii/go-todo-cli/pkg/todo"
+)
+
+func undoList() *TodoList {
+	return &TodoList{List: todo.List{Tasks: []Task{
+		{ID: 1, UUID: "u1", Hash: "h1", Title: "parent"},
+		{ID: 2, UUID: "u2", Hash: "h2", Title: "child", Parent: "u1"},
+		{ID: 3, UUID: "u3", Hash: "h3", Title: "water plants", Repeat: "weekly", Due: "2026-10-14"},
+	}}}
+}
+
+func TestUndoRedo(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tests := []struct {
+		name    string
+		op      func(tl *TodoList) error
+		changed int
+	}{
+		{"add", func(tl *TodoList) error {
+			_, err := tl.add(Task{Title: "new"})
+			return err
+		}, 1},
+		{"edit", func(tl *TodoList) error {
+			task, _ := tl.Find(1)
+			task.Title, task.Tags = "renamed", []string{"home"}
+			return tl.Update(task)
+		}, 1},
+		// The child moves up a level, so both are journaled
+		{"delete", func(tl *TodoList) error { return tl.DeleteTask(1) }, 2},
+		// The next occurrence is added alongside the completion
+		{"repeat completion", func(tl *TodoList) error {
+			_, _, err := tl.complete(3)
+			return err
+		}, 2},
+		{"goal", func(tl *TodoList) error {
+			tl.AddGoal("Run a 10k", "")
+			return nil
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := undoList()
+			before := tl.List.Clone()
+			if err := tt.op(tl); err != nil {
+				t.Fatal(err)
+			}
+			after := tl.List.Clone()
+			entry := diffLists(before, after)
+			if len(entry.Tasks) != tt.changed {
+				t.Errorf("journaled %d task changes, want %d", len(entry.Tasks), tt.changed)
+			}
+
+			if err := tl.applyEntry(entry, true); err != nil {
+				t.Fatalf("undo: %v", err)
+			}
+			if !reflect.DeepEqual(tl.Tasks, before.Tasks) || !reflect.DeepEqual(tl.Goals, before.Goals) {
+				t.Errorf("after undo\n%+v\nwant\n%+v", tl.List, *before)
+			}
+			if err := tl.applyEntry(entry, false); err != nil {
+				t.Fatalf("redo: %v", err)
+			}
+			if !reflect.DeepEqual(tl.Tasks, after.Tasks) || !reflect.DeepEqual(tl.Goals, after.Goals) {
+				t.Errorf("after redo\n%+v\nwant\n%+v", tl.List, *after)
+			}
+		})
+	}
+}
+
+// Undo leaves a task alone that was changed after the journaled operation
+func TestUndoRefusesLaterChanges(t *testing.T) {
+	tl := undoList()
+	before := tl.List.Clone()
+	task, _ := tl.Find(2)
+	task.Title = "first"
+	tl.Update(task)
+	entry := diffLists(before, tl.List.Clone())
+
+	task.Title = "second"
+	tl.Update(task)
+	if err := tl.applyEntry(entry, true); err == nil {
+		t.Fatal("undo went over a later change")
+	}
+	if task, _ := tl.Find(2); task.Title != "second" {
+		t.Errorf("title = %q, want the later change kept", task.Title)
+	}
+	// A new revision alone doesn't count as a change
+	task.Title, task.Rev = "first", task.Rev+1
+	tl.Update(task)
+	if err := tl.applyEntry(entry, true); err != nil {
+		t.Errorf("undo after a revision bump: %v", err)
+	}
+}
+
+func TestUndoCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	backend = "json"
+	filename := filepath.Join(dir, "todo.json")
+	tl := &TodoList{}
+	if err := tl.LoadFromFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	err := tl.Transaction(filename, func() error {
+		_, err := tl.add(Task{Title: "draft"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tl.Transaction(filename, func() error {
+		task, _ := tl.Find(1)
+		task.Title = "final"
+		return tl.Update(task)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := func() []string {
+		t.Helper()
+		l, err := todo.FileStore{Path: filename}.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, task := range l.Tasks {
+			out = append(out, task.Title)
+		}
+		return out
+	}
+	steps := []struct {
+		redo    bool
+		want    []string
+		wantErr bool
+	}{
+		{false, []string{"draft"}, false},
+		{false, nil, false},
+		{false, nil, true},
+		{true, []string{"draft"}, false},
+		{true, []string{"final"}, false},
+		{true, []string{"final"}, true},
+	}
+	for i, step := range steps {
+		err := undoCommand(filename, step.redo)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("step %d: error = %v, want error %v", i, err, step.wantErr)
+		}
+		if got := titles(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d: tasks = %q, want %q", i, got, step.want)
+		}
+	}
+}
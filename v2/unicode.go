@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Brings user input into NFC, so the same title typed on different systems
+// (precomposed vs. combining accents) is stored, matched and measured alike
+func normalizeTitle(title string) string {
+	return norm.NFC.String(strings.TrimSpace(title))
+}
+
+// Number of terminal cells s occupies: east-asian wide and fullwidth
+// characters (CJK, most emoji) take two, combining marks and zero-width
+// characters none
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch {
+		case r == '\u200d' || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+			// zero width
+		case r >= 0xfe00 && r <= 0xfe0f:
+			// variation selectors
+		default:
+			switch width.LookupRune(r).Kind() {
+			case width.EastAsianWide, width.EastAsianFullwidth:
+				n += 2
+			default:
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Pads s with spaces to w terminal cells
+func padRight(s string, w int) string {
+	if pad := w - displayWidth(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// Reports whether the terminal can be expected to render UTF-8. A locale
+// that is set but isn't UTF-8 (LANG=C, ISO-8859-1) turned "✓" into mojibake
+func utf8Terminal() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	// No locale at all: modern terminals default to UTF-8, the classic
+	// Windows console does not
+	return runtime.GOOS != "windows"
+}
+
+// The mark put before pinned tasks' titles
+func pinMark() string {
+	if utf8Terminal() {
+		return "📌"
+	}
+	return "^"
+}
+
+// The mark used for completed tasks in tables
+func doneMark() string {
+	if utf8Terminal() {
+		return "✓"
+	}
+	return "x"
+}
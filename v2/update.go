@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set at release build time:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.releasePublicKey=<base64 ed25519 key>"
+var (
+	version          = "dev"
+	releasePublicKey = ""
+)
+
+// Where releases are looked up; TODO_RELEASE_URL overrides it for mirrors
+const defaultReleaseURL = "https://api.github.com/repos/ikamii/go-todo-cli/releases/latest"
+
+// A published release: the binaries plus checksums.txt and its detached
+// ed25519 signature checksums.txt.sig
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+var updateClient = &http.Client{Timeout: 2 * time.Minute}
+
+func latestRelease() (*release, error) {
+	url := os.Getenv("TODO_RELEASE_URL")
+	if url == "" {
+		url = defaultReleaseURL
+	}
+	data, err := download(url)
+	if err != nil {
+		return nil, err
+	}
+	var rel release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := updateClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(T("fetching %s: %s"), url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (r *release) assetURL(name string) (string, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, true
+		}
+	}
+	return "", false
+}
+
+// Name of the release binary for this platform
+func binaryAssetName() string {
+	name := fmt.Sprintf("todo_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Reports whether tag is a newer version than the running one. Development
+// builds always consider a release newer
+func isNewer(tag string) bool {
+	if version == "dev" {
+		return true
+	}
+	a, b := parseVersion(tag), parseVersion(version)
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// Parses "v1.2.3" (pre-release suffixes ignored) into its numbers
+func parseVersion(v string) [3]int {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	for i, field := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(field)
+	}
+	return parts
+}
+
+// Prints the version and, with check, whether a newer release exists
+func printVersion(check bool) error {
+	fmt.Printf(T("todo %s (%s/%s)\n"), version, runtime.GOOS, runtime.GOARCH)
+	if !check {
+		return nil
+	}
+	rel, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if isNewer(rel.TagName) {
+		fmt.Printf(T("Update available: %s (run \"todo self-update\")\n"), rel.TagName)
+	} else {
+		fmt.Println(T("You are running the latest version."))
+	}
+	return nil
+}
+
+// Downloads the latest release binary, checks it against the signed
+// checksum list and atomically replaces the running executable
+func selfUpdate() error {
+	if releasePublicKey == "" {
+		return errors.New(T("this build has no release signing key; self-update is disabled"))
+	}
+	key, err := base64.StdEncoding.DecodeString(releasePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New(T("this build has an invalid release signing key"))
+	}
+
+	rel, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if !isNewer(rel.TagName) {
+		fmt.Println(T("You are running the latest version."))
+		return nil
+	}
+
+	name := binaryAssetName()
+	binURL, ok := rel.assetURL(name)
+	sumsURL, ok2 := rel.assetURL("checksums.txt")
+	sigURL, ok3 := rel.assetURL("checksums.txt.sig")
+	if !ok || !ok2 || !ok3 {
+		return fmt.Errorf(T("release %s has no %s, checksums.txt or checksums.txt.sig"), rel.TagName, name)
+	}
+
+	sums, err := download(sumsURL)
+	if err != nil {
+		return err
+	}
+	sig, err := download(sigURL)
+	if err != nil {
+		return err
+	}
+	// The signature may be raw or base64 encoded
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = decoded
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), sums, sig) {
+		return errors.New(T("checksums.txt signature does not verify; not updating"))
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(T("Downloading %s %s...\n"), name, rel.TagName)
+	binary, err := download(binURL)
+	if err != nil {
+		return err
+	}
+	got := sha256.Sum256(binary)
+	if hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf(T("checksum mismatch for %s; not updating"), name)
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		return err
+	}
+	fmt.Printf(T("Updated to %s\n"), rel.TagName)
+	return nil
+}
+
+// Finds name in a sha256sum-style listing
+func checksumFor(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf(T("no checksum listed for %s"), name)
+}
+
+// Writes the new binary next to the running one and renames it into place,
+// so an interrupted update never leaves a half-written executable
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".todo-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// A running executable can't be replaced on Windows, only renamed
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+	slog.Info("replacing executable", "path", exe)
+	return os.Rename(tmp.Name(), exe)
+}
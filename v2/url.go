@@ -0,0 +1,66 @@
+package main
+
+import (
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// How long to wait for a page before falling back to the bare URL
+const titleFetchTimeout = 5 * time.Second
+
+// Pages are only read this far looking for <title>
+const titleFetchLimit = 1 << 20
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Reports whether s is a single http(s) URL rather than a task description
+func isURL(s string) bool {
+	if strings.ContainsAny(s, " \t\n") {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Fetches the page title for rawURL. Any failure (offline, timeout, not
+// HTML, no title) returns the URL itself so capturing never fails
+func fetchTitle(rawURL string) string {
+	client := &http.Client{Timeout: titleFetchTimeout}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return rawURL
+	}
+	req.Header.Set("User-Agent", "todo-cli")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Info("could not fetch page title", "url", rawURL, "err", err)
+		return rawURL
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		slog.Info("no page title to use", "url", rawURL, "status", resp.Status, "type", resp.Header.Get("Content-Type"))
+		return rawURL
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, titleFetchLimit))
+	if err != nil {
+		return rawURL
+	}
+	m := titleRe.FindSubmatch(body)
+	if m == nil {
+		return rawURL
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	if title == "" {
+		return rawURL
+	}
+	return title
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Parks a task until someone else delivers, optionally with a date to
+// chase them up
+func (tl *TodoList) SetWaiting(id int, on, followUp string) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Waiting = true
+			tl.Tasks[i].WaitingOn = on
+			tl.Tasks[i].FollowUp = followUp
+			fmt.Printf(T("Task %d is %s\n"), id, waitingNote(tl.Tasks[i]))
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Makes a waiting task actionable again
+func (tl *TodoList) StopWaiting(id int) error {
+	for i := range tl.Tasks {
+		if tl.Tasks[i].ID == id {
+			tl.Tasks[i].Waiting = false
+			tl.Tasks[i].WaitingOn = ""
+			tl.Tasks[i].FollowUp = ""
+			fmt.Printf(T("Task %d is no longer waiting\n"), id)
+			return nil
+		}
+	}
+	return fmt.Errorf(T("task with ID %d not found"), id)
+}
+
+// Describes what a waiting task waits on, e.g. "waiting on Bob's reply,
+// follow up 2024-05-03"
+func waitingNote(task Task) string {
+	parts := []string{T("waiting")}
+	if task.WaitingOn != "" {
+		parts[0] = fmt.Sprintf(T("waiting on %s"), task.WaitingOn)
+	}
+	if task.FollowUp != "" {
+		parts = append(parts, fmt.Sprintf(T("follow up %s"), task.FollowUp))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Open waiting tasks whose follow-up date has arrived
+func (tl *TodoList) FollowUpsDue(now time.Time) []Task {
+	return tl.Filter(func(task Task) bool {
+		return task.Waiting && !task.Completed && dateReached(task.FollowUp, now)
+	})
+}
+
+// Nags about overdue follow-ups below list output until the task is
+// resolved or the follow-up is pushed back
+func (tl *TodoList) PrintFollowUps(now time.Time) {
+	for _, task := range tl.FollowUpsDue(now) {
+		if task.WaitingOn != "" {
+			fmt.Printf(T("Follow up on task %d (%s): still waiting on %s\n"), task.ID, task.Title, task.WaitingOn)
+		} else {
+			fmt.Printf(T("Follow up on task %d (%s): due since %s\n"), task.ID, task.Title, task.FollowUp)
+		}
+	}
+}
+
+// Handles `todo waiting [<task> [--on who] [--follow-up 3d] | --clear <task>]`
+func waitingCommand(filename string, args []string) error {
+	// Allow the task before the flags, as in the usage line
+	var ref string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		ref, args = args[0], args[1:]
+	}
+	waitCmd := flag.NewFlagSet("waiting", flag.ExitOnError)
+	on := waitCmd.String("on", "", "who or what the task is waiting on")
+	followUp := waitCmd.String("follow-up", "", "when to chase it up: YYYY-MM-DD, 3d or 2w")
+	clear := waitCmd.Bool("clear", false, "stop waiting and make the task actionable again")
+	waitCmd.Parse(args)
+	if ref == "" && waitCmd.NArg() > 0 {
+		ref = waitCmd.Arg(0)
+	}
+
+	tl := loadTodoList(filename)
+	now := time.Now()
+	if ref == "" {
+		if *on != "" || *followUp != "" || *clear {
+			return errors.New(T("a task ID is required"))
+		}
+		waiting := tl.Filter(func(task Task) bool { return task.Waiting && !task.Completed })
+		tl.PrintTasks(waiting)
+		tl.PrintFollowUps(now)
+		return nil
+	}
+
+	id, err := tl.Resolve(ref)
+	if err != nil {
+		return err
+	}
+	if *clear {
+		return tl.Transaction(filename, func() error {
+			return tl.StopWaiting(id)
+		})
+	}
+	date := ""
+	if *followUp != "" {
+		if date, err = parseDate(*followUp, now); err != nil {
+			return err
+		}
+	}
+	return tl.Transaction(filename, func() error {
+		return tl.SetWaiting(id, *on, date)
+	})
+}